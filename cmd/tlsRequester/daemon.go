@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/requester"
+)
+
+// daemonMessage is one NDJSON line on stdout. Responses to concurrent
+// requests interleave; id ties each line to its request.
+type daemonMessage struct {
+	ID         string               `json:"id"`
+	Type       string               `json:"type"` // response, data, end or error
+	Status     int                  `json:"status,omitempty"`
+	StatusText string               `json:"status_text,omitempty"`
+	Proto      string               `json:"proto,omitempty"`
+	Headers    config.Headers       `json:"headers,omitempty"` // [name, value] pairs in wire order
+	URL        string               `json:"url,omitempty"`     // final URL after redirects
+	RemoteAddr string               `json:"remote_addr,omitempty"`
+	Proxy      string               `json:"proxy,omitempty"`
+	Redirects  []requester.Redirect `json:"redirects,omitempty"`
+	Data       []byte               `json:"data,omitempty"` // base64 body chunk
+	Timings    *requester.Timings   `json:"timings,omitempty"`
+	Error      string               `json:"error,omitempty"`
+	ErrorType  string               `json:"error_type,omitempty"`
+}
+
+// defaultDaemonConcurrency caps the requests a daemon serves at once.
+const defaultDaemonConcurrency = 64
+
+// runDaemon reads newline-delimited requests from stdin and serves them
+// concurrently over pooled connections until stdin closes. Once
+// -concurrency requests are in flight, it stops reading stdin until one
+// finishes.
+func runDaemon(args []string) {
+	fs := flag.NewFlagSet("daemon", flag.ExitOnError)
+	configPath := fs.String("c", "", "Config file for requests without config_path or profile (default config.json)")
+	profile := fs.String("profile", "", "Built-in profile for requests without config_path or profile")
+	concurrency := fs.Int("concurrency", defaultDaemonConcurrency, "Maximum requests served at once")
+	fs.Parse(args)
+	if *concurrency < 1 {
+		fmt.Fprintln(os.Stderr, "Error: -concurrency must be at least 1")
+		os.Exit(1)
+	}
+
+	d := &daemon{
+		session: newSession(*configPath, *profile),
+		out:     json.NewEncoder(os.Stdout),
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	var wg sync.WaitGroup
+	slots := make(chan struct{}, *concurrency)
+	for seq := 1; scanner.Scan(); seq++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req config.Request
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			d.send(daemonMessage{ID: "", Type: "error", Error: fmt.Sprintf("failed to parse request: %v", err), ErrorType: "INPUT_ERROR"})
+			continue
+		}
+		if req.ID == "" {
+			req.ID = strconv.Itoa(seq)
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			d.serve(&req)
+		}()
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: failed to read stdin: %v\n", err)
+	}
+	wg.Wait()
+	d.close()
+}
+
+type daemon struct {
+	*session
+
+	outMu sync.Mutex
+	out   *json.Encoder
+}
+
+func (d *daemon) send(msg daemonMessage) {
+	d.outMu.Lock()
+	defer d.outMu.Unlock()
+	d.out.Encode(msg)
+}
+
+func (d *daemon) serve(req *config.Request) {
+	cfg, err := d.config(req)
+	if err != nil {
+		d.send(daemonMessage{ID: req.ID, Type: "error", Error: fmt.Sprintf("failed to load config: %v", err), ErrorType: "CONFIG_ERROR"})
+		return
+	}
+
+	timer := requester.NewTimer()
+	resp, err := d.do(requester.WithTimer(context.Background(), timer), req, cfg)
+	if err != nil {
+		errType, _ := requester.ClassifyError(err)
+		d.send(daemonMessage{ID: req.ID, Type: "error", Error: err.Error(), ErrorType: errType})
+		return
+	}
+	defer resp.Body.Close()
+
+	d.send(daemonMessage{
+		ID:         req.ID,
+		Type:       "response",
+		Status:     resp.StatusCode,
+		StatusText: strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		Proto:      resp.Proto,
+		Headers:    requester.ResponseHeaders(resp),
+		URL:        resp.Request.URL.String(),
+		RemoteAddr: timer.RemoteAddr(),
+		Proxy:      timer.Proxy(),
+		Redirects:  requester.RedirectChain(resp),
+	})
+
+	buf := make([]byte, 16384)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			d.send(daemonMessage{ID: req.ID, Type: "data", Data: buf[:n]})
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			errType, _ := requester.ClassifyError(err)
+			d.send(daemonMessage{ID: req.ID, Type: "error", Error: err.Error(), ErrorType: errType})
+			return
+		}
+	}
+	timings := timer.Finish()
+	d.send(daemonMessage{ID: req.ID, Type: "end", Timings: &timings})
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/fingerprint"
+)
+
+// runHash prints the fingerprints a config produces without sending anything.
+func runHash(args []string) {
+	fs := flag.NewFlagSet("hash", flag.ExitOnError)
+	configPath := fs.String("c", "config.json", "Config file path")
+	targetURL := fs.String("url", "https://example.com/", "Target URL (sets SNI)")
+	asJSON := fs.Bool("json", false, "Print as JSON")
+	fs.Parse(args)
+
+	cfg, err := config.LoadConfig(*configPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(4)
+	}
+
+	hashes, err := fingerprint.ComputeHashes(cfg, *targetURL)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(hashes, "", "  ")
+		fmt.Println(string(data))
+		return
+	}
+	fmt.Printf("JA3:         %s\n", hashes.JA3)
+	fmt.Printf("JA3 hash:    %s\n", hashes.JA3Hash)
+	fmt.Printf("JA3N:        %s\n", hashes.JA3N)
+	fmt.Printf("JA3N hash:   %s\n", hashes.JA3NHash)
+	fmt.Printf("JA4:         %s\n", hashes.JA4)
+	if hashes.Akamai != "" {
+		fmt.Printf("Akamai:      %s\n", hashes.Akamai)
+		fmt.Printf("Akamai hash: %s\n", hashes.AkamaiHash)
+	}
+}
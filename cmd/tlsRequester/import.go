@@ -0,0 +1,57 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"fingerPrintRequester/internal/fingerprint"
+)
+
+// runImport converts a captured ClientHello (pcap, pcapng, raw or hex) into
+// a complete config file.
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	output := fs.String("o", "", "Write config to file instead of stdout")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: tlsRequester import [-o config.json] <capture.pcap|capture.pcapng|hello.bin|hello.hex|->")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	var data []byte
+	var err error
+	if fs.Arg(0) == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(fs.Arg(0))
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	raw, err := fingerprint.ExtractClientHello(data)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	hello, err := fingerprint.ParseClientHello(raw)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	fp, notes, err := fingerprint.FromClientHello(hello)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	writeConfig(newConfig(fp), *output)
+
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/fingerprint"
+)
+
+// runJA3 converts a JA3 string into a complete config file.
+func runJA3(args []string) {
+	fs := flag.NewFlagSet("ja3", flag.ExitOnError)
+	output := fs.String("o", "", "Write config to file instead of stdout")
+	fs.Parse(args)
+
+	if fs.NArg() != 1 {
+		fmt.Fprintln(os.Stderr, "Usage: tlsRequester ja3 [-o config.json] <ja3 string>")
+		fs.PrintDefaults()
+		os.Exit(1)
+	}
+
+	fp, notes, err := fingerprint.FromJA3(fs.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	writeConfig(newConfig(fp), *output)
+
+	for _, note := range notes {
+		fmt.Fprintf(os.Stderr, "Note: %s\n", note)
+	}
+}
+
+// newConfig wraps an imported fingerprint with the defaults used by the
+// bundled config files.
+func newConfig(fp *config.FingerprintConfig) *config.Config {
+	cfg := config.Default()
+	cfg.Fingerprint = *fp
+	return cfg
+}
+
+func writeConfig(cfg *config.Config, path string) {
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	data = append(data, '\n')
+	if path == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+}
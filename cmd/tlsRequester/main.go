@@ -5,17 +5,37 @@ import (
 	"flag"
 	"fmt"
 	"io"
+	"maps"
 	"os"
+	"slices"
 	"strings"
 
 	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/profiles"
 	"fingerPrintRequester/internal/requester"
 )
 
 func main() {
-	// Check if running in curl mode (has command line args)
+	// Check if running a subcommand or curl mode (has command line args)
 	if len(os.Args) > 1 {
-		runCurlMode()
+		switch os.Args[1] {
+		case "ja3":
+			runJA3(os.Args[2:])
+		case "hash":
+			runHash(os.Args[2:])
+		case "import":
+			runImport(os.Args[2:])
+		case "profiles":
+			runProfiles(os.Args[2:])
+		case "daemon":
+			runDaemon(os.Args[2:])
+		case "serve":
+			runServe(os.Args[2:])
+		case "proxy":
+			runProxy(os.Args[2:])
+		default:
+			runCurlMode()
+		}
 		return
 	}
 
@@ -29,14 +49,29 @@ func main() {
 	if err := json.Unmarshal(input, &req); err != nil {
 		outputError("INPUT_ERROR", fmt.Sprintf("failed to parse request: %v", err), 1)
 	}
+	if err := checkFormat(req.Format); err != nil {
+		outputError("INPUT_ERROR", err.Error(), 1)
+	}
 
 	// Load config
-	cfg, err := config.LoadConfig(req.ConfigPath)
+	cfg, err := loadConfig(req.ConfigPath, req.Profile)
 	if err != nil {
 		outputError("CONFIG_ERROR", fmt.Sprintf("failed to load config: %v", err), 4)
 	}
 
-	// Override config with request parameters
+	applyRequestOverrides(cfg, &req)
+
+	// Make request
+	if err := requester.MakeRequest(&req, cfg); err != nil {
+		errType, code := requester.ClassifyError(err)
+		outputError(errType, err.Error(), code)
+	}
+}
+
+// applyRequestOverrides lets a stdin request override the timeout, proxy,
+// DNS and TLS sections, the proxy session and the local address of its
+// config, and add resolve and connect_to entries ahead of the config's.
+func applyRequestOverrides(cfg *config.Config, req *config.Request) {
 	if req.Timeout != nil {
 		if req.Timeout.Connect > 0 {
 			cfg.Timeout.Connect = req.Timeout.Connect
@@ -48,35 +83,82 @@ func main() {
 	if req.Proxy != nil {
 		cfg.Proxy = *req.Proxy
 	}
+	if req.ProxySession != "" {
+		cfg.Proxy.SessionKey = req.ProxySession
+	}
 	if req.DNS != nil {
 		cfg.DNS = *req.DNS
 	}
-
-	// Make request
-	if err := requester.MakeRequest(&req, cfg); err != nil {
-		errMsg := err.Error()
-		code := 2 // Default: network error
-		errType := "NETWORK_ERROR"
-		
-		if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline") {
-			code = 3
-			errType = "TIMEOUT_ERROR"
+	if req.TLS != nil {
+		if req.TLS.Insecure {
+			cfg.TLS.Insecure = true
+		}
+		if req.TLS.CAFile != "" {
+			cfg.TLS.CAFile = req.TLS.CAFile
 		}
-		
-		outputError(errType, errMsg, code)
+		if len(req.TLS.Pins) > 0 {
+			// cfg is a copy of a cached config, so its map is shared
+			pins := maps.Clone(cfg.TLS.Pins)
+			if pins == nil {
+				pins = map[string][]string{}
+			}
+			maps.Copy(pins, req.TLS.Pins)
+			cfg.TLS.Pins = pins
+		}
+		// A client certificate comes with its own key and password
+		if req.TLS.CertFile != "" {
+			cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CertPassword = req.TLS.CertFile, req.TLS.KeyFile, req.TLS.CertPassword
+		}
+	}
+	if len(req.Resolve) > 0 {
+		cfg.Resolve = append(slices.Clone(req.Resolve), cfg.Resolve...)
+	}
+	if len(req.ConnectTo) > 0 {
+		cfg.ConnectTo = append(slices.Clone(req.ConnectTo), cfg.ConnectTo...)
+	}
+	if len(req.LocalAddress) > 0 {
+		cfg.LocalAddress = req.LocalAddress
 	}
 }
 
 const version = "1.0.0"
 
 func runCurlMode() {
+	var headers, proxyHeaders headerFlags
+	flag.Var(&headers, "H", "Header \"Name: value\" or JSON object/array; repeat for more, order is kept")
+	flag.Var(&proxyHeaders, "proxy-header", "Header for the CONNECT request to an HTTP proxy; repeat for more")
+	var resolve, connectTo, localAddress listFlags
+	flag.Var(&resolve, "resolve", "Use addr for host:port, as host:port:addr[,addr]; repeat for more")
+	flag.Var(&connectTo, "connect-to", "Connect to host2:port2 for host:port, as host:port:host2:port2; repeat for more")
+	flag.Var(&localAddress, "interface", "Local IP address or interface to connect from; repeat to rotate through several")
 	var (
 		method     = flag.String("X", "GET", "HTTP method")
-		headerArgs = flag.String("H", "", "Headers (JSON object or key:value)")
 		data       = flag.String("d", "", "Request body")
-		configPath = flag.String("c", "config.json", "Config file path")
+		configPath = flag.String("c", "", "Config file path (default config.json unless -profile is set)")
+		profile    = flag.String("profile", "", "Built-in profile (see 'profiles list')")
 		proxy      = flag.String("x", "", "Proxy URL")
+		proxyUser  = flag.String("U", "", "Proxy credentials user:password (instead of user:pass@ in -x)")
+		proxyInsecure = flag.Bool("proxy-insecure", false, "Skip verifying an https proxy's certificate")
+		proxyCA    = flag.String("proxy-cacert", "", "CA bundle (PEM) to verify an https proxy with")
+		proxyFile  = flag.String("proxy-file", "", "File of proxy URLs, one per line with an optional weight, to pick from")
+		proxyStrategy = flag.String("proxy-strategy", "", "How to pick from -proxy-file: round_robin, random, weighted, sticky_host or sticky_session")
+		proxySession = flag.String("proxy-session", "", "Session key for -proxy-strategy sticky_session")
+		dnsServers = flag.String("dns-servers", "", "Comma-separated DNS servers: ip[:port], tcp://, tls:// or https:// (DoH)")
+		dnsStrict  = flag.Bool("dns-strict", false, "Fail instead of falling back to system DNS when -dns-servers fail")
+		ipv4       = flag.Bool("4", false, "Connect over IPv4 only")
+		ipv6       = flag.Bool("6", false, "Connect over IPv6 only")
 		showVersion = flag.Bool("v", false, "Show version")
+		verbose    = flag.Bool("verbose", false, "Print fingerprint hashes to stderr")
+		insecure   = flag.Bool("k", false, "Skip server certificate verification")
+		caFile     = flag.String("cacert", "", "CA bundle (PEM) to verify the server with instead of system roots")
+		certFile   = flag.String("cert", "", "Client certificate for mTLS (PEM or PKCS#12)")
+		keyFile    = flag.String("key", "", "Client private key (PEM) if not in -cert")
+		certPass   = flag.String("cert-pass", "", "Password for a PKCS#12 -cert")
+		location   = flag.Bool("L", false, "Follow redirects")
+		maxRedirs  = flag.Int("max-redirs", requester.DefaultMaxRedirects, "Maximum redirects to follow with -L")
+		cookieJar  = flag.String("cookie-jar", "", "Cookie file to send cookies from and save them to (Netscape or .json)")
+		compressed = flag.Bool("compressed", false, "Decode gzip/deflate/br/zstd response bodies")
+		format     = flag.String("format", requester.FormatRaw, "Output format: raw or ndjson")
 	)
 	flag.Parse()
 
@@ -93,54 +175,168 @@ func runCurlMode() {
 		os.Exit(1)
 	}
 
+	if *configPath == "" && *profile == "" {
+		*configPath = "config.json"
+	}
+
 	url := flag.Arg(0)
 	req := config.Request{
 		Method:     *method,
 		URL:        url,
-		Headers:    make(map[string]string),
+		Headers:    config.Headers(headers),
 		Body:       *data,
 		ConfigPath: *configPath,
-	}
+		Profile:    *profile,
+		Verbose:    *verbose,
+		Resolve:    resolve,
+		ConnectTo:  connectTo,
 
-	// Parse headers
-	if *headerArgs != "" {
-		if strings.HasPrefix(*headerArgs, "{") {
-			json.Unmarshal([]byte(*headerArgs), &req.Headers)
-		} else {
-			parts := strings.SplitN(*headerArgs, ":", 2)
-			if len(parts) == 2 {
-				req.Headers[strings.TrimSpace(parts[0])] = strings.TrimSpace(parts[1])
-			}
-		}
+		LocalAddress:    config.LocalAddresses(localAddress),
+		FollowRedirects: *location,
+		MaxRedirects:    *maxRedirs,
+		CookieJar:       *cookieJar,
+		Decompress:      *compressed,
+		Format:          *format,
+	}
+	if err := checkFormat(req.Format); err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
 
 	// Load config
-	cfg, err := config.LoadConfig(req.ConfigPath)
+	cfg, err := loadConfig(req.ConfigPath, req.Profile)
 	if err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		os.Exit(4)
 	}
+	applyRequestOverrides(cfg, &req)
 
 	// Set proxy if specified
 	if *proxy != "" {
-		proxyType := "http"
-		if strings.HasPrefix(*proxy, "socks") {
-			proxyType = "socks5"
-		}
-		cfg.Proxy = config.ProxyConfig{
-			Enabled: true,
-			Type:    proxyType,
-			URL:     *proxy,
-		}
+		cfg.Proxy = proxyFromURL(*proxy)
+	}
+	if *proxyFile != "" {
+		cfg.Proxy = config.ProxyConfig{Enabled: true, File: *proxyFile}
+	}
+	if *proxyStrategy != "" {
+		cfg.Proxy.Strategy = *proxyStrategy
+	}
+	if *proxySession != "" {
+		cfg.Proxy.SessionKey = *proxySession
+	}
+	if *proxyUser != "" {
+		cfg.Proxy.Username, cfg.Proxy.Password, _ = strings.Cut(*proxyUser, ":")
+	}
+	if len(proxyHeaders) > 0 {
+		cfg.Proxy.ConnectHeaders = config.Headers(proxyHeaders)
+	}
+	if *proxyInsecure {
+		cfg.Proxy.Insecure = true
+	}
+	if *proxyCA != "" {
+		cfg.Proxy.CAFile = *proxyCA
+	}
+	if *dnsServers != "" {
+		cfg.DNS.Servers = strings.Split(*dnsServers, ",")
+	}
+	if *dnsStrict {
+		cfg.DNS.Strict = true
+	}
+	if *ipv4 {
+		cfg.DNS.IPVersion = "4"
+	} else if *ipv6 {
+		cfg.DNS.IPVersion = "6"
+	}
+	if *insecure {
+		cfg.TLS.Insecure = true
+	}
+	if *caFile != "" {
+		cfg.TLS.CAFile = *caFile
+	}
+	if *certFile != "" {
+		cfg.TLS.CertFile, cfg.TLS.KeyFile, cfg.TLS.CertPassword = *certFile, *keyFile, *certPass
 	}
 
 	// Make request
 	if err := requester.MakeRequest(&req, cfg); err != nil {
 		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		_, code := requester.ClassifyError(err)
+		if code >= 5 {
+			os.Exit(code)
+		}
 		os.Exit(2)
 	}
 }
 
+// checkFormat rejects output formats MakeRequest does not know.
+func checkFormat(format string) error {
+	switch format {
+	case "", requester.FormatRaw, requester.FormatNDJSON:
+		return nil
+	}
+	return fmt.Errorf("unknown output format %q (want raw or ndjson)", format)
+}
+
+// proxyFromURL builds the proxy section for a -x proxy URL.
+func proxyFromURL(proxyURL string) config.ProxyConfig {
+	pc := config.ProxyConfig{Enabled: true, URL: proxyURL}
+	// The scheme picks the type; a bare host:port is an HTTP proxy
+	pc.Type, _, _ = pc.Endpoint()
+	return pc
+}
+
+// listFlags collects a repeated option in command-line order.
+type listFlags []string
+
+func (l *listFlags) String() string { return strings.Join(*l, ",") }
+
+func (l *listFlags) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// headerFlags collects repeated -H options in command-line order.
+type headerFlags config.Headers
+
+func (h *headerFlags) String() string { return "" }
+
+func (h *headerFlags) Set(value string) error {
+	if trimmed := strings.TrimSpace(value); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+		var parsed config.Headers
+		if err := json.Unmarshal([]byte(trimmed), &parsed); err != nil {
+			return err
+		}
+		*h = append(*h, parsed...)
+		return nil
+	}
+	hdr, err := config.ParseHeaderLine(value)
+	if err != nil {
+		return err
+	}
+	*h = append(*h, hdr)
+	return nil
+}
+
+// loadConfig loads the config file a request points at and applies its
+// built-in profile, if any. A profile alone needs no config file.
+func loadConfig(path, profile string) (*config.Config, error) {
+	cfg := config.Default()
+	if path != "" || profile == "" {
+		var err error
+		if cfg, err = config.LoadConfig(path); err != nil {
+			return nil, err
+		}
+	}
+	if profile != "" {
+		p, err := profiles.Get(profile)
+		if err != nil {
+			return nil, err
+		}
+		p.Apply(cfg)
+	}
+	return cfg, nil
+}
+
 func outputError(errType, msg string, exitCode int) {
 	errResp := map[string]interface{}{
 		"success":    false,
@@ -0,0 +1,308 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sort"
+	"sync"
+	"syscall"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/mitm"
+	"fingerPrintRequester/internal/profiles"
+	"fingerPrintRequester/internal/requester"
+)
+
+// runProxy runs a local HTTP forward proxy. CONNECT tunnels are terminated
+// with certificates from a local CA and every request is re-sent upstream
+// with the fingerprint the matching rule picks; plain http:// requests are
+// re-sent the same way.
+func runProxy(args []string) {
+	fs := flag.NewFlagSet("proxy", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8888", "Address to listen on")
+	caCert := fs.String("ca-cert", "mitm-ca.pem", "CA certificate (created with -ca-key if missing)")
+	caKey := fs.String("ca-key", "mitm-ca-key.pem", "CA private key")
+	rulesPath := fs.String("rules", "", "JSON rules choosing profile/config per host")
+	configPath := fs.String("c", "", "Config file for hosts no rule matches (default config.json)")
+	profile := fs.String("profile", "", "Built-in profile for hosts no rule matches")
+	outer := fs.String("x", "", "Upstream proxy URL to chain through")
+	fs.Parse(args)
+
+	ca, created, err := mitm.LoadOrCreateCA(*caCert, *caKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(4)
+	}
+	if created {
+		fmt.Fprintf(os.Stderr, "Created CA certificate %s; clients must trust it\n", *caCert)
+	}
+	var rules mitm.Rules
+	if *rulesPath != "" {
+		if rules, err = mitm.LoadRules(*rulesPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(4)
+		}
+	}
+
+	p := &mitmProxy{
+		session: newSession(*configPath, *profile),
+		ca:      ca,
+		rules:   rules,
+		tunnels: newConnListener(),
+	}
+	if *outer != "" {
+		proxyCfg := proxyFromURL(*outer)
+		p.outerProxy = &proxyCfg
+	}
+
+	// Decrypted tunnel connections are served by a second server, which
+	// handles the TLS handshake and HTTP/2 negotiation with the client.
+	inner := &http.Server{
+		Handler: http.HandlerFunc(p.handleTunneled),
+		ConnContext: func(ctx context.Context, c net.Conn) context.Context {
+			target, _ := p.targets.LoadAndDelete(c)
+			return context.WithValue(ctx, connectTargetKey{}, target)
+		},
+	}
+	go inner.Serve(p.tunnels)
+
+	srv := &http.Server{Addr: *listen, Handler: p}
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+		inner.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "TLS Requester v%s proxy listening on %s\n", version, *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	p.close()
+}
+
+type mitmProxy struct {
+	*session
+	ca         *mitm.CA
+	rules      mitm.Rules
+	outerProxy *config.ProxyConfig
+
+	tunnels *connListener
+	targets sync.Map // *tls.Conn -> CONNECT target host:port
+}
+
+type connectTargetKey struct{}
+
+func (p *mitmProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodConnect {
+		p.handleConnect(w, r)
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "This is a proxy: send absolute-form requests or CONNECT", http.StatusBadRequest)
+		return
+	}
+	p.forward(w, r, r.URL.String(), r.URL.Hostname())
+}
+
+func (p *mitmProxy) handleConnect(w http.ResponseWriter, r *http.Request) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(r.Host); err == nil {
+		host = h
+	}
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "CONNECT is not supported over HTTP/2", http.StatusHTTPVersionNotSupported)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	conn.Write([]byte("HTTP/1.1 200 Connection Established\r\n\r\n"))
+
+	if rule := p.rules.Match(host); rule != nil && rule.Passthrough {
+		p.tunnel(conn, r.Host, rule)
+		return
+	}
+	tlsConn := tls.Server(conn, p.ca.TLSConfig(host))
+	p.targets.Store(net.Conn(tlsConn), r.Host)
+	if !p.tunnels.push(tlsConn) {
+		p.targets.Delete(net.Conn(tlsConn))
+		conn.Close()
+	}
+}
+
+// tunnel relays bytes between the client and target without decrypting,
+// connecting the way rule's config does (its proxy, DNS and local address).
+func (p *mitmProxy) tunnel(client net.Conn, target string, rule *mitm.Rule) {
+	defer client.Close()
+	cfg, err := p.hostConfig(&config.Request{}, rule)
+	if err != nil {
+		return
+	}
+	upstream, err := requester.DialWithProxy(target, cfg)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	done := make(chan struct{}, 2)
+	go func() { io.Copy(upstream, client); done <- struct{}{} }()
+	go func() { io.Copy(client, upstream); done <- struct{}{} }()
+	<-done
+}
+
+func (p *mitmProxy) handleTunneled(w http.ResponseWriter, r *http.Request) {
+	target, _ := r.Context().Value(connectTargetKey{}).(string)
+	if target == "" {
+		target = r.Host
+	}
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	p.forward(w, r, "https://"+target+r.URL.RequestURI(), host)
+}
+
+// forward re-sends r to targetURL with the fingerprint chosen for host,
+// streaming the client's body upstream as it arrives.
+func (p *mitmProxy) forward(w http.ResponseWriter, r *http.Request, targetURL, host string) {
+	req := &config.Request{
+		Method:  r.Method,
+		URL:     targetURL,
+		Headers: proxiedHeaders(r),
+	}
+
+	rule := p.rules.Match(host)
+	cfg, err := p.hostConfig(req, rule)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to load config: %v", err), http.StatusBadGateway)
+		return
+	}
+
+	resp, err := p.doStream(r.Context(), req, r.Body, r.ContentLength, cfg)
+	if err != nil {
+		errType, _ := requester.ClassifyError(err)
+		status := http.StatusBadGateway
+		if errType == "TIMEOUT_ERROR" {
+			status = http.StatusGatewayTimeout
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	defer resp.Body.Close()
+	streamResponse(w, resp)
+}
+
+// hostConfig resolves the config for req under rule (nil for defaults).
+func (p *mitmProxy) hostConfig(req *config.Request, rule *mitm.Rule) (*config.Config, error) {
+	if rule != nil {
+		req.Profile, req.ConfigPath = rule.Profile, rule.Config
+	}
+	cfg, err := p.config(req)
+	if err != nil {
+		return nil, err
+	}
+	if rule != nil {
+		if rule.HeaderOrder != nil {
+			cfg.HeaderOrder = rule.HeaderOrder
+		}
+		if rule.PseudoHeaderOrder != nil {
+			cfg.PseudoHeaderOrder = rule.PseudoHeaderOrder
+		}
+	}
+	if len(cfg.HeaderOrder) == 0 {
+		cfg.HeaderOrder = defaultProxyHeaderOrder()
+	}
+	if p.outerProxy != nil {
+		cfg.Proxy = *p.outerProxy
+	}
+	return cfg, nil
+}
+
+// defaultProxyHeaderOrder is the header order of the latest Chrome profile,
+// used for configs without header_order since the client's order is lost.
+var defaultProxyHeaderOrder = sync.OnceValue(func() []string {
+	p, err := profiles.Get("chrome_latest")
+	if err != nil {
+		return nil
+	}
+	return p.HeaderOrder
+})
+
+// proxiedHeaders returns the client's end-to-end headers. Go's server does
+// not keep their order, so they are sorted by name here and the wire order
+// comes from header_order: the rule's, else the config's or profile's, else
+// the Chrome profile's (see hostConfig).
+func proxiedHeaders(r *http.Request) config.Headers {
+	skip := map[string]bool{"Proxy-Authorization": true}
+	for _, k := range hopHeaders {
+		skip[k] = true
+	}
+	names := make([]string, 0, len(r.Header))
+	for k := range r.Header {
+		if !skip[k] {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+
+	headers := config.Headers{}
+	if r.Host != "" && r.Host != r.URL.Host {
+		headers = append(headers, config.Header{Name: "Host", Value: r.Host})
+	}
+	for _, k := range names {
+		for _, v := range r.Header[k] {
+			headers = append(headers, config.Header{Name: k, Value: v})
+		}
+	}
+	return headers
+}
+
+// connListener is a net.Listener fed with already-accepted connections.
+type connListener struct {
+	conns     chan net.Conn
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func newConnListener() *connListener {
+	return &connListener{conns: make(chan net.Conn), closed: make(chan struct{})}
+}
+
+func (l *connListener) push(c net.Conn) bool {
+	select {
+	case l.conns <- c:
+		return true
+	case <-l.closed:
+		return false
+	}
+}
+
+func (l *connListener) Accept() (net.Conn, error) {
+	select {
+	case c := <-l.conns:
+		return c, nil
+	case <-l.closed:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *connListener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *connListener) Addr() net.Addr {
+	return &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1)}
+}
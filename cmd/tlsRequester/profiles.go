@@ -0,0 +1,39 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/profiles"
+)
+
+// runProfiles lists the built-in profiles or prints one as a config file.
+func runProfiles(args []string) {
+	if len(args) == 0 {
+		args = []string{"list"}
+	}
+
+	switch args[0] {
+	case "list":
+		for _, p := range profiles.List() {
+			fmt.Printf("%-20s %s\n", p.Name, p.Description)
+		}
+	case "show":
+		if len(args) != 2 {
+			fmt.Fprintln(os.Stderr, "Usage: tlsRequester profiles show <name>")
+			os.Exit(1)
+		}
+		p, err := profiles.Get(args[1])
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(1)
+		}
+		cfg := config.Default()
+		p.Apply(cfg)
+		writeConfig(cfg, "")
+	default:
+		fmt.Fprintln(os.Stderr, "Usage: tlsRequester profiles [list | show <name>]")
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/profiles"
+	"fingerPrintRequester/internal/requester"
+)
+
+// runServe exposes the requester as a local HTTP API backed by one shared
+// connection pool:
+//
+//	POST /request          body is a stdin-mode request; the upstream
+//	                       response is streamed back as it arrives
+//	GET  /health           liveness check
+//	GET  /profiles         built-in profiles
+//	GET  /profiles/{name}  a profile as a complete config file
+//
+// POST /request only takes application/json, which a web page cannot send
+// cross-origin without a preflight, and requests may not name local files
+// unless -allow-files is given.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	listen := fs.String("listen", "127.0.0.1:8080", "Address to listen on")
+	configPath := fs.String("c", "", "Config file for requests without config_path or profile (default config.json)")
+	profile := fs.String("profile", "", "Built-in profile for requests without config_path or profile")
+	token := fs.String("token", os.Getenv("TLS_REQUESTER_TOKEN"), "Bearer token POST /request must carry (default $TLS_REQUESTER_TOKEN)")
+	allowFiles := fs.Bool("allow-files", false, "Let requests name local files (config_path, cookie_jar, proxy and tls file paths)")
+	fs.Parse(args)
+
+	s := &server{session: newSession(*configPath, *profile), token: *token, allowFiles: *allowFiles}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /request", s.handleRequest)
+	mux.HandleFunc("GET /health", s.handleHealth)
+	mux.HandleFunc("GET /profiles", s.handleProfiles)
+	mux.HandleFunc("GET /profiles/{name}", s.handleProfile)
+	srv := &http.Server{Addr: *listen, Handler: mux}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		srv.Shutdown(context.Background())
+	}()
+
+	fmt.Fprintf(os.Stderr, "TLS Requester v%s listening on http://%s\n", version, *listen)
+	if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	s.close()
+}
+
+type server struct {
+	*session
+	token      string
+	allowFiles bool
+}
+
+// hopHeaders describe the upstream connection, not the response, and are
+// not copied to the API response.
+var hopHeaders = []string{"Connection", "Keep-Alive", "Proxy-Connection", "Transfer-Encoding", "Upgrade", "Trailer"}
+
+func (s *server) handleRequest(w http.ResponseWriter, r *http.Request) {
+	if s.token != "" {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(s.token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeJSON(w, http.StatusUnauthorized, map[string]interface{}{"success": false, "error": "missing or wrong bearer token", "error_type": "AUTH_ERROR"})
+			return
+		}
+	}
+	if mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type")); mediaType != "application/json" {
+		writeJSON(w, http.StatusUnsupportedMediaType, map[string]interface{}{"success": false, "error": "Content-Type must be application/json", "error_type": "INPUT_ERROR"})
+		return
+	}
+
+	var req config.Request
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeAPIError(w, "INPUT_ERROR", fmt.Sprintf("failed to parse request: %v", err))
+		return
+	}
+	if files := requestFiles(&req); len(files) > 0 && !s.allowFiles {
+		writeJSON(w, http.StatusForbidden, map[string]interface{}{"success": false, "error": fmt.Sprintf("request names local files (%s); start serve with -allow-files to permit it", strings.Join(files, ", ")), "error_type": "INPUT_ERROR"})
+		return
+	}
+	cfg, err := s.config(&req)
+	if err != nil {
+		writeAPIError(w, "CONFIG_ERROR", fmt.Sprintf("failed to load config: %v", err))
+		return
+	}
+
+	timer := requester.NewTimer()
+	resp, err := s.do(requester.WithTimer(r.Context(), timer), &req, cfg)
+	if err != nil {
+		errType, _ := requester.ClassifyError(err)
+		writeAPIError(w, errType, err.Error())
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("X-Upstream-Proto", resp.Proto)
+	w.Header().Set("X-Upstream-Addr", timer.RemoteAddr())
+	if proxy := timer.Proxy(); proxy != "" {
+		w.Header().Set("X-Upstream-Proxy", proxy)
+	}
+	if chain := requester.RedirectChain(resp); len(chain) > 0 {
+		w.Header().Set("X-Upstream-Url", resp.Request.URL.String())
+		for _, r := range chain {
+			w.Header().Add("X-Upstream-Redirect", fmt.Sprintf("%d %s", r.Status, r.URL))
+		}
+	}
+	streamResponse(w, resp)
+}
+
+// requestFiles lists the fields of req that name local files, which would
+// let an API caller read or, through cookie_jar, overwrite them.
+func requestFiles(req *config.Request) []string {
+	var files []string
+	add := func(field, path string) {
+		if path != "" {
+			files = append(files, field)
+		}
+	}
+	add("config_path", req.ConfigPath)
+	add("cookie_jar", req.CookieJar)
+	if req.Proxy != nil {
+		add("proxy.file", req.Proxy.File)
+		add("proxy.ca_file", req.Proxy.CAFile)
+	}
+	if req.TLS != nil {
+		add("tls.ca_file", req.TLS.CAFile)
+		add("tls.cert_file", req.TLS.CertFile)
+		add("tls.key_file", req.TLS.KeyFile)
+	}
+	return files
+}
+
+// streamResponse copies an upstream response to w, flushing each chunk as
+// it arrives.
+func streamResponse(w http.ResponseWriter, resp *http.Response) {
+	for k, vv := range resp.Header {
+		w.Header()[k] = vv
+	}
+	for _, k := range hopHeaders {
+		w.Header().Del(k)
+	}
+	delete(w.Header(), requester.HeaderOrderKey)
+	w.WriteHeader(resp.StatusCode)
+
+	// Stream body chunk by chunk
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 8192)
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return // client went away; closing the body cancels upstream
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return
+		}
+		if err != nil {
+			// Headers are already out; abort so the client sees a broken
+			// response rather than a short one.
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+func (s *server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{"status": "ok", "version": version})
+}
+
+func (s *server) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	list := []map[string]string{}
+	for _, p := range profiles.List() {
+		list = append(list, map[string]string{"name": p.Name, "description": p.Description})
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *server) handleProfile(w http.ResponseWriter, r *http.Request) {
+	p, err := profiles.Get(r.PathValue("name"))
+	if err != nil {
+		writeJSON(w, http.StatusNotFound, map[string]interface{}{"success": false, "error": err.Error(), "error_type": "CONFIG_ERROR"})
+		return
+	}
+	cfg := config.Default()
+	p.Apply(cfg)
+	writeJSON(w, http.StatusOK, cfg)
+}
+
+// writeAPIError answers with the same JSON error object stdin mode prints.
+func writeAPIError(w http.ResponseWriter, errType, msg string) {
+	status := http.StatusBadGateway
+	switch errType {
+	case "INPUT_ERROR", "CONFIG_ERROR":
+		status = http.StatusBadRequest
+	case "TIMEOUT_ERROR":
+		status = http.StatusGatewayTimeout
+	}
+	writeJSON(w, status, map[string]interface{}{"success": false, "error": msg, "error_type": errType})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
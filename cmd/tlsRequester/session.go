@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"sync"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/requester"
+)
+
+// session is the state a long-running mode shares across requests: the
+// connection pool and the configs loaded so far.
+type session struct {
+	pool *requester.Pool
+
+	// Used for requests that name neither a config file nor a profile
+	defaultConfigPath string
+	defaultProfile    string
+
+	configMu sync.Mutex
+	configs  map[string]*config.Config
+}
+
+func newSession(configPath, profile string) *session {
+	if configPath == "" && profile == "" {
+		configPath = "config.json"
+	}
+	return &session{
+		pool:              requester.NewPool(),
+		defaultConfigPath: configPath,
+		defaultProfile:    profile,
+		configs:           map[string]*config.Config{},
+	}
+}
+
+// config returns a private copy of the config req asks for, with its
+// overrides applied. Each config file / profile pair is loaded once.
+func (s *session) config(req *config.Request) (*config.Config, error) {
+	if req.ConfigPath == "" && req.Profile == "" {
+		req.ConfigPath, req.Profile = s.defaultConfigPath, s.defaultProfile
+	}
+
+	s.configMu.Lock()
+	key := req.ConfigPath + "|" + req.Profile
+	cfg, ok := s.configs[key]
+	if !ok {
+		var err error
+		if cfg, err = loadConfig(req.ConfigPath, req.Profile); err != nil {
+			s.configMu.Unlock()
+			return nil, err
+		}
+		s.configs[key] = cfg
+	}
+	s.configMu.Unlock()
+
+	c := *cfg
+	applyRequestOverrides(&c, req)
+	return &c, nil
+}
+
+func (s *session) do(ctx context.Context, req *config.Request, cfg *config.Config) (*http.Response, error) {
+	return s.pool.Do(ctx, req, cfg)
+}
+
+// doStream is do with body streamed upstream in place of req.Body.
+func (s *session) doStream(ctx context.Context, req *config.Request, body io.ReadCloser, contentLength int64, cfg *config.Config) (*http.Response, error) {
+	return s.pool.DoStream(ctx, req, body, contentLength, cfg)
+}
+
+func (s *session) close() {
+	s.pool.Close()
+}
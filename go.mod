@@ -3,14 +3,13 @@ module fingerPrintRequester
 go 1.24
 
 require (
+	github.com/andybalholm/brotli v1.0.6
+	github.com/klauspost/compress v1.17.4
 	github.com/refraction-networking/utls v1.8.1
+	golang.org/x/crypto v0.36.0
 	golang.org/x/net v0.38.0
+	golang.org/x/sys v0.31.0
+	software.sslmate.com/src/go-pkcs12 v0.7.3
 )
 
-require (
-	github.com/andybalholm/brotli v1.0.6 // indirect
-	github.com/klauspost/compress v1.17.4 // indirect
-	golang.org/x/crypto v0.36.0 // indirect
-	golang.org/x/sys v0.31.0 // indirect
-	golang.org/x/text v0.23.0 // indirect
-)
+require golang.org/x/text v0.23.0 // indirect
@@ -5,6 +5,23 @@ import (
 	"os"
 )
 
+// Default returns the settings used when a request names a profile but no
+// config file, matching the bundled config files.
+func Default() *Config {
+	return &Config{
+		Timeout: TimeoutConfig{
+			Connect: 30,
+			Read:    60,
+		},
+		Proxy: ProxyConfig{
+			Type: "http",
+		},
+		DNS: DNSConfig{
+			Servers: []string{},
+		},
+	}
+}
+
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
@@ -14,5 +31,13 @@ func LoadConfig(path string) (*Config, error) {
 	if err := json.Unmarshal(data, &cfg); err != nil {
 		return nil, err
 	}
+	if err := cfg.HTTP2.Validate(); err != nil {
+		return nil, err
+	}
+	if cfg.Proxy.URL != "" {
+		if _, _, err := cfg.Proxy.Endpoint(); err != nil {
+			return nil, err
+		}
+	}
 	return &cfg, nil
 }
@@ -0,0 +1,32 @@
+package config
+
+import "fmt"
+
+// defaultDNSCacheTTL is CacheTTL when it is not set.
+const defaultDNSCacheTTL = 60
+
+// Network returns the address family IPVersion allows, as a net package
+// network name: ip (both), ip4 or ip6.
+func (d *DNSConfig) Network() (string, error) {
+	switch d.IPVersion {
+	case "", "auto":
+		return "ip", nil
+	case "4":
+		return "ip4", nil
+	case "6":
+		return "ip6", nil
+	}
+	return "", fmt.Errorf("invalid dns ip_version %q (want 4, 6 or auto)", d.IPVersion)
+}
+
+// SystemCacheTTL returns how many seconds to cache system resolver
+// answers; zero means not at all.
+func (d *DNSConfig) SystemCacheTTL() int {
+	switch {
+	case d.CacheTTL < 0:
+		return 0
+	case d.CacheTTL == 0:
+		return defaultDNSCacheTTL
+	}
+	return d.CacheTTL
+}
@@ -0,0 +1,145 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/net/http/httpguts"
+)
+
+// Header is one request header as sent on the wire.
+type Header struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Headers is an ordered header list; duplicates are allowed. In JSON it may
+// be an object (key order is kept), or an array of [name, value] pairs,
+// "Name: value" strings or {"name", "value"} objects.
+type Headers []Header
+
+// Get returns the first value for name, compared case-insensitively.
+func (h Headers) Get(name string) string {
+	for _, hdr := range h {
+		if strings.EqualFold(hdr.Name, name) {
+			return hdr.Value
+		}
+	}
+	return ""
+}
+
+// CheckHeaderName rejects a header name that is not an HTTP token, such as
+// one carrying CR/LF to smuggle in more headers.
+func CheckHeaderName(name string) error {
+	if !httpguts.ValidHeaderFieldName(name) {
+		return fmt.Errorf("invalid header name %q", name)
+	}
+	return nil
+}
+
+// ParseHeaderLine splits a curl-style "Name: value" line.
+func ParseHeaderLine(line string) (Header, error) {
+	name, value, ok := strings.Cut(line, ":")
+	if !ok || strings.TrimSpace(name) == "" {
+		return Header{}, fmt.Errorf("invalid header %q, want \"Name: value\"", line)
+	}
+	name = strings.TrimSpace(name)
+	if err := CheckHeaderName(name); err != nil {
+		return Header{}, err
+	}
+	return Header{Name: name, Value: strings.TrimSpace(value)}, nil
+}
+
+func (h *Headers) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.Equal(data, []byte("null")) {
+		*h = nil
+		return nil
+	}
+	if len(data) > 0 && data[0] == '{' {
+		return h.unmarshalObject(data)
+	}
+
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return fmt.Errorf("headers must be an object or an array: %v", err)
+	}
+	out := make(Headers, 0, len(items))
+	for _, item := range items {
+		var line string
+		var pair []string
+		var obj Header
+		switch {
+		case json.Unmarshal(item, &line) == nil:
+			hdr, err := ParseHeaderLine(line)
+			if err != nil {
+				return err
+			}
+			out = append(out, hdr)
+		case json.Unmarshal(item, &pair) == nil:
+			if len(pair) != 2 {
+				return fmt.Errorf("header pair must be [name, value], got %d items", len(pair))
+			}
+			out = append(out, Header{Name: pair[0], Value: pair[1]})
+		case json.Unmarshal(item, &obj) == nil && obj.Name != "":
+			out = append(out, obj)
+		default:
+			return fmt.Errorf("invalid header entry: %s", item)
+		}
+	}
+	for _, hdr := range out {
+		if err := CheckHeaderName(hdr.Name); err != nil {
+			return err
+		}
+	}
+	*h = out
+	return nil
+}
+
+// unmarshalObject walks the object token by token so key order survives.
+// A value may be a string or an array of strings (repeated header).
+func (h *Headers) unmarshalObject(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.Token() // {
+	out := Headers{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		name := tok.(string)
+		if err := CheckHeaderName(name); err != nil {
+			return err
+		}
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return err
+		}
+		var value string
+		var values []string
+		switch {
+		case json.Unmarshal(raw, &value) == nil:
+			out = append(out, Header{Name: name, Value: value})
+		case json.Unmarshal(raw, &values) == nil:
+			for _, v := range values {
+				out = append(out, Header{Name: name, Value: v})
+			}
+		default:
+			return fmt.Errorf("header %s: value must be a string or an array of strings", name)
+		}
+	}
+	*h = out
+	return nil
+}
+
+// MarshalJSON writes [name, value] pairs, the one form that keeps both order
+// and duplicates.
+func (h Headers) MarshalJSON() ([]byte, error) {
+	pairs := make([][2]string, len(h))
+	for i, hdr := range h {
+		pairs[i] = [2]string{hdr.Name, hdr.Value}
+	}
+	return json.Marshal(pairs)
+}
@@ -0,0 +1,128 @@
+package config
+
+import "fmt"
+
+// HTTP2Config controls the frames that make up the Akamai HTTP/2
+// fingerprint. Unset parts fall back to DefaultHTTP2.
+type HTTP2Config struct {
+	// Settings are sent in the initial SETTINGS frame, in this order.
+	Settings []HTTP2Setting `json:"settings,omitempty"`
+	// WindowUpdate is the connection-level WINDOW_UPDATE increment sent
+	// right after SETTINGS. Nil takes the default; 0 sends no WINDOW_UPDATE
+	// frame, the way some clients open their connections.
+	WindowUpdate *uint32 `json:"window_update,omitempty"`
+	// Priorities are PRIORITY frames sent after the WINDOW_UPDATE (the way
+	// older Firefox builds its dependency tree).
+	Priorities []HTTP2Priority `json:"priorities,omitempty"`
+	// HeaderPriority, if set, is carried in every request HEADERS frame.
+	HeaderPriority *HTTP2PriorityParam `json:"header_priority,omitempty"`
+}
+
+// HTTP2Setting is one SETTINGS entry: 1 HEADER_TABLE_SIZE, 2 ENABLE_PUSH,
+// 3 MAX_CONCURRENT_STREAMS, 4 INITIAL_WINDOW_SIZE, 5 MAX_FRAME_SIZE,
+// 6 MAX_HEADER_LIST_SIZE, 8 ENABLE_CONNECT_PROTOCOL, 9 NO_RFC7540_PRIORITIES.
+type HTTP2Setting struct {
+	ID    uint16 `json:"id"`
+	Value uint32 `json:"value"`
+}
+
+type HTTP2Priority struct {
+	StreamID uint32 `json:"stream_id"`
+	HTTP2PriorityParam
+}
+
+// HTTP2PriorityParam uses the 1-256 weight browsers and Akamai report;
+// the wire value is Weight-1.
+type HTTP2PriorityParam struct {
+	DependsOn uint32 `json:"depends_on"`
+	Weight    uint16 `json:"weight"`
+	Exclusive bool   `json:"exclusive"`
+}
+
+// DefaultHTTP2 is what golang.org/x/net/http2.Transport sends, so configs
+// without an http2 section keep the fingerprint they had before.
+var DefaultHTTP2 = HTTP2Config{
+	Settings: []HTTP2Setting{
+		{ID: 2, Value: 0},
+		{ID: 4, Value: 4 << 20},
+		{ID: 5, Value: 1 << 20},
+		{ID: 6, Value: 10 << 20},
+	},
+	WindowUpdate: &defaultWindowUpdate,
+}
+
+var defaultWindowUpdate uint32 = 1 << 30
+
+// maxWindowUpdate is the largest connection WINDOW_UPDATE that keeps the
+// window, which starts at 65535, within 2^31-1 (RFC 9113 6.9.1).
+const maxWindowUpdate = 1<<31 - 1 - 65535
+
+// DefaultPseudoHeaderOrder is the pseudo-header order of x/net/http2.
+var DefaultPseudoHeaderOrder = []string{":authority", ":method", ":path", ":scheme"}
+
+// WithDefaults returns c with unset parts taken from DefaultHTTP2.
+func (c HTTP2Config) WithDefaults() HTTP2Config {
+	if c.Settings == nil {
+		c.Settings = DefaultHTTP2.Settings
+	}
+	if c.WindowUpdate == nil {
+		c.WindowUpdate = DefaultHTTP2.WindowUpdate
+	}
+	return c
+}
+
+// Setting returns the value sent for id, or def if it is not sent.
+func (c HTTP2Config) Setting(id uint16, def uint32) uint32 {
+	for _, s := range c.Settings {
+		if s.ID == id {
+			return s.Value
+		}
+	}
+	return def
+}
+
+// Validate checks the flow-control windows against the 2^31-1 limit of
+// RFC 9113 and the priority weights, which go on the wire as Weight-1 in a
+// single byte.
+func (c HTTP2Config) Validate() error {
+	if c.WindowUpdate != nil && *c.WindowUpdate > maxWindowUpdate {
+		return fmt.Errorf("http2.window_update %d is above %d", *c.WindowUpdate, maxWindowUpdate)
+	}
+	if v := c.Setting(4, 0); v > 1<<31-1 {
+		return fmt.Errorf("http2.settings INITIAL_WINDOW_SIZE %d is above %d", v, 1<<31-1)
+	}
+	for _, p := range c.Priorities {
+		if err := p.validate(); err != nil {
+			return fmt.Errorf("http2.priorities stream %d: %v", p.StreamID, err)
+		}
+	}
+	if c.HeaderPriority != nil {
+		if err := c.HeaderPriority.validate(); err != nil {
+			return fmt.Errorf("http2.header_priority: %v", err)
+		}
+	}
+	return nil
+}
+
+func (p HTTP2PriorityParam) validate() error {
+	if p.Weight < 1 || p.Weight > 256 {
+		return fmt.Errorf("weight %d is outside 1-256", p.Weight)
+	}
+	return nil
+}
+
+// PseudoOrder completes order with the pseudo-headers it leaves out, in
+// DefaultPseudoHeaderOrder, and drops anything that is not one of them.
+func PseudoOrder(order []string) []string {
+	out := []string{}
+	seen := map[string]bool{}
+	for _, name := range append(append([]string{}, order...), DefaultPseudoHeaderOrder...) {
+		for _, known := range DefaultPseudoHeaderOrder {
+			if name == known && !seen[name] {
+				seen[name] = true
+				out = append(out, name)
+			}
+		}
+	}
+	return out
+}
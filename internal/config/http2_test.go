@@ -0,0 +1,50 @@
+package config
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestHTTP2Validate(t *testing.T) {
+	window := func(v uint32) *uint32 { return &v }
+	tests := []struct {
+		name string
+		cfg  HTTP2Config
+		err  string
+	}{
+		{"defaults", DefaultHTTP2, ""},
+		{"no WINDOW_UPDATE", HTTP2Config{WindowUpdate: window(0)}, ""},
+		{"largest window_update", HTTP2Config{WindowUpdate: window(1<<31 - 1 - 65535)}, ""},
+		{"window_update overflows", HTTP2Config{WindowUpdate: window(1<<31 - 65535)}, "http2.window_update"},
+		{"largest INITIAL_WINDOW_SIZE", HTTP2Config{Settings: []HTTP2Setting{{ID: 4, Value: 1<<31 - 1}}}, ""},
+		{"INITIAL_WINDOW_SIZE overflows", HTTP2Config{Settings: []HTTP2Setting{{ID: 4, Value: 1 << 31}}}, "INITIAL_WINDOW_SIZE"},
+		{"weight 0", HTTP2Config{Priorities: []HTTP2Priority{{StreamID: 3, HTTP2PriorityParam: HTTP2PriorityParam{Weight: 0}}}}, "stream 3"},
+		{"weight 257", HTTP2Config{HeaderPriority: &HTTP2PriorityParam{Weight: 257}}, "header_priority"},
+	}
+	for _, tt := range tests {
+		err := tt.cfg.Validate()
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestHTTP2WindowUpdateOmitted(t *testing.T) {
+	for in, want := range map[string]uint32{
+		`{}`:                       1 << 30,
+		`{"window_update": 0}`:     0,
+		`{"window_update": 12345}`: 12345,
+	} {
+		var c HTTP2Config
+		if err := json.Unmarshal([]byte(in), &c); err != nil {
+			t.Fatal(err)
+		}
+		if got := *c.WithDefaults().WindowUpdate; got != want {
+			t.Errorf("%s: window_update %d, want %d", in, got, want)
+		}
+	}
+}
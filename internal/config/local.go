@@ -0,0 +1,46 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// LocalAddresses are IP addresses or interface names to make connections
+// from. JSON accepts a single string or an array.
+type LocalAddresses []string
+
+func (l *LocalAddresses) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if len(data) > 0 && data[0] == '"' {
+		var s string
+		if err := json.Unmarshal(data, &s); err != nil {
+			return err
+		}
+		*l = LocalAddresses{s}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("local_address must be a string or an array: %v", err)
+	}
+	*l = list
+	return nil
+}
+
+// Local address rotations: how each connection picks from LocalAddress.
+const (
+	RotateRoundRobin = "round_robin" // in turn, per process (default)
+	RotateRandom     = "random"
+)
+
+// Rotation returns LocalRotation, checked, with the default filled in.
+func (c *Config) Rotation() (string, error) {
+	switch c.LocalRotation {
+	case "", RotateRoundRobin:
+		return RotateRoundRobin, nil
+	case RotateRandom:
+		return RotateRandom, nil
+	}
+	return "", fmt.Errorf("invalid local_rotation %q (want round_robin or random)", c.LocalRotation)
+}
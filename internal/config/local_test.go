@@ -0,0 +1,43 @@
+package config
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+)
+
+func TestLocalAddressesUnmarshal(t *testing.T) {
+	tests := []struct {
+		in   string
+		want LocalAddresses // nil for an error
+	}{
+		{`"192.0.2.1"`, LocalAddresses{"192.0.2.1"}},
+		{` "eth0"`, LocalAddresses{"eth0"}},
+		{`["192.0.2.1", "2001:db8::1", "eth0"]`, LocalAddresses{"192.0.2.1", "2001:db8::1", "eth0"}},
+		{`[]`, LocalAddresses{}},
+		{`1`, nil},
+		{`{"ip": "192.0.2.1"}`, nil},
+	}
+	for _, tt := range tests {
+		var cfg Config
+		err := json.Unmarshal([]byte(`{"local_address": `+tt.in+`}`), &cfg)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%s: got %q, want an error", tt.in, cfg.LocalAddress)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(cfg.LocalAddress, tt.want) {
+			t.Errorf("%s: got %q, %v; want %q", tt.in, cfg.LocalAddress, err, tt.want)
+		}
+	}
+}
+
+func TestRotation(t *testing.T) {
+	for in, want := range map[string]string{"": RotateRoundRobin, "round_robin": RotateRoundRobin, "random": RotateRandom, "Random": ""} {
+		got, err := (&Config{LocalRotation: in}).Rotation()
+		if got != want || (err != nil) != (want == "") {
+			t.Errorf("%q: got %q, %v; want %q", in, got, err, want)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// proxyTypes are the values ProxyConfig.Type and proxy URL schemes accept.
+var proxyTypes = map[string]bool{
+	"http":    true,
+	"https":   true, // TLS to the proxy, then CONNECT
+	"socks4":  true, // target resolved locally
+	"socks4a": true, // target resolved by the proxy
+	"socks5":  true, // target resolved locally
+	"socks5h": true, // target resolved by the proxy
+}
+
+// Endpoint returns the proxy type and URL. Without a Type, the URL scheme
+// gives it, so "socks5h://host:1080" needs no type; a URL without a scheme
+// uses Type, or http when Type is empty. A Type the scheme contradicts is
+// an error rather than a silent switch of protocol.
+func (p *ProxyConfig) Endpoint() (string, *url.URL, error) {
+	typ := strings.ToLower(p.Type)
+	raw := p.URL
+	if !strings.Contains(raw, "://") {
+		scheme := typ
+		if scheme == "" {
+			scheme = "http"
+		}
+		raw = scheme + "://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid proxy URL: %v", err)
+	}
+	scheme := strings.ToLower(u.Scheme)
+	if typ != "" && typ != scheme {
+		return "", nil, fmt.Errorf("proxy type %q does not match the %s:// scheme of its URL", typ, scheme)
+	}
+	typ = scheme
+	if !proxyTypes[typ] {
+		return "", nil, fmt.Errorf("unsupported proxy type %q", typ)
+	}
+	if u.Port() == "" {
+		port := "1080"
+		switch typ {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		}
+		u.Host = u.Host + ":" + port
+	}
+	return typ, u, nil
+}
+
+// Proxy pool strategies, see ProxyConfig.Strategy.
+const (
+	ProxyRoundRobin    = "round_robin"
+	ProxyRandom        = "random"
+	ProxyWeighted      = "weighted"
+	ProxyStickyHost    = "sticky_host"
+	ProxyStickySession = "sticky_session"
+)
+
+// IsPool reports whether p picks from several proxies rather than URL.
+func (p *ProxyConfig) IsPool() bool {
+	return len(p.URLs) > 0 || p.File != ""
+}
+
+// PoolStrategy returns Strategy, checked, with the default filled in.
+func (p *ProxyConfig) PoolStrategy() (string, error) {
+	switch p.Strategy {
+	case "":
+		return ProxyRoundRobin, nil
+	case ProxyRoundRobin, ProxyRandom, ProxyWeighted, ProxyStickyHost:
+		return p.Strategy, nil
+	case ProxyStickySession:
+		if p.SessionKey == "" {
+			return "", fmt.Errorf("proxy strategy sticky_session needs a session_key")
+		}
+		return p.Strategy, nil
+	}
+	return "", fmt.Errorf("unsupported proxy strategy %q", p.Strategy)
+}
+
+// Single returns p with its pool replaced by the one proxy rawURL.
+func (p *ProxyConfig) Single(rawURL string) ProxyConfig {
+	single := *p
+	single.URL = rawURL
+	single.URLs, single.File = nil, ""
+	single.Strategy, single.SessionKey = "", ""
+	single.MaxFailures, single.Quarantine = 0, 0
+	return single
+}
@@ -0,0 +1,206 @@
+package cookies
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Open loads the jar stored in path. A missing file gives an empty jar
+// that Save creates. The format is detected from the content: a JSON array
+// of Cookie, or Netscape cookies.txt as written by curl and browsers' export
+// tools. New files are JSON if path ends in .json, Netscape otherwise.
+func Open(path string) (*Jar, error) {
+	j := New()
+	j.path = path
+	j.json = strings.EqualFold(filepath.Ext(path), ".json")
+
+	cookies, isJSON, err := readFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return j, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.json = isJSON
+	for i := range cookies {
+		j.cookies[cookies[i].key()] = &cookies[i]
+	}
+	return j, nil
+}
+
+// Save writes the jar back to its file. Cookies other processes stored
+// since Open are kept unless this jar changed the same cookie, so
+// concurrent processes do not drop each other's cookies. The read, merge
+// and write happen under a lock on a ".lock" file next to the jar, so
+// saves from several processes do not interleave.
+func (j *Jar) Save() error {
+	if j.path == "" {
+		return errors.New("cookie jar has no file")
+	}
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	unlock, err := lockFile(j.path)
+	if err != nil {
+		return fmt.Errorf("failed to lock cookie jar: %v", err)
+	}
+	defer unlock()
+
+	current, _, err := readFile(j.path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	merged := map[string]*Cookie{}
+	for i := range current {
+		merged[current[i].key()] = &current[i]
+	}
+	for key := range j.changed {
+		if c, ok := j.cookies[key]; ok {
+			merged[key] = c
+		} else {
+			delete(merged, key)
+		}
+	}
+
+	now := time.Now()
+	list := make([]Cookie, 0, len(merged))
+	for _, c := range merged {
+		if !c.expired(now) {
+			list = append(list, *c)
+		}
+	}
+	sortCookies(list)
+
+	var data []byte
+	if j.json {
+		if data, err = json.MarshalIndent(list, "", "  "); err != nil {
+			return err
+		}
+		data = append(data, '\n')
+	} else {
+		data = marshalNetscape(list)
+	}
+	if err := writeFileAtomic(j.path, data); err != nil {
+		return err
+	}
+
+	j.cookies = merged
+	j.changed = map[string]bool{}
+	return nil
+}
+
+func readFile(path string) ([]Cookie, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false, err
+	}
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '[' {
+		var cookies []Cookie
+		if err := json.Unmarshal(trimmed, &cookies); err != nil {
+			return nil, true, fmt.Errorf("invalid cookie jar %s: %v", path, err)
+		}
+		return cookies, true, nil
+	}
+	cookies, err := parseNetscape(data)
+	if err != nil {
+		return nil, false, fmt.Errorf("invalid cookie jar %s: %v", path, err)
+	}
+	return cookies, false, nil
+}
+
+// writeFileAtomic replaces path so readers never see a partial file. The
+// file is only readable by its owner: session cookies are credentials.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	// CreateTemp already uses 0600; make sure before any cookie is written
+	if err := tmp.Chmod(0600); err != nil {
+		tmp.Close()
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+const httpOnlyPrefix = "#HttpOnly_"
+
+// parseNetscape reads the tab-separated cookies.txt format: domain,
+// include-subdomains, path, secure, expiry (unix seconds, 0 for session),
+// name and value. "#HttpOnly_" before the domain marks HttpOnly cookies.
+func parseNetscape(data []byte) ([]Cookie, error) {
+	var cookies []Cookie
+	created := time.Now()
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		line = strings.TrimPrefix(line, httpOnlyPrefix)
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("line %d: want 7 tab-separated fields, got %d", lineNo, len(fields))
+		}
+		expiry, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: invalid expiry %q", lineNo, fields[4])
+		}
+		c := Cookie{
+			Domain:   strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			HostOnly: !strings.EqualFold(fields[1], "TRUE"),
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    strings.Join(fields[6:], "\t"),
+			HTTPOnly: httpOnly,
+			// The format has no creation time; keep the file order.
+			Created: created.Add(time.Duration(len(cookies))),
+		}
+		if expiry > 0 {
+			c.Expires = time.Unix(expiry, 0).UTC()
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, scanner.Err()
+}
+
+func marshalNetscape(cookies []Cookie) []byte {
+	var b bytes.Buffer
+	b.WriteString("# Netscape HTTP Cookie File\n")
+	b.WriteString("# This file was generated by tlsRequester.\n\n")
+	for _, c := range cookies {
+		domain, sub := c.Domain, "FALSE"
+		if !c.HostOnly {
+			domain, sub = "."+c.Domain, "TRUE"
+		}
+		if c.HTTPOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expiry int64
+		if !c.Expires.IsZero() {
+			expiry = c.Expires.Unix()
+		}
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", domain, sub, c.Path, strings.ToUpper(strconv.FormatBool(c.Secure)), expiry, c.Name, c.Value)
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,200 @@
+package cookies
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fill stores a mix of cookie kinds in j.
+func fill(t *testing.T, j *Jar) {
+	t.Helper()
+	expires := time.Now().Add(24 * time.Hour).Truncate(time.Second)
+	u, _ := url.Parse("https://www.example.com/app/login")
+	j.SetCookies(u, []*http.Cookie{
+		{Name: "session", Value: "abc123"},
+		{Name: "pref", Value: "dark", Domain: "example.com", Path: "/", Expires: expires},
+		{Name: "token", Value: "x=y;z", Path: "/app", Secure: true, HttpOnly: true, Expires: expires},
+		{Name: "lax", Value: "1", SameSite: http.SameSiteLaxMode},
+	})
+	if n := len(j.All()); n != 4 {
+		t.Fatalf("stored %d cookies, want 4", n)
+	}
+}
+
+// same compares the fields a jar file keeps. Netscape files have no
+// SameSite or creation time.
+func same(a, b Cookie, netscape bool) bool {
+	if netscape {
+		a.SameSite, b.SameSite = "", ""
+		a.Created, b.Created = time.Time{}, time.Time{}
+	}
+	return a.Name == b.Name && a.Value == b.Value && a.Domain == b.Domain && a.Path == b.Path &&
+		a.HostOnly == b.HostOnly && a.Secure == b.Secure && a.HTTPOnly == b.HTTPOnly &&
+		a.SameSite == b.SameSite && a.Expires.Equal(b.Expires) && a.Created.Equal(b.Created)
+}
+
+func TestSaveOpenRoundTrip(t *testing.T) {
+	for _, name := range []string{"cookies.json", "cookies.txt"} {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			j, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			fill(t, j)
+			if err := j.Save(); err != nil {
+				t.Fatal(err)
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			netscape := name == "cookies.txt"
+			if isJSON := strings.HasPrefix(string(data), "["); isJSON == netscape {
+				t.Errorf("%s written in the wrong format:\n%s", name, data)
+			}
+			if fi, err := os.Stat(path); err == nil && runtime.GOOS != "windows" && fi.Mode().Perm() != 0600 {
+				t.Errorf("mode %v, want 0600", fi.Mode().Perm())
+			}
+
+			reopened, err := Open(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			want, got := j.All(), reopened.All()
+			if len(got) != len(want) {
+				t.Fatalf("reopened jar has %d cookies, want %d", len(got), len(want))
+			}
+			for i := range want {
+				if !same(got[i], want[i], netscape) {
+					t.Errorf("cookie %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestOpenNetscape(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json") // content decides the format
+	data := "# Netscape HTTP Cookie File\r\n" +
+		"# comment\n" +
+		"\n" +
+		".example.com\tTRUE\t/\tFALSE\t0\tsid\tone\n" +
+		"#HttpOnly_api.example.com\tFALSE\t/v1\tTRUE\t4102444800\tauth\ta\tb\r\n"
+	if err := os.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := j.All()
+	want := []Cookie{
+		{Name: "auth", Value: "a\tb", Domain: "api.example.com", Path: "/v1", HostOnly: true, Secure: true, HTTPOnly: true, Expires: time.Unix(4102444800, 0)},
+		{Name: "sid", Value: "one", Domain: "example.com", Path: "/"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %+v", got)
+	}
+	for i := range want {
+		if !same(got[i], want[i], true) {
+			t.Errorf("cookie %d:\ngot  %+v\nwant %+v", i, got[i], want[i])
+		}
+	}
+
+	// Saving keeps the format the file already had.
+	if err := j.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, _ := os.ReadFile(path); !strings.HasPrefix(string(saved), "# Netscape") {
+		t.Errorf("saved as:\n%s", saved)
+	}
+}
+
+func TestOpenInvalid(t *testing.T) {
+	for name, data := range map[string]string{
+		"short line":  "example.com\tFALSE\t/\tFALSE\t0\tname\n",
+		"bad expiry":  "example.com\tFALSE\t/\tFALSE\tsoon\tname\tvalue\n",
+		"broken JSON": `[{"name": "a"`,
+	} {
+		path := filepath.Join(t.TempDir(), "cookies.txt")
+		os.WriteFile(path, []byte(data), 0600)
+		if _, err := Open(path); err == nil {
+			t.Errorf("%s: opened without error", name)
+		}
+	}
+}
+
+func TestSaveMerges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.json")
+	u, _ := url.Parse("https://example.com/")
+
+	base, _ := Open(path)
+	base.SetCookies(u, []*http.Cookie{{Name: "keep", Value: "1"}, {Name: "drop", Value: "1"}})
+	if err := base.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	a, _ := Open(path)
+	b, _ := Open(path)
+	a.SetCookies(u, []*http.Cookie{{Name: "from_a", Value: "1"}, {Name: "drop", MaxAge: -1}})
+	b.SetCookies(u, []*http.Cookie{{Name: "from_b", Value: "1"}})
+	if err := a.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.Save(); err != nil {
+		t.Fatal(err)
+	}
+
+	merged, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := []string{}
+	for _, c := range merged.All() {
+		names = append(names, c.Name)
+	}
+	if got := strings.Join(names, ","); got != "from_a,from_b,keep" {
+		t.Errorf("merged jar holds %s, want from_a,from_b,keep", got)
+	}
+}
+
+func TestSaveConcurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	u, _ := url.Parse("https://example.com/")
+	const n = 20
+	var wg sync.WaitGroup
+	for i := range n {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			j, err := Open(path)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			j.SetCookies(u, []*http.Cookie{{Name: fmt.Sprintf("c%d", i), Value: "1"}})
+			if err := j.Save(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	j, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := len(j.All()); got != n {
+		t.Errorf("jar holds %d cookies after %d concurrent saves", got, n)
+	}
+}
@@ -0,0 +1,280 @@
+// Package cookies implements an RFC 6265 cookie jar that can be stored in
+// a file, so separate processes share one session.
+package cookies
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/publicsuffix"
+)
+
+// Cookie is a stored cookie (RFC 6265 section 5.3). A zero Expires marks a
+// session cookie; it is still written to the jar file.
+type Cookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain"`
+	Path     string    `json:"path"`
+	HostOnly bool      `json:"host_only,omitempty"`
+	Secure   bool      `json:"secure,omitempty"`
+	HTTPOnly bool      `json:"http_only,omitempty"`
+	SameSite string    `json:"same_site,omitempty"`
+	Expires  time.Time `json:"expires,omitzero"`
+	Created  time.Time `json:"created"`
+}
+
+func (c *Cookie) key() string {
+	return c.Domain + ";" + c.Path + ";" + c.Name
+}
+
+func (c *Cookie) expired(now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+// Jar is an http.CookieJar. It is safe for concurrent use.
+type Jar struct {
+	mu      sync.Mutex
+	cookies map[string]*Cookie
+	changed map[string]bool // keys set or removed since the file was read
+
+	path string
+	json bool
+}
+
+// New returns an empty jar not backed by a file.
+func New() *Jar {
+	return &Jar{cookies: map[string]*Cookie{}, changed: map[string]bool{}}
+}
+
+// SetCookies stores the cookies a response from u set, ignoring those the
+// origin may not set.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	host, ok := canonicalHost(u)
+	if !ok {
+		return
+	}
+	secure := u.Scheme == "https"
+	now := time.Now()
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for i, hc := range cookies {
+		// Offset creation times so cookies keep their response order.
+		c, remove, ok := newCookie(hc, host, u.Path, secure, now.Add(time.Duration(i)))
+		if !ok {
+			continue
+		}
+		key := c.key()
+		old := j.cookies[key]
+		// An insecure origin may not replace a Secure cookie (RFC 6265bis).
+		if old != nil && old.Secure && !secure {
+			continue
+		}
+		if remove {
+			if old != nil {
+				delete(j.cookies, key)
+				j.changed[key] = true
+			}
+			continue
+		}
+		if old != nil {
+			c.Created = old.Created
+		}
+		j.cookies[key] = c
+		j.changed[key] = true
+	}
+}
+
+// Cookies returns the cookies to send to u, longest path first and then
+// oldest first, as RFC 6265 section 5.4 recommends.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	host, ok := canonicalHost(u)
+	if !ok {
+		return nil
+	}
+	secure := u.Scheme == "https"
+	path := u.Path
+	if path == "" {
+		path = "/"
+	}
+	now := time.Now()
+
+	j.mu.Lock()
+	var selected []*Cookie
+	for key, c := range j.cookies {
+		if c.expired(now) {
+			delete(j.cookies, key)
+			j.changed[key] = true
+			continue
+		}
+		if c.Secure && !secure {
+			continue
+		}
+		if c.HostOnly && host != c.Domain || !c.HostOnly && !domainMatch(host, c.Domain) {
+			continue
+		}
+		if !pathMatch(path, c.Path) {
+			continue
+		}
+		selected = append(selected, c)
+	}
+	j.mu.Unlock()
+
+	sort.Slice(selected, func(a, b int) bool {
+		if len(selected[a].Path) != len(selected[b].Path) {
+			return len(selected[a].Path) > len(selected[b].Path)
+		}
+		return selected[a].Created.Before(selected[b].Created)
+	})
+	out := make([]*http.Cookie, len(selected))
+	for i, c := range selected {
+		out[i] = &http.Cookie{Name: c.Name, Value: c.Value}
+	}
+	return out
+}
+
+// All returns a copy of every unexpired cookie.
+func (j *Jar) All() []Cookie {
+	now := time.Now()
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	out := make([]Cookie, 0, len(j.cookies))
+	for _, c := range j.cookies {
+		if !c.expired(now) {
+			out = append(out, *c)
+		}
+	}
+	sortCookies(out)
+	return out
+}
+
+// newCookie applies RFC 6265 section 5.3 to a Set-Cookie from host. remove
+// reports a cookie that deletes any stored one; ok is false if it must be
+// ignored.
+func newCookie(hc *http.Cookie, host, requestPath string, secure bool, now time.Time) (c *Cookie, remove, ok bool) {
+	if hc.Name == "" {
+		return nil, false, false
+	}
+	c = &Cookie{
+		Name:     hc.Name,
+		Value:    hc.Value,
+		Path:     hc.Path,
+		Secure:   hc.Secure,
+		HTTPOnly: hc.HttpOnly,
+		Created:  now,
+	}
+	switch hc.SameSite {
+	case http.SameSiteLaxMode:
+		c.SameSite = "Lax"
+	case http.SameSiteStrictMode:
+		c.SameSite = "Strict"
+	case http.SameSiteNoneMode:
+		c.SameSite = "None"
+	}
+
+	domain := strings.TrimPrefix(strings.ToLower(hc.Domain), ".")
+	switch {
+	case domain == "":
+		c.Domain, c.HostOnly = host, true
+	case net.ParseIP(host) != nil:
+		if domain != host {
+			return nil, false, false
+		}
+		c.Domain, c.HostOnly = host, true
+	default:
+		// A public suffix may only be set by that host itself.
+		if ps, _ := publicsuffix.PublicSuffix(domain); ps == domain {
+			if domain != host {
+				return nil, false, false
+			}
+			c.Domain, c.HostOnly = host, true
+		} else if !domainMatch(host, domain) {
+			return nil, false, false
+		} else {
+			c.Domain = domain
+		}
+	}
+
+	if c.Path == "" || c.Path[0] != '/' {
+		c.Path = defaultPath(requestPath)
+	}
+
+	if c.Secure && !secure {
+		return nil, false, false
+	}
+	// Cookie name prefixes (RFC 6265bis section 4.1.3)
+	if strings.HasPrefix(c.Name, "__Secure-") && !c.Secure {
+		return nil, false, false
+	}
+	if strings.HasPrefix(c.Name, "__Host-") && (!c.Secure || !c.HostOnly || c.Path != "/") {
+		return nil, false, false
+	}
+
+	switch {
+	case hc.MaxAge < 0:
+		return c, true, true
+	case hc.MaxAge > 0:
+		c.Expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+	case !hc.Expires.IsZero():
+		if !hc.Expires.After(now) {
+			return c, true, true
+		}
+		c.Expires = hc.Expires.UTC()
+	}
+	return c, false, true
+}
+
+// canonicalHost is u's lowercase host name without port or trailing dot.
+func canonicalHost(u *url.URL) (string, bool) {
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	return host, host != ""
+}
+
+// domainMatch implements RFC 6265 section 5.1.3.
+func domainMatch(host, domain string) bool {
+	if host == domain {
+		return true
+	}
+	return strings.HasSuffix(host, "."+domain) && net.ParseIP(host) == nil
+}
+
+// pathMatch implements RFC 6265 section 5.1.4.
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+// defaultPath implements RFC 6265 section 5.1.4.
+func defaultPath(requestPath string) string {
+	if requestPath == "" || requestPath[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(requestPath, "/")
+	if i == 0 {
+		return "/"
+	}
+	return requestPath[:i]
+}
+
+func sortCookies(cs []Cookie) {
+	sort.Slice(cs, func(a, b int) bool {
+		if cs[a].Domain != cs[b].Domain {
+			return cs[a].Domain < cs[b].Domain
+		}
+		if cs[a].Path != cs[b].Path {
+			return cs[a].Path < cs[b].Path
+		}
+		return cs[a].Name < cs[b].Name
+	})
+}
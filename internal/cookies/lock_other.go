@@ -0,0 +1,9 @@
+//go:build !(linux || android || darwin || freebsd || netbsd || openbsd || dragonfly || windows)
+
+package cookies
+
+// lockFile is a no-op where no file locking is available; Save still
+// merges, but two processes saving at once may drop each other's cookies.
+func lockFile(path string) (func(), error) {
+	return func() {}, nil
+}
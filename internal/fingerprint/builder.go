@@ -28,7 +28,7 @@ func Build(cfg *config.FingerprintConfig, targetURL string) (*utls.ClientHelloSp
 		ciphers = append(ciphers, utils.GenerateGREASEValue())
 	}
 	for _, cipherName := range cfg.Ciphers {
-		if cipher, ok := CipherMap[cipherName]; ok {
+		if cipher, ok := LookupCipher(cipherName); ok {
 			ciphers = append(ciphers, cipher)
 		}
 	}
@@ -36,8 +36,10 @@ func Build(cfg *config.FingerprintConfig, targetURL string) (*utls.ClientHelloSp
 
 	// Build extensions
 	extensions := []utls.TLSExtension{}
+	names := []string{}
 	if cfg.GREASE {
 		extensions = append(extensions, &utls.UtlsGREASEExtension{})
+		names = append(names, "GREASE")
 	}
 	
 	for _, extCfg := range cfg.Extensions {
@@ -46,14 +48,20 @@ func Build(cfg *config.FingerprintConfig, targetURL string) (*utls.ClientHelloSp
 			continue
 		}
 		extensions = append(extensions, ext)
+		names = append(names, extCfg.Name)
 	}
 	
 	// Insert GREASE before last extension (if GREASE enabled and pre_shared_key is last)
 	if cfg.GREASE && len(extensions) > 0 {
 		if len(cfg.Extensions) > 0 && cfg.Extensions[len(cfg.Extensions)-1].Name == "pre_shared_key" {
 			extensions = append(extensions[:len(extensions)-1], &utls.UtlsGREASEExtension{}, extensions[len(extensions)-1])
+			names = append(names[:len(names)-1], "GREASE", names[len(names)-1])
 		}
 	}
+
+	if cfg.ShuffleExtensions {
+		shuffleExtensions(cfg, extensions, names)
+	}
 	
 	spec.Extensions = extensions
 	return spec, nil
@@ -0,0 +1,264 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ExtractClientHello finds the first ClientHello in a capture. data may be a
+// pcap or pcapng file, a raw TLS record / handshake message, or a hex dump
+// of either (a plain hex stream or Wireshark's "offset  bytes  ascii" form).
+// The result is a TLS record or handshake message for ParseClientHello.
+func ExtractClientHello(data []byte) ([]byte, error) {
+	if len(data) >= 4 {
+		switch binary.LittleEndian.Uint32(data) {
+		case 0xa1b2c3d4, 0xd4c3b2a1, 0xa1b23c4d, 0x4d3cb2a1:
+			return clientHelloFromPcap(data)
+		case 0x0a0d0d0a:
+			return clientHelloFromPcapng(data)
+		}
+	}
+	if len(data) > 0 && (data[0] == 0x16 || data[0] == 0x01) {
+		return data, nil
+	}
+	decoded, err := decodeHexDump(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(decoded) == 0 || (decoded[0] != 0x16 && decoded[0] != 0x01) {
+		return nil, errors.New("hex input is not a TLS handshake record or ClientHello message")
+	}
+	return decoded, nil
+}
+
+func decodeHexDump(s string) ([]byte, error) {
+	var out []byte
+	for _, line := range strings.Split(s, "\n") {
+		fields := strings.Fields(strings.NewReplacer(":", " ", "0x", "", ",", " ").Replace(line))
+		if len(fields) == 0 {
+			continue
+		}
+		// Wireshark/hexdump style: offset, up to 16 single-byte columns, ascii
+		if len(fields) > 1 && len(fields[0]) >= 4 && len(fields[1]) == 2 && isHex(fields[0]) {
+			for _, f := range fields[1:min(len(fields), 17)] {
+				if len(f) != 2 || !isHex(f) {
+					break
+				}
+				b, _ := hex.DecodeString(f)
+				out = append(out, b...)
+			}
+			continue
+		}
+		b, err := hex.DecodeString(strings.Join(fields, ""))
+		if err != nil {
+			return nil, fmt.Errorf("invalid hex input: %v", err)
+		}
+		out = append(out, b...)
+	}
+	return out, nil
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+func clientHelloFromPcap(data []byte) ([]byte, error) {
+	if len(data) < 24 {
+		return nil, errors.New("truncated pcap header")
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if m := binary.LittleEndian.Uint32(data); m == 0xd4c3b2a1 || m == 0x4d3cb2a1 {
+		order = binary.BigEndian
+	}
+	linkType := order.Uint32(data[20:24]) & 0x0fffffff
+
+	r := newTCPReassembler()
+	data = data[24:]
+	for len(data) >= 16 {
+		capLen := int(order.Uint32(data[8:12]))
+		if len(data) < 16+capLen {
+			break
+		}
+		if hello := r.add(linkType, data[16:16+capLen]); hello != nil {
+			return hello, nil
+		}
+		data = data[16+capLen:]
+	}
+	return nil, errors.New("no ClientHello found in pcap")
+}
+
+func clientHelloFromPcapng(data []byte) ([]byte, error) {
+	var order binary.ByteOrder = binary.LittleEndian
+	linkTypes := []uint32{}
+	r := newTCPReassembler()
+
+	for len(data) >= 12 {
+		blockType := order.Uint32(data)
+		if blockType == 0x0a0d0d0a {
+			// Section header: byte order magic decides endianness for the section
+			if binary.LittleEndian.Uint32(data[8:12]) == 0x1a2b3c4d {
+				order = binary.LittleEndian
+			} else {
+				order = binary.BigEndian
+			}
+			linkTypes = linkTypes[:0]
+		}
+		blockLen := int(order.Uint32(data[4:8]))
+		if blockLen < 12 || blockLen > len(data) {
+			break
+		}
+		body := data[8 : blockLen-4]
+
+		switch blockType {
+		case 1: // Interface Description Block
+			if len(body) >= 2 {
+				linkTypes = append(linkTypes, uint32(order.Uint16(body)))
+			}
+		case 6: // Enhanced Packet Block
+			if len(body) >= 20 {
+				iface := order.Uint32(body)
+				capLen := int(order.Uint32(body[12:16]))
+				if int(iface) < len(linkTypes) && 20+capLen <= len(body) {
+					if hello := r.add(linkTypes[iface], body[20:20+capLen]); hello != nil {
+						return hello, nil
+					}
+				}
+			}
+		case 3: // Simple Packet Block
+			if len(body) >= 4 && len(linkTypes) > 0 {
+				if hello := r.add(linkTypes[0], body[4:]); hello != nil {
+					return hello, nil
+				}
+			}
+		}
+		data = data[blockLen:]
+	}
+	return nil, errors.New("no ClientHello found in pcapng")
+}
+
+// tcpReassembler stitches a ClientHello record back together when it spans
+// several TCP segments (common with post-quantum key shares).
+type tcpReassembler struct {
+	flows map[string]*pendingHello
+}
+
+type pendingHello struct {
+	buf     []byte
+	want    int
+	nextSeq uint32
+}
+
+func newTCPReassembler() *tcpReassembler {
+	return &tcpReassembler{flows: map[string]*pendingHello{}}
+}
+
+// add feeds one captured frame and returns the complete TLS record once the
+// first ClientHello has been fully seen.
+func (r *tcpReassembler) add(linkType uint32, frame []byte) []byte {
+	flow, seq, payload, ok := tcpPayload(linkType, frame)
+	if !ok || len(payload) == 0 {
+		return nil
+	}
+
+	if p, ok := r.flows[flow]; ok {
+		if seq != p.nextSeq {
+			return nil
+		}
+		p.buf = append(p.buf, payload...)
+		p.nextSeq += uint32(len(payload))
+	} else {
+		if len(payload) < 6 || payload[0] != 0x16 || payload[1] != 0x03 || payload[5] != 0x01 {
+			return nil
+		}
+		r.flows[flow] = &pendingHello{
+			buf:     append([]byte{}, payload...),
+			want:    5 + int(binary.BigEndian.Uint16(payload[3:5])),
+			nextSeq: seq + uint32(len(payload)),
+		}
+	}
+
+	p := r.flows[flow]
+	if len(p.buf) >= p.want {
+		return p.buf[:p.want]
+	}
+	return nil
+}
+
+// tcpPayload strips the link, IP and TCP headers from a frame.
+func tcpPayload(linkType uint32, frame []byte) (flow string, seq uint32, payload []byte, ok bool) {
+	var ip []byte
+	switch linkType {
+	case 1: // Ethernet
+		if len(frame) < 14 {
+			return
+		}
+		etherType := binary.BigEndian.Uint16(frame[12:14])
+		ip = frame[14:]
+		for etherType == 0x8100 && len(ip) >= 4 { // 802.1Q VLAN tags
+			etherType = binary.BigEndian.Uint16(ip[2:4])
+			ip = ip[4:]
+		}
+	case 0, 108: // BSD loopback
+		if len(frame) < 4 {
+			return
+		}
+		ip = frame[4:]
+	case 101, 228, 229: // Raw IP
+		ip = frame
+	case 113: // Linux cooked capture
+		if len(frame) < 16 {
+			return
+		}
+		ip = frame[16:]
+	case 276: // Linux cooked capture v2
+		if len(frame) < 20 {
+			return
+		}
+		ip = frame[20:]
+	default:
+		return
+	}
+
+	var tcp []byte
+	var src, dst []byte
+	switch {
+	case len(ip) >= 20 && ip[0]>>4 == 4:
+		ihl := int(ip[0]&0x0f) * 4
+		total := int(binary.BigEndian.Uint16(ip[2:4]))
+		if ip[9] != 6 || ihl < 20 || total < ihl || total > len(ip) {
+			return
+		}
+		src, dst, tcp = ip[12:16], ip[16:20], ip[ihl:total]
+	case len(ip) >= 40 && ip[0]>>4 == 6:
+		total := 40 + int(binary.BigEndian.Uint16(ip[4:6]))
+		if ip[6] != 6 || total > len(ip) {
+			return
+		}
+		src, dst, tcp = ip[8:24], ip[24:40], ip[40:total]
+	default:
+		return
+	}
+
+	if len(tcp) < 20 {
+		return
+	}
+	dataOffset := int(tcp[12]>>4) * 4
+	if dataOffset < 20 || dataOffset > len(tcp) {
+		return
+	}
+	var key bytes.Buffer
+	key.Write(src)
+	key.Write(tcp[0:2])
+	key.Write(dst)
+	key.Write(tcp[2:4])
+	return key.String(), binary.BigEndian.Uint32(tcp[4:8]), tcp[dataOffset:], true
+}
@@ -0,0 +1,137 @@
+package fingerprint
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// tcpFrame builds an Ethernet/IPv4/TCP frame from 10.0.0.1:50000 to
+// 10.0.0.2:443 carrying payload at sequence number seq.
+func tcpFrame(seq uint32, payload []byte) []byte {
+	tcp := make([]byte, 20)
+	binary.BigEndian.PutUint16(tcp[0:], 50000)
+	binary.BigEndian.PutUint16(tcp[2:], 443)
+	binary.BigEndian.PutUint32(tcp[4:], seq)
+	tcp[12] = 5 << 4
+	ip := make([]byte, 20)
+	ip[0] = 0x45
+	binary.BigEndian.PutUint16(ip[2:], uint16(20+len(tcp)+len(payload)))
+	ip[9] = 6
+	copy(ip[12:], []byte{10, 0, 0, 1})
+	copy(ip[16:], []byte{10, 0, 0, 2})
+	eth := make([]byte, 14)
+	binary.BigEndian.PutUint16(eth[12:], 0x0800)
+	return bytes.Join([][]byte{eth, ip, tcp, payload}, nil)
+}
+
+// helloFrames splits rec across two TCP segments, after an unrelated one.
+func helloFrames(rec []byte) [][]byte {
+	return [][]byte{
+		tcpFrame(1, []byte("GET / HTTP/1.1\r\n\r\n")),
+		tcpFrame(1000, rec[:100]),
+		tcpFrame(1100, rec[100:]),
+	}
+}
+
+func pcapFile(order binary.ByteOrder, frames [][]byte) []byte {
+	var b bytes.Buffer
+	header := make([]byte, 24)
+	order.PutUint32(header[0:], 0xa1b2c3d4)
+	order.PutUint16(header[4:], 2)
+	order.PutUint16(header[6:], 4)
+	order.PutUint32(header[16:], 65535)
+	order.PutUint32(header[20:], 1) // Ethernet
+	b.Write(header)
+	for _, f := range frames {
+		rec := make([]byte, 16)
+		order.PutUint32(rec[8:], uint32(len(f)))
+		order.PutUint32(rec[12:], uint32(len(f)))
+		b.Write(rec)
+		b.Write(f)
+	}
+	return b.Bytes()
+}
+
+func pcapngFile(frames [][]byte) []byte {
+	le := binary.LittleEndian
+	var b bytes.Buffer
+	block := func(typ uint32, body []byte) {
+		for len(body)%4 != 0 {
+			body = append(body, 0)
+		}
+		n := uint32(12 + len(body))
+		b.Write(le.AppendUint32(le.AppendUint32(nil, typ), n))
+		b.Write(body)
+		b.Write(le.AppendUint32(nil, n))
+	}
+	block(0x0a0d0d0a, []byte{0x4d, 0x3c, 0x2b, 0x1a, 1, 0, 0, 0, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	block(1, []byte{1, 0, 0, 0, 0, 0, 0, 0}) // Ethernet
+	for _, f := range frames {
+		body := make([]byte, 20)
+		le.PutUint32(body[12:], uint32(len(f)))
+		le.PutUint32(body[16:], uint32(len(f)))
+		block(6, append(body, f...))
+	}
+	return b.Bytes()
+}
+
+func TestExtractClientHello(t *testing.T) {
+	msg := marshalHello(chromeHello)
+	rec := record(msg)
+	tests := []struct {
+		name  string
+		input []byte
+		want  []byte
+	}{
+		{"handshake message", msg, msg},
+		{"TLS record", rec, rec},
+		{"hex stream", []byte(hex.EncodeToString(rec)), rec},
+		{"wrapped hex", []byte(strings.ToUpper(hex.EncodeToString(rec[:40])) + "\n" + hex.EncodeToString(rec[40:]) + "\n"), rec},
+		{"colon hex", []byte(colonHex(rec)), rec},
+		{"hexdump", []byte(hex.Dump(rec)), rec},
+		{"pcap", pcapFile(binary.LittleEndian, helloFrames(rec)), rec},
+		{"big-endian pcap", pcapFile(binary.BigEndian, helloFrames(rec)), rec},
+		{"pcapng", pcapngFile(helloFrames(rec)), rec},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ExtractClientHello(tt.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(got, tt.want) {
+				t.Errorf("got %x\nwant %x", got, tt.want)
+			}
+		})
+	}
+}
+
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, c := range b {
+		parts[i] = hex.EncodeToString([]byte{c})
+	}
+	return strings.Join(parts, ":")
+}
+
+func TestExtractClientHelloErrors(t *testing.T) {
+	rec := record(marshalHello(chromeHello))
+	tests := []struct {
+		name  string
+		input []byte
+	}{
+		{"not hex", []byte("hello world")},
+		{"hex of something else", []byte("474554202f")},
+		{"pcap without ClientHello", pcapFile(binary.LittleEndian, helloFrames(rec)[:1])},
+		{"pcap with a missing segment", pcapFile(binary.LittleEndian, helloFrames(rec)[:2])},
+		{"pcapng without ClientHello", pcapngFile(nil)},
+	}
+	for _, tt := range tests {
+		if _, err := ExtractClientHello(tt.input); err == nil {
+			t.Errorf("%s: no error", tt.name)
+		}
+	}
+}
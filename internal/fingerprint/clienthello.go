@@ -0,0 +1,154 @@
+package fingerprint
+
+import (
+	"errors"
+	"net"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// ClientHello is a ClientHello message split into its fields, with
+// extensions kept raw and in wire order.
+type ClientHello struct {
+	Version            uint16
+	CipherSuites       []uint16
+	CompressionMethods []byte
+	Extensions         []RawExtension
+}
+
+type RawExtension struct {
+	Type uint16
+	Data []byte
+}
+
+// ParseClientHello parses a ClientHello given either as a TLS record
+// (starting with 0x16) or as a bare handshake message (starting with 0x01).
+func ParseClientHello(data []byte) (*ClientHello, error) {
+	if len(data) >= 5 && data[0] == 0x16 {
+		record := cryptobyte.String(data[3:])
+		var fragment cryptobyte.String
+		if !record.ReadUint16LengthPrefixed(&fragment) {
+			return nil, errors.New("truncated TLS record")
+		}
+		data = fragment
+	}
+
+	s := cryptobyte.String(data)
+	var msgType uint8
+	var body cryptobyte.String
+	if !s.ReadUint8(&msgType) || msgType != 1 {
+		return nil, errors.New("not a ClientHello handshake message")
+	}
+	if !s.ReadUint24LengthPrefixed(&body) {
+		return nil, errors.New("truncated ClientHello")
+	}
+
+	hello := &ClientHello{}
+	var random, sessionID, ciphers, compression cryptobyte.String
+	if !body.ReadUint16(&hello.Version) ||
+		!body.ReadBytes((*[]byte)(&random), 32) ||
+		!body.ReadUint8LengthPrefixed(&sessionID) ||
+		!body.ReadUint16LengthPrefixed(&ciphers) ||
+		!body.ReadUint8LengthPrefixed(&compression) {
+		return nil, errors.New("malformed ClientHello")
+	}
+	for !ciphers.Empty() {
+		var c uint16
+		if !ciphers.ReadUint16(&c) {
+			return nil, errors.New("malformed cipher suite list")
+		}
+		hello.CipherSuites = append(hello.CipherSuites, c)
+	}
+	hello.CompressionMethods = []byte(compression)
+
+	if body.Empty() {
+		return hello, nil
+	}
+	var exts cryptobyte.String
+	if !body.ReadUint16LengthPrefixed(&exts) {
+		return nil, errors.New("malformed extension block")
+	}
+	for !exts.Empty() {
+		var ext RawExtension
+		var extData cryptobyte.String
+		if !exts.ReadUint16(&ext.Type) || !exts.ReadUint16LengthPrefixed(&extData) {
+			return nil, errors.New("malformed extension")
+		}
+		ext.Data = []byte(extData)
+		hello.Extensions = append(hello.Extensions, ext)
+	}
+	return hello, nil
+}
+
+// Extension returns the body of the first extension of the given type.
+func (h *ClientHello) Extension(id uint16) ([]byte, bool) {
+	for _, ext := range h.Extensions {
+		if ext.Type == id {
+			return ext.Data, true
+		}
+	}
+	return nil, false
+}
+
+// Uint16List decodes an extension body made of a length-prefixed list of
+// uint16 values (supported_groups, signature_algorithms, ...). lenBytes is
+// the size of the list length prefix.
+func Uint16List(data []byte, lenBytes int) []uint16 {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	switch lenBytes {
+	case 1:
+		if !s.ReadUint8LengthPrefixed(&list) {
+			return nil
+		}
+	default:
+		if !s.ReadUint16LengthPrefixed(&list) {
+			return nil
+		}
+	}
+	values := []uint16{}
+	for !list.Empty() {
+		var v uint16
+		if !list.ReadUint16(&v) {
+			return nil
+		}
+		values = append(values, v)
+	}
+	return values
+}
+
+// ProtocolList decodes an ALPN/ALPS style list of protocol names.
+func ProtocolList(data []byte) []string {
+	s := cryptobyte.String(data)
+	var list cryptobyte.String
+	if !s.ReadUint16LengthPrefixed(&list) {
+		return nil
+	}
+	protocols := []string{}
+	for !list.Empty() {
+		var proto cryptobyte.String
+		if !list.ReadUint8LengthPrefixed(&proto) {
+			return nil
+		}
+		protocols = append(protocols, string(proto))
+	}
+	return protocols
+}
+
+// MarshalClientHello serializes spec into the ClientHello handshake message
+// utls would send to serverName, without touching the network.
+func MarshalClientHello(spec *utls.ClientHelloSpec, serverName string) ([]byte, error) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	uConn := utls.UClient(client, &utls.Config{ServerName: serverName, InsecureSkipVerify: true}, utls.HelloCustom)
+	if err := uConn.ApplyPreset(spec); err != nil {
+		return nil, err
+	}
+	if err := uConn.BuildHandshakeState(); err != nil {
+		return nil, err
+	}
+	return uConn.HandshakeState.Hello.Raw, nil
+}
@@ -2,6 +2,7 @@ package fingerprint
 
 import (
 	"crypto/rand"
+	"encoding/hex"
 	"fmt"
 
 	"fingerPrintRequester/internal/config"
@@ -25,7 +26,7 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 		curves := []utls.CurveID{}
 		if curveNames, ok := cfg.Data["curves"].([]interface{}); ok {
 			for _, name := range curveNames {
-				if curveID, exists := CurveMap[name.(string)]; exists {
+				if curveID, exists := LookupCurve(name.(string)); exists {
 					curves = append(curves, curveID)
 				}
 			}
@@ -57,7 +58,7 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 		algorithms := []utls.SignatureScheme{}
 		if algoNames, ok := cfg.Data["algorithms"].([]interface{}); ok {
 			for _, name := range algoNames {
-				if algo, exists := SignatureAlgorithmMap[name.(string)]; exists {
+				if algo, exists := LookupSignatureAlgorithm(name.(string)); exists {
 					algorithms = append(algorithms, algo)
 				}
 			}
@@ -67,7 +68,7 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 		algorithms := []utls.SignatureScheme{}
 		if algoNames, ok := cfg.Data["algorithms"].([]interface{}); ok {
 			for _, name := range algoNames {
-				if algo, exists := SignatureAlgorithmMap[name.(string)]; exists {
+				if algo, exists := LookupSignatureAlgorithm(name.(string)); exists {
 					algorithms = append(algorithms, algo)
 				}
 			}
@@ -80,7 +81,7 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 		if groupNames, ok := cfg.Data["groups"].([]interface{}); ok {
 			groups = make([]utls.CurveID, 0, len(groupNames))
 			for _, name := range groupNames {
-				if curveID, exists := CurveMap[name.(string)]; exists {
+				if curveID, exists := LookupCurve(name.(string)); exists {
 					groups = append(groups, curveID)
 				}
 			}
@@ -109,6 +110,10 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 		}
 		return &utls.SupportedVersionsExtension{Versions: versions}, nil
 	case "padding":
+		// "boringssl" pads the hello the way Chrome/Safari do instead of a fixed length
+		if style, ok := cfg.Data["style"].(string); ok && style == "boringssl" {
+			return &utls.UtlsPaddingExtension{GetPaddingLen: utls.BoringPaddingStyle}, nil
+		}
 		length := 0
 		if l, ok := cfg.Data["length"].(float64); ok {
 			length = int(l)
@@ -136,6 +141,15 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 			}
 		}
 		return &utls.ApplicationSettingsExtension{SupportedProtocols: protocols}, nil
+	case "application_settings_new":
+		protocols := []string{"h2"}
+		if protos, ok := cfg.Data["protocols"].([]interface{}); ok {
+			protocols = make([]string, len(protos))
+			for i, p := range protos {
+				protocols[i] = p.(string)
+			}
+		}
+		return &utls.ApplicationSettingsExtensionNew{SupportedProtocols: protocols}, nil
 	case "pre_shared_key":
 		identityLen := 138
 		binderLen := 32
@@ -186,6 +200,18 @@ func BuildExtension(cfg config.ExtensionConfig, serverName string) (utls.TLSExte
 	case "GREASE":
 		return &utls.UtlsGREASEExtension{}, nil
 	default:
+		// Raw extension type ("0x1234") with an optional hex-encoded body
+		if id, ok := parseRawID(cfg.Name); ok {
+			data := []byte{}
+			if h, ok := cfg.Data["data"].(string); ok {
+				decoded, err := hex.DecodeString(h)
+				if err != nil {
+					return nil, fmt.Errorf("extension %s: %v", cfg.Name, err)
+				}
+				data = decoded
+			}
+			return &utls.GenericExtension{Id: id, Data: data}, nil
+		}
 		return nil, fmt.Errorf("unknown extension: %s", cfg.Name)
 	}
 }
@@ -0,0 +1,277 @@
+package fingerprint
+
+import (
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// Hashes holds the fingerprints echo services report for a handshake.
+type Hashes struct {
+	JA3        string `json:"ja3"`
+	JA3Hash    string `json:"ja3_hash"`
+	JA3N       string `json:"ja3n"`
+	JA3NHash   string `json:"ja3n_hash"`
+	JA4        string `json:"ja4"`
+	Akamai     string `json:"akamai,omitempty"`
+	AkamaiHash string `json:"akamai_hash,omitempty"`
+}
+
+// ComputeHashes builds the fingerprint for targetURL and hashes it offline.
+func ComputeHashes(cfg *config.Config, targetURL string) (*Hashes, error) {
+	spec, err := Build(&cfg.Fingerprint, targetURL)
+	if err != nil {
+		return nil, err
+	}
+	parsedURL, err := url.Parse(targetURL)
+	if err != nil {
+		return nil, err
+	}
+	h, err := Hash(spec, parsedURL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	h.SetAkamai(AkamaiFingerprint(cfg))
+	return h, nil
+}
+
+// Hash computes the TLS fingerprints of the ClientHello spec produces.
+func Hash(spec *utls.ClientHelloSpec, serverName string) (*Hashes, error) {
+	raw, err := MarshalClientHello(spec, serverName)
+	if err != nil {
+		return nil, err
+	}
+	return HashClientHello(raw)
+}
+
+// HashClientHello computes the TLS fingerprints of a serialized ClientHello.
+func HashClientHello(raw []byte) (*Hashes, error) {
+	hello, err := ParseClientHello(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ciphers := withoutGREASE(hello.CipherSuites)
+	extensions := []uint16{}
+	for _, ext := range hello.Extensions {
+		if !IsGREASE(ext.Type) {
+			extensions = append(extensions, ext.Type)
+		}
+	}
+	curves := []uint16{}
+	if data, ok := hello.Extension(ExtensionMap["supported_groups"]); ok {
+		curves = withoutGREASE(Uint16List(data, 2))
+	}
+	formats := []uint16{}
+	if data, ok := hello.Extension(ExtensionMap["ec_point_formats"]); ok && len(data) > 0 {
+		for _, f := range data[1:] {
+			formats = append(formats, uint16(f))
+		}
+	}
+
+	sortedExtensions := append([]uint16{}, extensions...)
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+
+	h := &Hashes{}
+	h.JA3 = ja3String(hello.Version, ciphers, extensions, curves, formats)
+	h.JA3Hash = md5Hex(h.JA3)
+	h.JA3N = ja3String(hello.Version, ciphers, sortedExtensions, curves, formats)
+	h.JA3NHash = md5Hex(h.JA3N)
+	h.JA4 = ja4(hello, ciphers, extensions)
+	return h, nil
+}
+
+// SetAkamai records an Akamai HTTP/2 fingerprint string and its hash.
+func (h *Hashes) SetAkamai(fp string) {
+	h.Akamai = fp
+	h.AkamaiHash = ""
+	if fp != "" {
+		h.AkamaiHash = md5Hex(fp)
+	}
+}
+
+// AkamaiFingerprint returns the Akamai HTTP/2 fingerprint
+// ("SETTINGS|WINDOW_UPDATE|PRIORITY|PSEUDO_HEADER_ORDER") the requester
+// produces for cfg, or "" when HTTP/2 is disabled.
+func AkamaiFingerprint(cfg *config.Config) string {
+	if !cfg.Fingerprint.HTTP2 {
+		return ""
+	}
+	h2 := cfg.HTTP2.WithDefaults()
+
+	settings := make([]string, len(h2.Settings))
+	for i, s := range h2.Settings {
+		settings[i] = fmt.Sprintf("%d:%d", s.ID, s.Value)
+	}
+
+	priorities := "0"
+	if len(h2.Priorities) > 0 {
+		parts := make([]string, len(h2.Priorities))
+		for i, p := range h2.Priorities {
+			parts[i] = fmt.Sprintf("%d:%d:%d:%d", p.StreamID, boolInt(p.Exclusive), p.DependsOn, p.Weight)
+		}
+		priorities = strings.Join(parts, ",")
+	}
+
+	// Akamai writes 00 for a connection without WINDOW_UPDATE.
+	windowUpdate := "00"
+	if *h2.WindowUpdate > 0 {
+		windowUpdate = strconv.FormatUint(uint64(*h2.WindowUpdate), 10)
+	}
+
+	pseudo := []string{}
+	for _, name := range config.PseudoOrder(cfg.PseudoHeaderOrder) {
+		pseudo = append(pseudo, name[1:2])
+	}
+
+	return strings.Join([]string{
+		strings.Join(settings, ";"),
+		windowUpdate,
+		priorities,
+		strings.Join(pseudo, ","),
+	}, "|")
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func ja3String(version uint16, ciphers, extensions, curves, formats []uint16) string {
+	return strings.Join([]string{
+		strconv.Itoa(int(version)),
+		joinDecimal(ciphers),
+		joinDecimal(extensions),
+		joinDecimal(curves),
+		joinDecimal(formats),
+	}, ",")
+}
+
+// ja4 follows the FoxIO JA4 spec: t<version><sni><#ciphers><#exts><alpn>
+// followed by truncated SHA-256 hashes of the sorted ciphers and of the
+// sorted extensions plus signature algorithms.
+func ja4(hello *ClientHello, ciphers, extensions []uint16) string {
+	version := hello.Version
+	if data, ok := hello.Extension(ExtensionMap["supported_versions"]); ok {
+		version = 0
+		for _, v := range withoutGREASE(Uint16List(data, 1)) {
+			if v > version {
+				version = v
+			}
+		}
+	}
+
+	sni := "i"
+	if _, ok := hello.Extension(ExtensionMap["server_name"]); ok {
+		sni = "d"
+	}
+
+	alpn := "00"
+	if data, ok := hello.Extension(ExtensionMap["application_layer_protocol_negotiation"]); ok {
+		if protos := ProtocolList(data); len(protos) > 0 && protos[0] != "" {
+			first, last := protos[0][0], protos[0][len(protos[0])-1]
+			if isAlnum(first) && isAlnum(last) {
+				alpn = string([]byte{first, last})
+			} else {
+				h := hex.EncodeToString([]byte(protos[0]))
+				alpn = string([]byte{h[0], h[len(h)-1]})
+			}
+		}
+	}
+
+	a := fmt.Sprintf("t%s%s%02d%02d%s", ja4Version(version), sni, min(len(ciphers), 99), min(len(extensions), 99), alpn)
+
+	sortedCiphers := append([]uint16{}, ciphers...)
+	sort.Slice(sortedCiphers, func(i, j int) bool { return sortedCiphers[i] < sortedCiphers[j] })
+	b := truncatedSHA256(joinHex(sortedCiphers))
+
+	sortedExtensions := []uint16{}
+	for _, ext := range extensions {
+		if ext != ExtensionMap["server_name"] && ext != ExtensionMap["application_layer_protocol_negotiation"] {
+			sortedExtensions = append(sortedExtensions, ext)
+		}
+	}
+	sort.Slice(sortedExtensions, func(i, j int) bool { return sortedExtensions[i] < sortedExtensions[j] })
+	c := joinHex(sortedExtensions)
+	if data, ok := hello.Extension(ExtensionMap["signature_algorithms"]); ok {
+		if algos := withoutGREASE(Uint16List(data, 2)); len(algos) > 0 {
+			c += "_" + joinHex(algos)
+		}
+	}
+	if len(sortedExtensions) == 0 {
+		c = ""
+	}
+
+	return a + "_" + b + "_" + truncatedSHA256(c)
+}
+
+func ja4Version(v uint16) string {
+	switch v {
+	case utls.VersionTLS13:
+		return "13"
+	case utls.VersionTLS12:
+		return "12"
+	case utls.VersionTLS11:
+		return "11"
+	case utls.VersionTLS10:
+		return "10"
+	case 0x0300:
+		return "s3"
+	default:
+		return "00"
+	}
+}
+
+func withoutGREASE(values []uint16) []uint16 {
+	out := []uint16{}
+	for _, v := range values {
+		if !IsGREASE(v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func joinDecimal(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.Itoa(int(v))
+	}
+	return strings.Join(parts, "-")
+}
+
+func joinHex(values []uint16) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = fmt.Sprintf("%04x", v)
+	}
+	return strings.Join(parts, ",")
+}
+
+func truncatedSHA256(s string) string {
+	if s == "" {
+		return "000000000000"
+	}
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])[:12]
+}
+
+func md5Hex(s string) string {
+	sum := md5.Sum([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func isAlnum(c byte) bool {
+	return c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
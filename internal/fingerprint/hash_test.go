@@ -0,0 +1,178 @@
+package fingerprint
+
+import (
+	"reflect"
+	"testing"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// marshalHello serializes h as a ClientHello handshake message with a zero
+// random and an empty session ID.
+func marshalHello(h *ClientHello) []byte {
+	var b cryptobyte.Builder
+	b.AddUint8(1)
+	b.AddUint24LengthPrefixed(func(b *cryptobyte.Builder) {
+		b.AddUint16(h.Version)
+		b.AddBytes(make([]byte, 32))
+		b.AddUint8(0)
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, c := range h.CipherSuites {
+				b.AddUint16(c)
+			}
+		})
+		b.AddUint8LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(h.CompressionMethods) })
+		b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) {
+			for _, ext := range h.Extensions {
+				b.AddUint16(ext.Type)
+				b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(ext.Data) })
+			}
+		})
+	})
+	return b.BytesOrPanic()
+}
+
+// record wraps a handshake message in a TLS handshake record.
+func record(msg []byte) []byte {
+	return append([]byte{0x16, 0x03, 0x01, byte(len(msg) >> 8), byte(len(msg))}, msg...)
+}
+
+// u16 encodes values with a lenBytes-long length prefix.
+func u16(lenBytes int, values ...uint16) []byte {
+	var b cryptobyte.Builder
+	add := func(b *cryptobyte.Builder) {
+		for _, v := range values {
+			b.AddUint16(v)
+		}
+	}
+	if lenBytes == 1 {
+		b.AddUint8LengthPrefixed(add)
+	} else {
+		b.AddUint16LengthPrefixed(add)
+	}
+	return b.BytesOrPanic()
+}
+
+// chromeHello is the Chrome ClientHello of the FoxIO JA4 technical details.
+var chromeHello = &ClientHello{
+	Version:            0x0303,
+	CipherSuites:       []uint16{0x0a0a, 0x1301, 0x1302, 0x1303, 0xc02b, 0xc02f, 0xc02c, 0xc030, 0xcca9, 0xcca8, 0xc013, 0xc014, 0x009c, 0x009d, 0x002f, 0x0035},
+	CompressionMethods: []byte{0},
+	Extensions: []RawExtension{
+		{Type: 0x0a0a},
+		{Type: 0x0000, Data: []byte{0, 14, 0, 0, 11, 'e', 'x', 'a', 'm', 'p', 'l', 'e', '.', 'c', 'o', 'm'}},
+		{Type: 0x0017},
+		{Type: 0xff01, Data: []byte{0}},
+		{Type: 0x000a, Data: u16(2, 0x2a2a, 0x001d, 0x0017, 0x0018)},
+		{Type: 0x000b, Data: []byte{1, 0}},
+		{Type: 0x0023},
+		{Type: 0x0010, Data: []byte{0, 12, 2, 'h', '2', 8, 'h', 't', 't', 'p', '/', '1', '.', '1'}},
+		{Type: 0x0005, Data: []byte{1, 0, 0, 0, 0}},
+		{Type: 0x000d, Data: u16(2, 0x0403, 0x0804, 0x0401, 0x0503, 0x0805, 0x0501, 0x0806, 0x0601)},
+		{Type: 0x0012},
+		{Type: 0x0033, Data: []byte{0, 0}},
+		{Type: 0x002d, Data: []byte{1, 1}},
+		{Type: 0x002b, Data: u16(1, 0x3a3a, 0x0304, 0x0303)},
+		{Type: 0x001b, Data: []byte{2, 0, 2}},
+		{Type: 0x4469, Data: []byte{0, 3, 2, 'h', '2'}},
+		{Type: 0x1a1a, Data: []byte{0}},
+		{Type: 0x0015, Data: make([]byte, 8)},
+	},
+}
+
+func TestParseClientHello(t *testing.T) {
+	msg := marshalHello(chromeHello)
+	badExts := marshalHello(&ClientHello{Version: 0x0303, CompressionMethods: []byte{0}})
+	badExts[len(badExts)-1] = 5 // extension block longer than the message
+	for name, data := range map[string][]byte{"handshake": msg, "record": record(msg)} {
+		got, err := ParseClientHello(data)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i := range got.Extensions {
+			if len(got.Extensions[i].Data) == 0 {
+				got.Extensions[i].Data = nil
+			}
+		}
+		if !reflect.DeepEqual(got, chromeHello) {
+			t.Errorf("%s: parsed\n%+v\nwant\n%+v", name, got, chromeHello)
+		}
+	}
+
+	for name, data := range map[string][]byte{
+		"empty":          nil,
+		"server hello":   {2, 0, 0, 0},
+		"truncated":      msg[:len(msg)-1],
+		"short record":   record(msg)[:20],
+		"bad extensions": badExts,
+	} {
+		if _, err := ParseClientHello(data); err == nil {
+			t.Errorf("%s: parsed without error", name)
+		}
+	}
+}
+
+func TestHashClientHello(t *testing.T) {
+	h, err := HashClientHello(marshalHello(chromeHello))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := Hashes{
+		JA3:  "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513-21,29-23-24,0",
+		JA3N: "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-5-10-11-13-16-18-21-23-27-35-43-45-51-17513-65281,29-23-24,0",
+		JA4:  "t13d1516h2_8daaf6152771_e5627efa2ab1",
+	}
+	want.JA3Hash, want.JA3NHash = md5Hex(want.JA3), md5Hex(want.JA3N)
+	if *h != want {
+		t.Errorf("got  %+v\nwant %+v", *h, want)
+	}
+}
+
+func TestJA4(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(h *ClientHello)
+		want string // first JA4 section
+	}{
+		{"chrome", func(h *ClientHello) {}, "t13d1516h2"},
+		{"no SNI", func(h *ClientHello) { h.Extensions = dropExtension(h.Extensions, 0x0000) }, "t13i1515h2"},
+		{"no ALPN", func(h *ClientHello) { h.Extensions = dropExtension(h.Extensions, 0x0010) }, "t13d151500"},
+		{"TLS 1.2", func(h *ClientHello) { h.Extensions = dropExtension(h.Extensions, 0x002b) }, "t12d1515h2"},
+		{"http/1.1 first", func(h *ClientHello) {
+			setExtension(h, 0x0010, []byte{0, 9, 8, 'h', 't', 't', 'p', '/', '1', '.', '1'})
+		}, "t13d1516h1"},
+		{"non-alphanumeric ALPN", func(h *ClientHello) { setExtension(h, 0x0010, []byte{0, 3, 2, 0xab, 0xcd}) }, "t13d1516ad"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hello := *chromeHello
+			hello.Extensions = append([]RawExtension{}, chromeHello.Extensions...)
+			tt.edit(&hello)
+			h, err := HashClientHello(marshalHello(&hello))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := h.JA4[:len(tt.want)]; got != tt.want {
+				t.Errorf("JA4 %s, want prefix %s", h.JA4, tt.want)
+			}
+		})
+	}
+}
+
+func dropExtension(exts []RawExtension, id uint16) []RawExtension {
+	out := []RawExtension{}
+	for _, ext := range exts {
+		if ext.Type != id {
+			out = append(out, ext)
+		}
+	}
+	return out
+}
+
+func setExtension(h *ClientHello, id uint16, data []byte) {
+	for i := range h.Extensions {
+		if h.Extensions[i].Type == id {
+			h.Extensions[i].Data = data
+		}
+	}
+}
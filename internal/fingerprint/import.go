@@ -0,0 +1,221 @@
+package fingerprint
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+	"golang.org/x/crypto/cryptobyte"
+)
+
+// FromClientHello converts a parsed ClientHello into a FingerprintConfig,
+// keeping ciphers and extensions in wire order and decoding each extension
+// body into the data keys BuildExtension reads. The returned notes flag
+// anything Build will not reproduce byte for byte.
+func FromClientHello(hello *ClientHello) (*config.FingerprintConfig, []string, error) {
+	cfg := &config.FingerprintConfig{
+		TLSVersionMin:      RawID(hello.Version),
+		TLSVersionMax:      RawID(hello.Version),
+		CompressionMethods: config.ByteList(hello.CompressionMethods),
+	}
+	notes := []string{}
+
+	greaseCiphers := 0
+	greaseMoved := false
+	for i, c := range hello.CipherSuites {
+		if IsGREASE(c) {
+			greaseCiphers++
+			if i != 0 && !greaseMoved {
+				greaseMoved = true
+				notes = append(notes, "GREASE cipher is not first; Build always puts it first")
+			}
+			continue
+		}
+		cfg.Ciphers = append(cfg.Ciphers, CipherName(c))
+	}
+
+	greasePositions := []int{}
+	for i, raw := range hello.Extensions {
+		if IsGREASE(raw.Type) {
+			greasePositions = append(greasePositions, i)
+			continue
+		}
+		ext, note, err := extensionFromWire(raw)
+		if err != nil {
+			return nil, nil, err
+		}
+		if note != "" {
+			notes = append(notes, note)
+		}
+		switch ext.Name {
+		case "supported_versions":
+			if versions := withoutGREASE(Uint16List(raw.Data, 1)); len(versions) > 0 {
+				lo, hi := versions[0], versions[0]
+				for _, v := range versions {
+					lo, hi = min(lo, v), max(hi, v)
+				}
+				cfg.TLSVersionMin, cfg.TLSVersionMax = RawID(lo), RawID(hi)
+			}
+		case "application_layer_protocol_negotiation":
+			for _, p := range ext.Data["protocols"].([]interface{}) {
+				if p == "h2" {
+					cfg.HTTP2 = true
+				}
+			}
+		}
+		cfg.Extensions = append(cfg.Extensions, ext)
+	}
+
+	cfg.GREASE = greaseCiphers > 0 || len(greasePositions) > 0
+	if cfg.GREASE && !greaseAtBuildPositions(greasePositions, len(hello.Extensions), cfg.Extensions) {
+		notes = append(notes, "GREASE extensions are not at the positions Build uses (first, and before a trailing pre_shared_key)")
+	}
+
+	return cfg, notes, nil
+}
+
+// greaseAtBuildPositions reports whether the observed GREASE extension
+// indexes match what Build emits for the same extension list.
+func greaseAtBuildPositions(positions []int, total int, exts []config.ExtensionConfig) bool {
+	want := []int{0}
+	if len(exts) > 0 && exts[len(exts)-1].Name == "pre_shared_key" {
+		want = append(want, total-2)
+	}
+	if len(positions) != len(want) {
+		return false
+	}
+	for i := range want {
+		if positions[i] != want[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func extensionFromWire(raw RawExtension) (config.ExtensionConfig, string, error) {
+	name := ExtensionName(raw.Type)
+	ext := config.ExtensionConfig{Name: name}
+	body := cryptobyte.String(raw.Data)
+	malformed := fmt.Errorf("malformed %s extension", name)
+
+	switch name {
+	case "supported_groups":
+		curves := []interface{}{}
+		for _, c := range withoutGREASE(Uint16List(raw.Data, 2)) {
+			curves = append(curves, CurveName(utls.CurveID(c)))
+		}
+		ext.Data = map[string]interface{}{"curves": curves}
+	case "ec_point_formats":
+		var formats cryptobyte.String
+		if !body.ReadUint8LengthPrefixed(&formats) {
+			return ext, "", malformed
+		}
+		ext.Data = map[string]interface{}{"formats": numbers8(formats)}
+	case "signature_algorithms", "signature_algorithms_cert":
+		algorithms := []interface{}{}
+		for _, a := range withoutGREASE(Uint16List(raw.Data, 2)) {
+			algorithms = append(algorithms, SignatureAlgorithmName(utls.SignatureScheme(a)))
+		}
+		ext.Data = map[string]interface{}{"algorithms": algorithms}
+	case "application_layer_protocol_negotiation", "application_settings", "application_settings_new":
+		protocols := []interface{}{}
+		for _, p := range ProtocolList(raw.Data) {
+			protocols = append(protocols, p)
+		}
+		ext.Data = map[string]interface{}{"protocols": protocols}
+	case "key_share":
+		var shares cryptobyte.String
+		if !body.ReadUint16LengthPrefixed(&shares) {
+			return ext, "", malformed
+		}
+		groups := []interface{}{}
+		for !shares.Empty() {
+			var group uint16
+			var key cryptobyte.String
+			if !shares.ReadUint16(&group) || !shares.ReadUint16LengthPrefixed(&key) {
+				return ext, "", malformed
+			}
+			if !IsGREASE(group) {
+				groups = append(groups, CurveName(utls.CurveID(group)))
+			}
+		}
+		ext.Data = map[string]interface{}{"groups": groups}
+	case "psk_key_exchange_modes":
+		var modes cryptobyte.String
+		if !body.ReadUint8LengthPrefixed(&modes) {
+			return ext, "", malformed
+		}
+		ext.Data = map[string]interface{}{"modes": numbers8(modes)}
+	case "supported_versions":
+		versions := []interface{}{}
+		for _, v := range withoutGREASE(Uint16List(raw.Data, 1)) {
+			versions = append(versions, RawID(v))
+		}
+		ext.Data = map[string]interface{}{"versions": versions}
+	case "compress_certificate":
+		algorithms := []interface{}{}
+		for _, a := range Uint16List(raw.Data, 1) {
+			algorithms = append(algorithms, float64(a))
+		}
+		ext.Data = map[string]interface{}{"algorithms": algorithms}
+	case "padding":
+		ext.Data = map[string]interface{}{"length": float64(len(raw.Data))}
+		return ext, "padding.length is the captured value; it depends on SNI length and key shares", nil
+	case "pre_shared_key":
+		var identities, identity, binders, binder cryptobyte.String
+		var age uint32
+		if !body.ReadUint16LengthPrefixed(&identities) ||
+			!identities.ReadUint16LengthPrefixed(&identity) || !identities.ReadUint32(&age) ||
+			!body.ReadUint16LengthPrefixed(&binders) || !binders.ReadUint8LengthPrefixed(&binder) {
+			return ext, "", malformed
+		}
+		ext.Data = map[string]interface{}{
+			"identity_length": float64(len(identity)),
+			"binder_length":   float64(len(binder)),
+		}
+		return ext, "pre_shared_key is replayed with random identity and binder bytes", nil
+	case "encrypted_client_hello":
+		var echType uint8
+		var kdf, aead uint16
+		var configID uint8
+		var enc, payload cryptobyte.String
+		if !body.ReadUint8(&echType) || echType != 0 ||
+			!body.ReadUint16(&kdf) || !body.ReadUint16(&aead) || !body.ReadUint8(&configID) ||
+			!body.ReadUint16LengthPrefixed(&enc) || !body.ReadUint16LengthPrefixed(&payload) {
+			return ext, "", malformed
+		}
+		if len(payload) < 16 {
+			// Too short to hold the AEAD tag: keep the captured bytes
+			ext.Name = RawID(raw.Type)
+			ext.Data = map[string]interface{}{"data": hex.EncodeToString(raw.Data)}
+			return ext, fmt.Sprintf("encrypted_client_hello payload is %d bytes, shorter than an AEAD tag; its body is replayed as-is", len(payload)), nil
+		}
+		ext.Data = map[string]interface{}{
+			"cipher_suites": []interface{}{
+				map[string]interface{}{"kdf_id": float64(kdf), "aead_id": float64(aead)},
+			},
+			// The payload carries a 16-byte AEAD tag on top of the candidate length
+			"payload_length": float64(len(payload) - 16),
+		}
+		return ext, "encrypted_client_hello is sent as GREASE ECH", nil
+	case "server_name", "status_request", "signed_certificate_timestamp", "encrypt_then_mac",
+		"extended_master_secret", "session_ticket", "renegotiation_info":
+		// Bodies are generated by Build (SNI from the URL, empty otherwise)
+	default:
+		if len(raw.Data) > 0 {
+			ext.Data = map[string]interface{}{"data": hex.EncodeToString(raw.Data)}
+		}
+		return ext, fmt.Sprintf("extension %s has no name; its body is replayed as-is", name), nil
+	}
+	return ext, "", nil
+}
+
+func numbers8(b []byte) []interface{} {
+	nums := make([]interface{}, len(b))
+	for i, v := range b {
+		nums[i] = float64(v)
+	}
+	return nums
+}
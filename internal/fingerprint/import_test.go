@@ -0,0 +1,154 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+
+	"golang.org/x/crypto/cryptobyte"
+)
+
+func TestFromClientHello(t *testing.T) {
+	want, err := HashClientHello(marshalHello(chromeHello))
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg, notes, err := FromClientHello(chromeHello)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !cfg.GREASE || !cfg.HTTP2 {
+		t.Errorf("grease = %v, http2 = %v, want both true", cfg.GREASE, cfg.HTTP2)
+	}
+	if cfg.TLSVersionMin != "0x0303" || cfg.TLSVersionMax != "0x0304" {
+		t.Errorf("versions %s-%s, want 0x0303-0x0304", cfg.TLSVersionMin, cfg.TLSVersionMax)
+	}
+	if !hasNote(notes, "GREASE extensions are not at the positions Build uses") {
+		t.Errorf("no note about the GREASE before padding: %q", notes)
+	}
+
+	spec, err := Build(cfg, "https://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := Hash(spec, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.JA3 != want.JA3 || got.JA4 != want.JA4 {
+		t.Errorf("rebuilt hello:\nJA3 %s\nJA4 %s\nwant\nJA3 %s\nJA4 %s", got.JA3, got.JA4, want.JA3, want.JA4)
+	}
+}
+
+// echExtension is an outer ECH extension body with a payloadLen-byte payload.
+func echExtension(payloadLen int) RawExtension {
+	var b cryptobyte.Builder
+	b.AddUint8(0)
+	b.AddUint16(0x0001) // HKDF-SHA256
+	b.AddUint16(0x0001) // AES-128-GCM
+	b.AddUint8(0x2a)
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(make([]byte, 32)) })
+	b.AddUint16LengthPrefixed(func(b *cryptobyte.Builder) { b.AddBytes(make([]byte, payloadLen)) })
+	return RawExtension{Type: 0xfe0d, Data: b.BytesOrPanic()}
+}
+
+func TestFromClientHelloECH(t *testing.T) {
+	tests := []struct {
+		name       string
+		payloadLen int
+		wantName   string
+		wantKey    string
+		note       string
+	}{
+		{"GREASE ECH", 144 + 16, "encrypted_client_hello", "payload_length", "sent as GREASE ECH"},
+		{"tag-sized payload", 16, "encrypted_client_hello", "payload_length", "sent as GREASE ECH"},
+		{"short payload", 15, "0xfe0d", "data", "shorter than an AEAD tag"},
+		{"empty payload", 0, "0xfe0d", "data", "shorter than an AEAD tag"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ech := echExtension(tt.payloadLen)
+			cfg, notes, err := FromClientHello(&ClientHello{
+				Version:      0x0303,
+				CipherSuites: []uint16{0x1301},
+				Extensions:   []RawExtension{ech},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			ext := cfg.Extensions[0]
+			if ext.Name != tt.wantName {
+				t.Fatalf("name %s, want %s", ext.Name, tt.wantName)
+			}
+			if _, ok := ext.Data[tt.wantKey]; !ok {
+				t.Errorf("data %v has no %s", ext.Data, tt.wantKey)
+			}
+			if ext.Name == "encrypted_client_hello" && ext.Data["payload_length"] != float64(tt.payloadLen-16) {
+				t.Errorf("payload_length %v, want %d", ext.Data["payload_length"], tt.payloadLen-16)
+			}
+			if !hasNote(notes, tt.note) {
+				t.Errorf("notes %q lack %q", notes, tt.note)
+			}
+
+			// The fallback must replay the captured body byte for byte.
+			if ext.Name == "0xfe0d" {
+				spec, err := Build(cfg, "https://example.com/")
+				if err != nil {
+					t.Fatal(err)
+				}
+				raw, err := MarshalClientHello(spec, "example.com")
+				if err != nil {
+					t.Fatal(err)
+				}
+				hello, err := ParseClientHello(raw)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data, ok := hello.Extension(0xfe0d); !ok || string(data) != string(ech.Data) {
+					t.Errorf("replayed body %x, want %x", data, ech.Data)
+				}
+			}
+		})
+	}
+}
+
+func TestFromClientHelloGREASECipherNote(t *testing.T) {
+	_, notes, err := FromClientHello(&ClientHello{
+		Version:      0x0303,
+		CipherSuites: []uint16{0x1301, 0x0a0a, 0x1302, 0x1a1a, 0x1303},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 0
+	for _, note := range notes {
+		if strings.Contains(note, "GREASE cipher is not first") {
+			n++
+		}
+	}
+	if n != 1 {
+		t.Errorf("GREASE cipher note appears %d times, want once: %q", n, notes)
+	}
+}
+
+func TestFromClientHelloMalformed(t *testing.T) {
+	for _, ext := range []RawExtension{
+		{Type: 0x000b}, // ec_point_formats
+		{Type: 0x0033, Data: []byte{0, 4, 0, 0x1d}}, // key_share
+		{Type: 0x002d},                  // psk_key_exchange_modes
+		{Type: 0x0029, Data: []byte{0}}, // pre_shared_key
+		{Type: 0xfe0d, Data: []byte{1}}, // encrypted_client_hello, inner type
+	} {
+		if _, _, err := FromClientHello(&ClientHello{Version: 0x0303, Extensions: []RawExtension{ext}}); err == nil {
+			t.Errorf("extension %#04x %x: no error", ext.Type, ext.Data)
+		}
+	}
+}
+
+func hasNote(notes []string, s string) bool {
+	for _, note := range notes {
+		if strings.Contains(note, s) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,150 @@
+package fingerprint
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// FromJA3 converts a JA3 string ("version,ciphers,extensions,curves,formats")
+// into a FingerprintConfig that Build accepts. Ciphers, groups and extensions
+// are named through the mapping tables; anything without a name is kept as a
+// raw "0x" entry. The returned notes list every field JA3 cannot express and
+// that was left at its BuildExtension default, so it can be filled in by hand.
+func FromJA3(ja3 string) (*config.FingerprintConfig, []string, error) {
+	fields := strings.Split(strings.TrimSpace(ja3), ",")
+	if len(fields) != 5 {
+		return nil, nil, fmt.Errorf("invalid JA3: expected 5 comma-separated fields, got %d", len(fields))
+	}
+
+	version, err := strconv.ParseUint(fields[0], 10, 16)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JA3 version %q", fields[0])
+	}
+	ciphers, err := parseJA3List(fields[1])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JA3 ciphers: %v", err)
+	}
+	extensions, err := parseJA3List(fields[2])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JA3 extensions: %v", err)
+	}
+	curves, err := parseJA3List(fields[3])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JA3 curves: %v", err)
+	}
+	formats, err := parseJA3List(fields[4])
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid JA3 point formats: %v", err)
+	}
+
+	cfg := &config.FingerprintConfig{
+		TLSVersionMin:      RawID(uint16(version)),
+		TLSVersionMax:      RawID(uint16(version)),
+		CompressionMethods: config.ByteList{0},
+	}
+	notes := []string{}
+
+	// JA3 normally strips GREASE; if the capture kept it, let Build re-insert
+	// it at its usual positions instead of pinning the observed values.
+	sawGREASE := false
+	for _, c := range ciphers {
+		if IsGREASE(c) {
+			sawGREASE = true
+			continue
+		}
+		cfg.Ciphers = append(cfg.Ciphers, CipherName(c))
+	}
+
+	for _, id := range extensions {
+		if IsGREASE(id) {
+			sawGREASE = true
+			continue
+		}
+		name := ExtensionName(id)
+		ext := config.ExtensionConfig{Name: name}
+
+		switch name {
+		case "supported_groups":
+			names := []interface{}{}
+			for _, c := range curves {
+				if IsGREASE(c) {
+					sawGREASE = true
+					continue
+				}
+				names = append(names, CurveName(utls.CurveID(c)))
+			}
+			ext.Data = map[string]interface{}{"curves": names}
+		case "ec_point_formats":
+			nums := []interface{}{}
+			for _, f := range formats {
+				nums = append(nums, float64(f))
+			}
+			ext.Data = map[string]interface{}{"formats": nums}
+		case "supported_versions":
+			// The JA3 version is the legacy ClientHello version (0x0303 for
+			// TLS 1.3 clients); the real range lives in this extension.
+			cfg.TLSVersionMax = RawID(utls.VersionTLS13)
+			ext.Data = map[string]interface{}{
+				"versions": []interface{}{RawID(utls.VersionTLS13), RawID(utls.VersionTLS12)},
+			}
+			notes = append(notes, "supported_versions.versions: not in JA3, assumed [0x0304, 0x0303]")
+		case "signature_algorithms", "signature_algorithms_cert":
+			notes = append(notes, name+".algorithms: not in JA3, extension is empty until filled in")
+		case "application_layer_protocol_negotiation":
+			cfg.HTTP2 = true
+			notes = append(notes, name+".protocols: not in JA3, defaults to [h2, http/1.1]")
+		case "application_settings", "application_settings_new":
+			notes = append(notes, name+".protocols: not in JA3, defaults to [h2]")
+		case "key_share":
+			notes = append(notes, "key_share.groups: not in JA3, defaults to [X25519]")
+		case "psk_key_exchange_modes":
+			notes = append(notes, "psk_key_exchange_modes.modes: not in JA3, defaults to [1]")
+		case "compress_certificate":
+			notes = append(notes, "compress_certificate.algorithms: not in JA3, defaults to [2] (brotli)")
+		case "padding":
+			notes = append(notes, "padding.length: not in JA3, defaults to 0")
+		case "pre_shared_key":
+			notes = append(notes, "pre_shared_key: identity/binder lengths not in JA3, default to 138/32")
+		case "encrypted_client_hello":
+			notes = append(notes, "encrypted_client_hello: payload lengths not in JA3, utls defaults apply")
+		default:
+			if _, known := ExtensionMap[name]; !known {
+				notes = append(notes, fmt.Sprintf("extension %s has no name; emitted as a raw extension with an empty body", name))
+			}
+		}
+		cfg.Extensions = append(cfg.Extensions, ext)
+	}
+
+	if sawGREASE {
+		cfg.GREASE = true
+		notes = append(notes, "grease: GREASE values found and removed; Build re-inserts them at its standard positions")
+	} else {
+		notes = append(notes, "grease: JA3 strips GREASE, set it to true if the client sends GREASE values")
+	}
+	if !cfg.HTTP2 {
+		notes = append(notes, "http2: no ALPN extension, HTTP/2 disabled")
+	}
+
+	return cfg, notes, nil
+}
+
+func parseJA3List(field string) ([]uint16, error) {
+	if field == "" {
+		return nil, nil
+	}
+	parts := strings.Split(field, "-")
+	values := make([]uint16, 0, len(parts))
+	for _, p := range parts {
+		v, err := strconv.ParseUint(p, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("bad value %q", p)
+		}
+		values = append(values, uint16(v))
+	}
+	return values, nil
+}
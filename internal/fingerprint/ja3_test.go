@@ -0,0 +1,87 @@
+package fingerprint
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFromJA3(t *testing.T) {
+	tests := []struct {
+		name   string
+		ja3    string
+		want   string // JA3 of the rebuilt ClientHello; "" means ja3 itself
+		grease bool
+		http2  bool
+	}{
+		{
+			name:  "chrome",
+			ja3:   "771,4865-4866-4867-49195-49199-49196-49200-52393-52392-49171-49172-156-157-47-53,0-23-65281-10-11-35-16-5-13-18-51-45-43-27-17513,29-23-24,0",
+			http2: true,
+		},
+		{
+			name:   "GREASE removed",
+			ja3:    "771,2570-4865-4866,2570-0-10-11-43-51-6682,2570-29-23,0",
+			want:   "771,4865-4866,0-10-11-43-51,29-23,0",
+			grease: true,
+		},
+		{
+			name: "TLS 1.2 only",
+			ja3:  "771,49195-49199,0-10-11-13,23-24,0",
+		},
+		{
+			name: "unnamed extension",
+			ja3:  "771,4865,0-10-11-1234,29,0",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, notes, err := FromJA3(tt.ja3)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if cfg.GREASE != tt.grease {
+				t.Errorf("grease = %v, want %v", cfg.GREASE, tt.grease)
+			}
+			if cfg.HTTP2 != tt.http2 {
+				t.Errorf("http2 = %v, want %v", cfg.HTTP2, tt.http2)
+			}
+			if len(notes) == 0 {
+				t.Error("no notes about fields JA3 cannot express")
+			}
+
+			spec, err := Build(cfg, "https://example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			h, err := Hash(spec, "example.com")
+			if err != nil {
+				t.Fatal(err)
+			}
+			want := tt.want
+			if want == "" {
+				want = tt.ja3
+			}
+			if h.JA3 != want {
+				t.Errorf("rebuilt JA3:\ngot  %s\nwant %s", h.JA3, want)
+			}
+		})
+	}
+}
+
+func TestFromJA3Invalid(t *testing.T) {
+	tests := []struct {
+		ja3, err string
+	}{
+		{"771,4865,0-10,29", "expected 5 comma-separated fields"},
+		{"tls,4865,0,29,0", "invalid JA3 version"},
+		{"771,4865-x,0,29,0", "invalid JA3 ciphers"},
+		{"771,4865,0-70000,29,0", "invalid JA3 extensions"},
+		{"771,4865,0-10,29-,0", "invalid JA3 curves"},
+		{"771,4865,0-11,29,a", "invalid JA3 point formats"},
+	}
+	for _, tt := range tests {
+		if _, _, err := FromJA3(tt.ja3); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("FromJA3(%q) error = %v, want %q", tt.ja3, err, tt.err)
+		}
+	}
+}
@@ -1,6 +1,10 @@
 package fingerprint
 
 import (
+	"fmt"
+	"strconv"
+	"strings"
+
 	utls "github.com/refraction-networking/utls"
 )
 
@@ -171,3 +175,118 @@ var SignatureAlgorithmMap = map[string]utls.SignatureScheme{
 	"mldsa65_ecdsa256": utls.SignatureScheme(0x0905),
 	"mldsa87_ecdsa384": utls.SignatureScheme(0x0906),
 }
+
+var ExtensionMap = map[string]uint16{
+	"server_name":                            0x0000,
+	"status_request":                         0x0005,
+	"supported_groups":                       0x000a,
+	"ec_point_formats":                       0x000b,
+	"signature_algorithms":                   0x000d,
+	"application_layer_protocol_negotiation": 0x0010,
+	"signed_certificate_timestamp":           0x0012,
+	"padding":                                0x0015,
+	"encrypt_then_mac":                       0x0016,
+	"extended_master_secret":                 0x0017,
+	"compress_certificate":                   0x001b,
+	"session_ticket":                         0x0023,
+	"pre_shared_key":                         0x0029,
+	"supported_versions":                     0x002b,
+	"psk_key_exchange_modes":                 0x002d,
+	"signature_algorithms_cert":              0x0032,
+	"key_share":                              0x0033,
+	"application_settings":                   0x4469, // 17513, old ALPS codepoint
+	"application_settings_new":               0x44cd, // 17613, Chrome 133+
+	"encrypted_client_hello":                 0xfe0d,
+	"renegotiation_info":                     0xff01,
+}
+
+// LookupCipher resolves a cipher name from CipherMap, or a raw "0x" value.
+func LookupCipher(name string) (uint16, bool) {
+	if id, ok := CipherMap[name]; ok {
+		return id, true
+	}
+	return parseRawID(name)
+}
+
+// LookupCurve resolves a group name from CurveMap, or a raw "0x" value.
+func LookupCurve(name string) (utls.CurveID, bool) {
+	if id, ok := CurveMap[name]; ok {
+		return id, true
+	}
+	id, ok := parseRawID(name)
+	return utls.CurveID(id), ok
+}
+
+// LookupSignatureAlgorithm resolves a scheme name from SignatureAlgorithmMap,
+// or a raw "0x" value.
+func LookupSignatureAlgorithm(name string) (utls.SignatureScheme, bool) {
+	if id, ok := SignatureAlgorithmMap[name]; ok {
+		return id, true
+	}
+	id, ok := parseRawID(name)
+	return utls.SignatureScheme(id), ok
+}
+
+// CipherName returns the config name for a cipher suite, or its raw "0x" form.
+func CipherName(id uint16) string {
+	return nameFor(CipherMap, id, func(a, b string) bool { return a < b })
+}
+
+// CurveName returns the config name for a group. Capitalised names
+// ("X25519", "CurveP256") win over their aliases.
+func CurveName(id utls.CurveID) string {
+	return nameFor(CurveMap, id, func(a, b string) bool { return a < b })
+}
+
+// SignatureAlgorithmName returns the config name for a signature scheme.
+// TLS 1.3 style names ("ecdsa_secp256r1_sha256") win over the Go style ones.
+func SignatureAlgorithmName(id utls.SignatureScheme) string {
+	return nameFor(SignatureAlgorithmMap, id, func(a, b string) bool {
+		aLower, bLower := a[0] >= 'a', b[0] >= 'a'
+		if aLower != bLower {
+			return aLower
+		}
+		return a < b
+	})
+}
+
+// ExtensionName returns the config name for an extension type. Unknown
+// types come back in raw "0x" form, which BuildExtension accepts as a
+// generic extension.
+func ExtensionName(id uint16) string {
+	return nameFor(ExtensionMap, id, func(a, b string) bool { return a < b })
+}
+
+// RawID formats a numeric TLS identifier the way configs spell raw values.
+func RawID(id uint16) string {
+	return fmt.Sprintf("0x%04x", id)
+}
+
+// IsGREASE reports whether v is one of the RFC 8701 reserved values.
+func IsGREASE(v uint16) bool {
+	return v&0x0f0f == 0x0a0a && v>>8 == v&0xff
+}
+
+func nameFor[T ~uint16](m map[string]T, id T, better func(a, b string) bool) string {
+	name := ""
+	for k, v := range m {
+		if v == id && (name == "" || better(k, name)) {
+			name = k
+		}
+	}
+	if name == "" {
+		return RawID(uint16(id))
+	}
+	return name
+}
+
+func parseRawID(s string) (uint16, bool) {
+	if !strings.HasPrefix(s, "0x") && !strings.HasPrefix(s, "0X") {
+		return 0, false
+	}
+	v, err := strconv.ParseUint(s[2:], 16, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(v), true
+}
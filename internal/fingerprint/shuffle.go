@@ -0,0 +1,53 @@
+package fingerprint
+
+import (
+	crand "crypto/rand"
+	"encoding/binary"
+	"math/rand/v2"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// pinnedExtensions never move when shuffling, as in BoringSSL: GREASE keeps
+// its first/last slots, padding and pre_shared_key stay at the end.
+var pinnedExtensions = map[string]bool{
+	"GREASE":         true,
+	"padding":        true,
+	"pre_shared_key": true,
+}
+
+// shuffleExtensions permutes exts in place the way Chrome 110+ does on every
+// handshake. names[i] is the config name of exts[i]; extensions listed in
+// cfg.ShuffleFixed keep their position too. With cfg.ShuffleSeed set the
+// permutation is the same on every call.
+func shuffleExtensions(cfg *config.FingerprintConfig, exts []utls.TLSExtension, names []string) {
+	fixed := map[string]bool{}
+	for _, name := range cfg.ShuffleFixed {
+		fixed[name] = true
+	}
+
+	movable := []int{}
+	for i, name := range names {
+		if !pinnedExtensions[name] && !fixed[name] {
+			movable = append(movable, i)
+		}
+	}
+
+	var seed uint64
+	if cfg.ShuffleSeed != nil {
+		seed = uint64(*cfg.ShuffleSeed)
+	} else {
+		var b [8]byte
+		crand.Read(b[:])
+		seed = binary.LittleEndian.Uint64(b[:])
+	}
+	rng := rand.New(rand.NewPCG(seed, seed))
+
+	rng.Shuffle(len(movable), func(i, j int) {
+		a, b := movable[i], movable[j]
+		exts[a], exts[b] = exts[b], exts[a]
+		names[a], names[b] = names[b], names[a]
+	})
+}
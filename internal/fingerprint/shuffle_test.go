@@ -0,0 +1,99 @@
+package fingerprint
+
+import (
+	"slices"
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// shuffled runs shuffleExtensions over names, checking each extension
+// moved together with its name.
+func shuffled(t *testing.T, cfg *config.FingerprintConfig, names []string) []string {
+	t.Helper()
+	out := slices.Clone(names)
+	exts := make([]utls.TLSExtension, len(names))
+	for i := range exts {
+		exts[i] = &utls.GenericExtension{Id: uint16(i)}
+	}
+	shuffleExtensions(cfg, exts, out)
+	for i, ext := range exts {
+		if orig := names[ext.(*utls.GenericExtension).Id]; orig != out[i] {
+			t.Fatalf("slot %d holds extension %s but name %s", i, orig, out[i])
+		}
+	}
+	return out
+}
+
+var shuffleTestNames = []string{
+	"GREASE", "server_name", "extended_master_secret", "renegotiation_info",
+	"supported_groups", "ec_point_formats", "session_ticket", "alpn",
+	"status_request", "signature_algorithms", "signed_certificate_timestamp",
+	"key_share", "psk_key_exchange_modes", "supported_versions",
+	"compress_certificate", "application_settings", "GREASE", "padding",
+	"pre_shared_key",
+}
+
+func TestShuffleExtensions(t *testing.T) {
+	seed := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name  string
+		cfg   config.FingerprintConfig
+		fixed []string // names expected in their original slot
+	}{
+		{"seeded", config.FingerprintConfig{ShuffleSeed: seed(1)}, nil},
+		{"zero seed", config.FingerprintConfig{ShuffleSeed: seed(0)}, nil},
+		{"negative seed", config.FingerprintConfig{ShuffleSeed: seed(-42)}, nil},
+		{"shuffle_fixed", config.FingerprintConfig{ShuffleSeed: seed(7), ShuffleFixed: []string{"server_name", "key_share"}}, []string{"server_name", "key_share"}},
+		{"unseeded", config.FingerprintConfig{}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := shuffled(t, &tt.cfg, shuffleTestNames)
+
+			for i, name := range shuffleTestNames {
+				if (pinnedExtensions[name] || slices.Contains(tt.fixed, name)) && got[i] != name {
+					t.Errorf("%s moved from slot %d: got %v", name, i, got)
+				}
+			}
+			if sorted, want := slices.Sorted(slices.Values(got)), slices.Sorted(slices.Values(shuffleTestNames)); !slices.Equal(sorted, want) {
+				t.Errorf("shuffle lost or duplicated extensions: %v", got)
+			}
+
+			if tt.cfg.ShuffleSeed != nil {
+				if again := shuffled(t, &tt.cfg, shuffleTestNames); !slices.Equal(got, again) {
+					t.Errorf("seeded order not stable:\n%v\n%v", got, again)
+				}
+			}
+		})
+	}
+}
+
+func TestShuffleExtensionsSeedsDiffer(t *testing.T) {
+	a, b := int64(1), int64(2)
+	if slices.Equal(
+		shuffled(t, &config.FingerprintConfig{ShuffleSeed: &a}, shuffleTestNames),
+		shuffled(t, &config.FingerprintConfig{ShuffleSeed: &b}, shuffleTestNames),
+	) {
+		t.Error("seeds 1 and 2 gave the same order")
+	}
+}
+
+// A given shuffle_seed must give the same order across releases, or saved
+// configs would silently change fingerprint.
+func TestShuffleExtensionsSeedOrder(t *testing.T) {
+	seed := int64(1)
+	want := []string{
+		"GREASE", "alpn", "server_name", "ec_point_formats", "renegotiation_info",
+		"signature_algorithms", "compress_certificate", "supported_versions",
+		"session_ticket", "status_request", "signed_certificate_timestamp",
+		"supported_groups", "key_share", "psk_key_exchange_modes",
+		"extended_master_secret", "application_settings", "GREASE", "padding",
+		"pre_shared_key",
+	}
+	if got := shuffled(t, &config.FingerprintConfig{ShuffleSeed: &seed}, shuffleTestNames); !slices.Equal(got, want) {
+		t.Errorf("seed 1:\ngot  %q\nwant %q", got, want)
+	}
+}
@@ -0,0 +1,56 @@
+package mitm
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Rule chooses how traffic to matching hosts is re-originated. Rules are
+// tried in order; the first match wins.
+type Rule struct {
+	// Host is a hostname or glob: "api.example.com", "*.example.com", "*".
+	Host string `json:"host"`
+	// Profile and Config select the fingerprint, as in a request.
+	Profile string `json:"profile,omitempty"`
+	Config  string `json:"config,omitempty"`
+	// HeaderOrder and PseudoHeaderOrder override those of the config.
+	HeaderOrder       []string `json:"header_order,omitempty"`
+	PseudoHeaderOrder []string `json:"pseudo_header_order,omitempty"`
+	// Passthrough tunnels CONNECT traffic untouched (for certificate-pinned
+	// clients) instead of terminating TLS.
+	Passthrough bool `json:"passthrough,omitempty"`
+}
+
+type Rules []Rule
+
+// LoadRules reads a JSON array of rules.
+func LoadRules(file string) (Rules, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules file: %v", err)
+	}
+	for _, r := range rules {
+		if _, err := path.Match(strings.ToLower(r.Host), ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %v", r.Host, err)
+		}
+	}
+	return rules, nil
+}
+
+// Match returns the first rule whose pattern matches host, or nil.
+func (rules Rules) Match(host string) *Rule {
+	host = strings.ToLower(host)
+	for i := range rules {
+		if ok, _ := path.Match(strings.ToLower(rules[i].Host), host); ok {
+			return &rules[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,143 @@
+package profiles
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fingerPrintRequester/internal/config"
+)
+
+//go:embed data/*.json
+var dataFS embed.FS
+
+// Profile is a built-in browser/client fingerprint. Names are
+// "<family>_<version>" (chrome_141, curl_openssl_3.5); the bare family name
+// or "<family>_latest" selects the highest version.
+type Profile struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description"`
+	Fingerprint config.FingerprintConfig `json:"fingerprint"`
+	HTTP2       *config.HTTP2Config      `json:"http2,omitempty"`
+
+	HeaderOrder       []string `json:"header_order,omitempty"`
+	PseudoHeaderOrder []string `json:"pseudo_header_order,omitempty"`
+}
+
+// registry holds each profile's embedded JSON by name. Profiles are
+// decoded afresh for every lookup, so a caller changing the slices and maps
+// of one (say, after Apply) cannot change it for anyone else.
+var registry = map[string][]byte{}
+
+func init() {
+	entries, err := dataFS.ReadDir("data")
+	if err != nil {
+		panic(err)
+	}
+	for _, entry := range entries {
+		data, err := dataFS.ReadFile(path.Join("data", entry.Name()))
+		if err != nil {
+			panic(err)
+		}
+		p, err := decode(data)
+		if err != nil {
+			panic(fmt.Sprintf("profile %s: %v", entry.Name(), err))
+		}
+		registry[p.Name] = data
+	}
+}
+
+func decode(data []byte) (*Profile, error) {
+	var p Profile
+	if err := json.Unmarshal(data, &p); err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// Get looks up a profile by exact name, family name or "<family>_latest".
+// The profile returned is the caller's own copy.
+func Get(name string) (*Profile, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if data, ok := registry[name]; ok {
+		return decode(data)
+	}
+	family := strings.TrimSuffix(name, "_latest")
+	var latest *Profile
+	for _, p := range List() {
+		if p.Family() == family && (latest == nil || versionLess(latest.Version(), p.Version())) {
+			latest = p
+		}
+	}
+	if latest == nil {
+		return nil, fmt.Errorf("unknown profile: %s", name)
+	}
+	return latest, nil
+}
+
+// List returns copies of all profiles sorted by family, then version.
+func List() []*Profile {
+	list := make([]*Profile, 0, len(registry))
+	for _, data := range registry {
+		p, err := decode(data)
+		if err != nil {
+			panic(err) // decoded once already in init
+		}
+		list = append(list, p)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].Family() != list[j].Family() {
+			return list[i].Family() < list[j].Family()
+		}
+		return versionLess(list[i].Version(), list[j].Version())
+	})
+	return list
+}
+
+// Family is the name without its version suffix.
+func (p *Profile) Family() string {
+	if i := strings.LastIndex(p.Name, "_"); i >= 0 {
+		return p.Name[:i]
+	}
+	return p.Name
+}
+
+// Version is the dotted version suffix of the name.
+func (p *Profile) Version() string {
+	if i := strings.LastIndex(p.Name, "_"); i >= 0 {
+		return p.Name[i+1:]
+	}
+	return ""
+}
+
+// Apply replaces the fingerprint, http2 and header order sections of cfg
+// with the profile's.
+func (p *Profile) Apply(cfg *config.Config) {
+	cfg.Fingerprint = p.Fingerprint
+	if p.HTTP2 != nil {
+		cfg.HTTP2 = *p.HTTP2
+	}
+	if p.HeaderOrder != nil {
+		cfg.HeaderOrder = p.HeaderOrder
+	}
+	if p.PseudoHeaderOrder != nil {
+		cfg.PseudoHeaderOrder = p.PseudoHeaderOrder
+	}
+}
+
+// versionLess compares dotted numeric versions ("3.5" < "3.10").
+func versionLess(a, b string) bool {
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, _ := strconv.Atoi(as[i])
+		bn, _ := strconv.Atoi(bs[i])
+		if an != bn {
+			return an < bn
+		}
+	}
+	return len(as) < len(bs)
+}
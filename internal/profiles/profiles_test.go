@@ -0,0 +1,114 @@
+package profiles
+
+import (
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/fingerprint"
+)
+
+// TestProfileHashes pins the JA4 and Akamai fingerprint of every bundled
+// profile. Both are independent of extension shuffling and GREASE values,
+// so a change here means a profile's data changed. Profiles of the same
+// Chromium version must agree.
+func TestProfileHashes(t *testing.T) {
+	tests := []struct {
+		name, ja4, akamaiHash string
+	}{
+		{"chrome_120", "t13d1516h2_8daaf6152771_02713d6af862", "52d84b11737d980aef856699f885ca86"},
+		{"chrome_131", "t13d1516h2_8daaf6152771_02713d6af862", "52d84b11737d980aef856699f885ca86"},
+		{"chrome_141", "t13d1516h2_8daaf6152771_d8a2da3f94cd", "52d84b11737d980aef856699f885ca86"},
+		{"curl_openssl_1.1.1", "t13d3112h2_e8f1e7e78f70_fb8d5ffd48c1", "7326ddc7c23342895575fa6045975834"},
+		{"curl_openssl_3.0", "t13d3112h2_e8f1e7e78f70_4a0154eed145", "7326ddc7c23342895575fa6045975834"},
+		{"curl_openssl_3.5", "t13d521200_b262b3658495_66863fb0a24c", "7326ddc7c23342895575fa6045975834"},
+		{"edge_120", "t13d1516h2_8daaf6152771_02713d6af862", "52d84b11737d980aef856699f885ca86"},
+		{"edge_131", "t13d1516h2_8daaf6152771_02713d6af862", "52d84b11737d980aef856699f885ca86"},
+		{"edge_141", "t13d1516h2_8daaf6152771_d8a2da3f94cd", "52d84b11737d980aef856699f885ca86"},
+		{"firefox_120", "t13d1715h2_5b57614c22b0_5c2c66f702b0", "6ea73faa8fc5aac76bded7bd238f6433"},
+		{"firefox_133", "t13d1716h2_5b57614c22b0_eeeea6562960", "6ea73faa8fc5aac76bded7bd238f6433"},
+		{"okhttp_3", "t12d1209h2_d34a8e72043a_b39be8c56a14", "605a1154008045d7e3cb3c6fb062c0ce"},
+		{"okhttp_4", "t13d1512h2_8daaf6152771_40271e0a5736", "605a1154008045d7e3cb3c6fb062c0ce"},
+		{"safari_15", "t13d2012h2_a09f3c656075_38ba08824cc9", "dda308d35f4e5db7b52a61720ca1b122"},
+		{"safari_18", "t13d2014h2_a09f3c656075_14788d8d241b", "c52879e43202aeb92740be6e8c86ea96"},
+	}
+	if len(tests) != len(List()) {
+		t.Errorf("%d profiles pinned, %d bundled", len(tests), len(List()))
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p, err := Get(tt.name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			cfg := config.Default()
+			p.Apply(cfg)
+			h, err := fingerprint.ComputeHashes(cfg, "https://example.com/")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if h.JA4 != tt.ja4 {
+				t.Errorf("JA4 %s, want %s", h.JA4, tt.ja4)
+			}
+			if h.AkamaiHash != tt.akamaiHash {
+				t.Errorf("Akamai hash %s (%s), want %s", h.AkamaiHash, h.Akamai, tt.akamaiHash)
+			}
+		})
+	}
+}
+
+func TestGet(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"chrome_131", "chrome_131"},
+		{" Chrome_131 ", "chrome_131"},
+		{"chrome", "chrome_141"},
+		{"chrome_latest", "chrome_141"},
+		{"curl_openssl", "curl_openssl_3.5"},
+		{"firefox_latest", "firefox_133"},
+	}
+	for _, tt := range tests {
+		p, err := Get(tt.in)
+		if err != nil {
+			t.Errorf("Get(%q): %v", tt.in, err)
+			continue
+		}
+		if p.Name != tt.want {
+			t.Errorf("Get(%q) = %s, want %s", tt.in, p.Name, tt.want)
+		}
+	}
+	if _, err := Get("netscape"); err == nil {
+		t.Error("unknown profile found")
+	}
+}
+
+func TestGetReturnsCopy(t *testing.T) {
+	p, _ := Get("chrome_141")
+	p.Fingerprint.Ciphers[0] = "changed"
+	p.HeaderOrder[0] = "changed"
+	cfg := config.Default()
+	p.Apply(cfg)
+	cfg.Fingerprint.Extensions[0].Name = "changed"
+
+	again, _ := Get("chrome_141")
+	if again.Fingerprint.Ciphers[0] == "changed" || again.HeaderOrder[0] == "changed" || again.Fingerprint.Extensions[0].Name == "changed" {
+		t.Error("changing a returned profile changed the registry")
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		less bool
+	}{
+		{"3.5", "3.10", true},
+		{"3.10", "3.5", false},
+		{"1.1.1", "3.0", true},
+		{"3", "3.0", true},
+		{"141", "141", false},
+	} {
+		if got := versionLess(tt.a, tt.b); got != tt.less {
+			t.Errorf("versionLess(%s, %s) = %v", tt.a, tt.b, got)
+		}
+	}
+}
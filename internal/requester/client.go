@@ -2,10 +2,13 @@ package requester
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"io"
 	"net"
 	"net/http"
 	"net/url"
+	"os"
 	"strings"
 	"time"
 
@@ -13,105 +16,236 @@ import (
 	"fingerPrintRequester/internal/fingerprint"
 
 	utls "github.com/refraction-networking/utls"
-	"golang.org/x/net/http2"
 )
 
+// MakeRequest sends req and writes the response to stdout in req.Format.
+// Redirects, if followed, reuse the connection when they stay on the same
+// origin.
 func MakeRequest(req *config.Request, cfg *config.Config) error {
-	spec, err := fingerprint.Build(&cfg.Fingerprint, req.URL)
+	pool := NewPool()
+	defer pool.Close()
+
+	timer := NewTimer()
+	resp, err := pool.Do(WithTimer(context.Background(), timer), req, cfg)
+	if err == nil && req.Verbose {
+		printRedirects(RedirectChain(resp))
+	}
+	if req.Format == FormatNDJSON {
+		err = writeNDJSON(os.Stdout, resp, err, timer)
+	} else if err == nil {
+		err = ForwardResponse(resp)
+	}
+	if req.Verbose {
+		printTimings(timer)
+	}
+	return err
+}
+
+// newHTTPRequest converts req into an http.Request carrying its headers in
+// order (see HeaderOrderKey).
+func newHTTPRequest(req *config.Request) (*http.Request, error) {
+	httpReq, err := http.NewRequest(req.Method, req.URL, strings.NewReader(req.Body))
 	if err != nil {
-		return err
+		return nil, err
+	}
+	httpReq.Header = newHeader(req.Headers)
+	if host := req.Headers.Get("Host"); host != "" {
+		httpReq.Host = host
 	}
+	return httpReq, nil
+}
 
-	parsedURL, err := url.Parse(req.URL)
+// dialOrigin connects to the origin of u and, for https, runs the
+// fingerprinted TLS handshake. It returns the ALPN protocol negotiated.
+func dialOrigin(ctx context.Context, u *url.URL, cfg *config.Config, verbose bool) (net.Conn, string, error) {
+	spec, err := fingerprint.Build(&cfg.Fingerprint, u.String())
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	// Dial connection
-	port := parsedURL.Port()
-	if port == "" {
-		if parsedURL.Scheme == "https" {
-			port = "443"
-		} else {
-			port = "80"
-		}
+	addr, err := connectTarget(u, cfg)
+	if err != nil {
+		return nil, "", err
 	}
-	addr := net.JoinHostPort(parsedURL.Hostname(), port)
-
-	conn, err := DialWithProxy(addr, cfg)
+	conn, err := DialContext(ctx, addr, cfg)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
 	var negotiatedProtocol string
 	// TLS handshake with timeout
-	if parsedURL.Scheme == "https" {
+	if u.Scheme == "https" {
 		conn.SetReadDeadline(time.Now().Add(time.Duration(cfg.Timeout.Read) * time.Second))
-		tlsConfig := &utls.Config{
-			ServerName:         parsedURL.Hostname(),
-			InsecureSkipVerify: true,
+		tlsConfig, err := newUTLSConfig(cfg, u.Hostname())
+		if err != nil {
+			conn.Close()
+			return nil, "", err
 		}
 		uConn := utls.UClient(conn, tlsConfig, utls.HelloCustom)
 		if err := uConn.ApplyPreset(spec); err != nil {
 			conn.Close()
-			return err
+			return nil, "", err
 		}
-		if err := uConn.Handshake(); err != nil {
+		if err := uConn.HandshakeContext(ctx); err != nil {
 			conn.Close()
-			return err
+			return nil, "", certificateError(err, u.Hostname())
 		}
-		
+		timerFrom(ctx).handshook()
+
 		// Get negotiated protocol from ALPN
 		negotiatedProtocol = uConn.ConnectionState().NegotiatedProtocol
+		if verbose {
+			printFingerprint(uConn.HandshakeState.Hello.Raw, cfg)
+		}
 		conn = uConn
 	}
 
 	// Clear all timeouts for streaming
 	conn.SetReadDeadline(time.Time{})
 	conn.SetWriteDeadline(time.Time{})
+	return conn, negotiatedProtocol, nil
+}
 
-	// Send HTTP request
-	httpReq, err := http.NewRequest(req.Method, req.URL, strings.NewReader(req.Body))
-	if err != nil {
-		conn.Close()
-		return err
-	}
-	for k, v := range req.Headers {
-		httpReq.Header.Set(k, v)
-	}
-
-	// Check if HTTP/2 should be used based on config AND ALPN negotiation
-	// Only use HTTP/2 if it's enabled in config AND server negotiated "h2" via ALPN
-	useHTTP2 := cfg.Fingerprint.HTTP2 && negotiatedProtocol == "h2"
-	
-	if useHTTP2 {
-		// Use HTTP/2
-		transport := &http2.Transport{
-			DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
-				return conn, nil
-			},
-			// Don't allow fallback to HTTP/1.1 if we expect HTTP/2
-			AllowHTTP: false,
+// originAddr is host:port of u, with the scheme's default port.
+func originAddr(u *url.URL) string {
+	port := u.Port()
+	if port == "" {
+		if u.Scheme == "https" {
+			port = "443"
+		} else {
+			port = "80"
 		}
-		client := &http.Client{Transport: transport}
-		resp, err := client.Do(httpReq)
-		if err != nil {
-			conn.Close()
-			return err
+	}
+	return net.JoinHostPort(u.Hostname(), port)
+}
+
+// roundTripHTTP1 writes httpReq on conn and reads the response head from
+// br, which reads from conn through rec; the body is left to the caller.
+// The response's header names are recorded under HeaderOrderKey in the
+// order the server sent them.
+func roundTripHTTP1(conn net.Conn, br *bufio.Reader, rec *headRecorder, httpReq *http.Request, cfg *config.Config) (*http.Response, error) {
+	headers := wireHeaders(prepareHeaders(httpReq, true), cfg.HeaderOrder)
+	cw := &countingWriter{w: conn}
+	if err := writeRequest(cw, httpReq, headers); err != nil {
+		return nil, &noResponseError{err: err, wrote: cw.n > 0}
+	}
+	timer := timerFrom(httpReq.Context())
+	timer.wrote()
+	// Bytes already buffered were read before recording could start
+	if br.Buffered() == 0 {
+		rec.start()
+	}
+	if _, err := br.Peek(1); err != nil {
+		rec.stop()
+		return nil, &noResponseError{err: err, wrote: true}
+	}
+	timer.firstByte()
+	resp, err := http.ReadResponse(br, httpReq)
+	names := rec.stop()
+	if err != nil {
+		return nil, err
+	}
+	if names != nil {
+		resp.Header[HeaderOrderKey] = names
+	}
+	resp.TLS = connectionState(conn)
+	return resp, nil
+}
+
+// noResponseError is an HTTP/1.1 request that failed before any byte of
+// the response arrived, as one sent on a connection the server has since
+// closed does.
+type noResponseError struct {
+	err   error
+	wrote bool // whether any of the request reached the connection
+}
+
+func (e *noResponseError) Error() string { return e.err.Error() }
+func (e *noResponseError) Unwrap() error { return e.err }
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// headRecorder sits between a connection and its bufio.Reader and keeps
+// what is read while recording, so the header names of a response head can
+// be recovered in wire order after http.ReadResponse has parsed it.
+type headRecorder struct {
+	r   io.Reader
+	buf []byte
+	on  bool
+}
+
+// maxRecordedHead bounds the bytes kept; longer heads lose their order.
+const maxRecordedHead = 1 << 20
+
+func (h *headRecorder) Read(p []byte) (int, error) {
+	n, err := h.r.Read(p)
+	if h.on && len(h.buf)+n <= maxRecordedHead {
+		h.buf = append(h.buf, p[:n]...)
+	}
+	return n, err
+}
+
+func (h *headRecorder) start() {
+	h.buf = h.buf[:0]
+	h.on = true
+}
+
+// stop ends recording and returns the header names of the response head
+// recorded, or nil if it was not captured whole.
+func (h *headRecorder) stop() []string {
+	if !h.on {
+		return nil
+	}
+	h.on = false
+	head := string(h.buf)
+	end := strings.Index(head, "\r\n\r\n")
+	if end < 0 {
+		if end = strings.Index(head, "\n\n"); end < 0 {
+			return nil
 		}
-		return ForwardResponse(resp, conn)
-	} else {
-		// Use HTTP/1.1
-		if err := httpReq.Write(conn); err != nil {
-			conn.Close()
-			return err
+	}
+	lines := strings.Split(head[:end], "\n")
+	names := []string{}
+	for _, line := range lines[1:] {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || line[0] == ' ' || line[0] == '\t' {
+			continue // obsolete line folding continues the previous value
 		}
-		br := bufio.NewReader(conn)
-		resp, err := http.ReadResponse(br, httpReq)
-		if err != nil {
-			conn.Close()
-			return err
+		if i := strings.IndexByte(line, ':'); i > 0 {
+			names = append(names, strings.TrimRight(line[:i], " \t"))
 		}
-		return ForwardResponse(resp, conn)
+	}
+	return names
+}
+
+// connectionState reports conn's TLS parameters in crypto/tls form, or nil
+// for plain connections.
+func connectionState(conn net.Conn) *tls.ConnectionState {
+	uConn, ok := conn.(*utls.UConn)
+	if !ok {
+		return nil
+	}
+	s := uConn.ConnectionState()
+	return &tls.ConnectionState{
+		Version:                     s.Version,
+		HandshakeComplete:           s.HandshakeComplete,
+		DidResume:                   s.DidResume,
+		CipherSuite:                 s.CipherSuite,
+		NegotiatedProtocol:          s.NegotiatedProtocol,
+		ServerName:                  s.ServerName,
+		PeerCertificates:            s.PeerCertificates,
+		VerifiedChains:              s.VerifiedChains,
+		SignedCertificateTimestamps: s.SignedCertificateTimestamps,
+		OCSPResponse:                s.OCSPResponse,
 	}
 }
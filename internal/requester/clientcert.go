@@ -0,0 +1,118 @@
+package requester
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// clientCerts caches loaded client certificates by file, key and password
+// hash. An entry is used only while the files it was read from are
+// unchanged, so a renewed certificate is picked up without a restart.
+var clientCerts sync.Map // clientCertKey -> *cachedClientCert
+
+type clientCertKey struct {
+	certFile, keyFile string
+	password          [sha256.Size]byte
+}
+
+type cachedClientCert struct {
+	cert  utls.Certificate
+	files [2]fileStamp // cert file, key file
+}
+
+// fileStamp identifies one version of a file. The zero value stands for no
+// file (an empty key_file).
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) fileStamp {
+	if path == "" {
+		return fileStamp{}
+	}
+	fi, err := os.Stat(path)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{fi.ModTime(), fi.Size()}
+}
+
+// loadClientCert loads the certificate configured in t. Setting it on the
+// utls config leaves the ClientHello untouched; it is only sent in reply to
+// a CertificateRequest.
+func loadClientCert(t *config.TLSConfig) (utls.Certificate, error) {
+	cacheKey := clientCertKey{t.CertFile, t.KeyFile, sha256.Sum256([]byte(t.CertPassword))}
+	files := [2]fileStamp{statFile(t.CertFile), statFile(t.KeyFile)}
+	if c, ok := clientCerts.Load(cacheKey); ok && c.(*cachedClientCert).files == files {
+		return c.(*cachedClientCert).cert, nil
+	}
+
+	certData, err := os.ReadFile(t.CertFile)
+	if err != nil {
+		return utls.Certificate{}, fmt.Errorf("failed to read client certificate: %v", err)
+	}
+	var cert utls.Certificate
+	switch {
+	case t.KeyFile != "":
+		keyData, err := os.ReadFile(t.KeyFile)
+		if err != nil {
+			return utls.Certificate{}, fmt.Errorf("failed to read client key: %v", err)
+		}
+		if cert, err = utls.X509KeyPair(certData, keyData); err != nil {
+			return utls.Certificate{}, fmt.Errorf("invalid client certificate: %v", err)
+		}
+	case bytes.Contains(certData, []byte("-----BEGIN")):
+		if cert, err = utls.X509KeyPair(certData, certData); err != nil {
+			return utls.Certificate{}, fmt.Errorf("invalid client certificate: %v", err)
+		}
+	default:
+		if cert, err = decodePKCS12(certData, t.CertPassword); err != nil {
+			return utls.Certificate{}, fmt.Errorf("failed to decode PKCS#12 client certificate: %v", err)
+		}
+	}
+	clientCerts.Store(cacheKey, &cachedClientCert{cert, files})
+	return cert, nil
+}
+
+// decodePKCS12 decodes a PKCS#12 bundle, legacy (RC2, 3DES) or AES. The
+// leaf is the certificate whose public key matches the private key,
+// wherever the bundle puts it; the other certificates follow as its chain.
+func decodePKCS12(data []byte, password string) (utls.Certificate, error) {
+	key, first, rest, err := pkcs12.DecodeChain(data, password)
+	if err != nil {
+		return utls.Certificate{}, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return utls.Certificate{}, fmt.Errorf("unsupported private key type %T", key)
+	}
+	certs := append([]*x509.Certificate{first}, rest...)
+	leaf := slices.IndexFunc(certs, func(c *x509.Certificate) bool {
+		pub, ok := c.PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+		return ok && pub.Equal(signer.Public())
+	})
+	if leaf < 0 {
+		return utls.Certificate{}, errors.New("no certificate matches the private key")
+	}
+	chain := [][]byte{certs[leaf].Raw}
+	for i, c := range certs {
+		if i != leaf {
+			chain = append(chain, c.Raw)
+		}
+	}
+	return utls.Certificate{Certificate: chain, PrivateKey: key, Leaf: certs[leaf]}, nil
+}
@@ -0,0 +1,176 @@
+package requester
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// newCert issues a certificate for name signed by parent (self-signed if
+// nil) and returns it with its key.
+func newCert(t *testing.T, name string, parent *x509.Certificate, parentKey crypto.Signer) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  parent == nil,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, key.Public(), parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key
+}
+
+func certPEM(c *x509.Certificate) []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})
+}
+
+func keyPEM(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	t.Helper()
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+}
+
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadClientCert(t *testing.T) {
+	ca, caKey := newCert(t, "CA", nil, nil)
+	leaf, leafKey := newCert(t, "client", ca, caKey)
+	_, otherKey := newCert(t, "other", nil, nil)
+	p12 := func(enc *pkcs12.Encoder, key crypto.PrivateKey, cert *x509.Certificate, chain ...*x509.Certificate) []byte {
+		data, err := enc.Encode(key, cert, chain, "secret")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	dir := t.TempDir()
+	tests := []struct {
+		name  string
+		tls   config.TLSConfig
+		chain int // certificates sent, leaf first
+		err   string
+	}{
+		{"PEM with key file", config.TLSConfig{
+			CertFile: writeFile(t, dir, "cert.pem", append(certPEM(leaf), certPEM(ca)...)),
+			KeyFile:  writeFile(t, dir, "key.pem", keyPEM(t, leafKey)),
+		}, 2, ""},
+		{"PEM with the key inside", config.TLSConfig{
+			CertFile: writeFile(t, dir, "both.pem", append(keyPEM(t, leafKey), certPEM(leaf)...)),
+		}, 1, ""},
+		{"legacy RC2 .p12", config.TLSConfig{
+			CertFile:     writeFile(t, dir, "legacy.p12", p12(pkcs12.LegacyRC2, leafKey, leaf)),
+			CertPassword: "secret",
+		}, 1, ""},
+		{"3DES .p12 with chain", config.TLSConfig{
+			CertFile:     writeFile(t, dir, "des.p12", p12(pkcs12.LegacyDES, leafKey, leaf, ca)),
+			CertPassword: "secret",
+		}, 2, ""},
+		{"AES .p12 with the CA first", config.TLSConfig{
+			CertFile:     writeFile(t, dir, "ca-first.p12", p12(pkcs12.Modern, leafKey, ca, leaf)),
+			CertPassword: "secret",
+		}, 2, ""},
+		{"wrong password", config.TLSConfig{
+			CertFile:     filepath.Join(dir, "des.p12"),
+			CertPassword: "wrong",
+		}, 0, "decryption password incorrect"},
+		{"key matches no certificate", config.TLSConfig{
+			CertFile:     writeFile(t, dir, "mismatch.p12", p12(pkcs12.Modern, otherKey, leaf, ca)),
+			CertPassword: "secret",
+		}, 0, "no certificate matches the private key"},
+		{"PEM key matches no certificate", config.TLSConfig{
+			CertFile: filepath.Join(dir, "cert.pem"),
+			KeyFile:  writeFile(t, dir, "other.pem", keyPEM(t, otherKey)),
+		}, 0, "invalid client certificate"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := loadClientCert(&tt.tls)
+			if tt.err != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.err) {
+					t.Fatalf("error %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cert.Certificate) != tt.chain {
+				t.Fatalf("%d certificates, want %d", len(cert.Certificate), tt.chain)
+			}
+			if !bytes.Equal(cert.Certificate[0], leaf.Raw) {
+				t.Error("first certificate is not the leaf")
+			}
+			if tt.chain > 1 && !bytes.Equal(cert.Certificate[1], ca.Raw) {
+				t.Error("second certificate is not the CA")
+			}
+		})
+	}
+}
+
+func TestLoadClientCertReloads(t *testing.T) {
+	dir := t.TempDir()
+	first, firstKey := newCert(t, "first", nil, nil)
+	cfg := &config.TLSConfig{
+		CertFile: writeFile(t, dir, "cert.pem", certPEM(first)),
+		KeyFile:  writeFile(t, dir, "key.pem", keyPEM(t, firstKey)),
+	}
+	if cert, err := loadClientCert(cfg); err != nil || !bytes.Equal(cert.Certificate[0], first.Raw) {
+		t.Fatalf("first load: %v", err)
+	}
+
+	second, secondKey := newCert(t, "second", nil, nil)
+	writeFile(t, dir, "cert.pem", certPEM(second))
+	writeFile(t, dir, "key.pem", keyPEM(t, secondKey))
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(cfg.CertFile, later, later)
+	os.Chtimes(cfg.KeyFile, later, later)
+	cert, err := loadClientCert(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(cert.Certificate[0], second.Raw) {
+		t.Error("replaced certificate was not reloaded")
+	}
+}
@@ -0,0 +1,155 @@
+package requester
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// DecompressBody replaces resp.Body with a reader that decodes its
+// Content-Encoding (gzip, deflate, br, zstd, or a list of them) as the body
+// arrives, and drops Content-Encoding and Content-Length. Responses with
+// an unknown coding, and responses that cannot have a body (see
+// noBodyAllowed) whatever their headers say, are left alone.
+func DecompressBody(resp *http.Response) {
+	codings := contentCodings(resp.Header.Get("Content-Encoding"))
+	if len(codings) == 0 || resp.Body == nil || resp.Body == http.NoBody || noBodyAllowed(resp) {
+		return
+	}
+	for _, c := range codings {
+		if !supportedCoding(c) {
+			return
+		}
+	}
+
+	resp.Body = &decodedBody{raw: resp.Body, codings: codings}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+}
+
+// noBodyAllowed reports responses to HEAD and 1xx, 204 and 304 responses,
+// whose Content-Encoding describes a body that is never sent.
+func noBodyAllowed(resp *http.Response) bool {
+	if resp.Request != nil && resp.Request.Method == http.MethodHead {
+		return true
+	}
+	code := resp.StatusCode
+	return code >= 100 && code < 200 || code == http.StatusNoContent || code == http.StatusNotModified
+}
+
+// contentCodings lists the codings applied, ignoring identity.
+func contentCodings(header string) []string {
+	var codings []string
+	for _, c := range strings.Split(header, ",") {
+		c = strings.ToLower(strings.TrimSpace(c))
+		if c != "" && c != "identity" {
+			codings = append(codings, c)
+		}
+	}
+	return codings
+}
+
+func supportedCoding(c string) bool {
+	switch c {
+	case "gzip", "x-gzip", "deflate", "br", "zstd":
+		return true
+	}
+	return false
+}
+
+// decodedBody sets up its decoders on the first Read, so creating it never
+// blocks on the network.
+type decodedBody struct {
+	raw     io.ReadCloser
+	codings []string
+	r       io.Reader
+	closers []func()
+	err     error
+}
+
+func (b *decodedBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		// An empty body decodes to nothing rather than a decoder's
+		// unexpected EOF; a body cut short after its first byte still fails.
+		raw := bufio.NewReader(b.raw)
+		if _, err := raw.Peek(1); err == io.EOF {
+			b.r = raw
+			return 0, io.EOF
+		}
+		b.r = raw
+		// Codings are listed in the order they were applied
+		for i := len(b.codings) - 1; i >= 0 && b.err == nil; i-- {
+			b.r, b.err = b.decoder(b.codings[i], b.r)
+		}
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	n, err := b.r.Read(p)
+	if err == io.EOF {
+		// Decoders may stop at the end of their stream without reading
+		// the raw body to EOF, which is what frees the connection.
+		io.Copy(io.Discard, io.LimitReader(b.raw, 4<<10))
+	}
+	return n, err
+}
+
+func (b *decodedBody) decoder(coding string, r io.Reader) (io.Reader, error) {
+	switch coding {
+	case "gzip", "x-gzip":
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, fmt.Errorf("gzip: %v", err)
+		}
+		b.closers = append(b.closers, func() { zr.Close() })
+		return zr, nil
+	case "deflate":
+		// "deflate" is meant to be zlib-wrapped, but some servers send raw
+		// DEFLATE; the zlib header tells them apart.
+		br := bufio.NewReader(r)
+		if header, err := br.Peek(2); err == nil && isZlibHeader(header) {
+			zr, err := zlib.NewReader(br)
+			if err != nil {
+				return nil, fmt.Errorf("deflate: %v", err)
+			}
+			b.closers = append(b.closers, func() { zr.Close() })
+			return zr, nil
+		}
+		fr := flate.NewReader(br)
+		b.closers = append(b.closers, func() { fr.Close() })
+		return fr, nil
+	case "br":
+		return brotli.NewReader(r), nil
+	case "zstd":
+		// A single goroutine decodes block by block, so data is returned
+		// as soon as each block arrives.
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, fmt.Errorf("zstd: %v", err)
+		}
+		b.closers = append(b.closers, zr.Close)
+		return zr, nil
+	}
+	return r, nil
+}
+
+func isZlibHeader(h []byte) bool {
+	return h[0]&0x0f == 8 && (uint16(h[0])<<8|uint16(h[1]))%31 == 0
+}
+
+func (b *decodedBody) Close() error {
+	for _, c := range b.closers {
+		c()
+	}
+	return b.raw.Close()
+}
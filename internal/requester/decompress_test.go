@@ -0,0 +1,172 @@
+package requester
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// encode applies coding to data the way a server would.
+func encode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var b bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case "gzip":
+		w = gzip.NewWriter(&b)
+	case "deflate":
+		w = zlib.NewWriter(&b)
+	case "raw deflate":
+		w, _ = flate.NewWriter(&b, flate.DefaultCompression)
+	case "br":
+		w = brotli.NewWriter(&b)
+	case "zstd":
+		var err error
+		if w, err = zstd.NewWriter(&b); err != nil {
+			t.Fatal(err)
+		}
+	default:
+		t.Fatalf("unknown coding %s", coding)
+	}
+	w.Write(data)
+	w.Close()
+	return b.Bytes()
+}
+
+// encodedResponse is a status response to method with body sent as encoding.
+func encodedResponse(method string, status int, encoding string, body []byte) *http.Response {
+	req, _ := http.NewRequest(method, "https://example.com/", nil)
+	return &http.Response{
+		StatusCode:    status,
+		Header:        http.Header{"Content-Encoding": {encoding}, "Content-Length": {"1"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+func TestDecompressBody(t *testing.T) {
+	text := []byte(strings.Repeat("fingerprinted response body\n", 200))
+	tests := []struct {
+		header string
+		body   []byte
+	}{
+		{"gzip", encode(t, "gzip", text)},
+		{"x-gzip", encode(t, "gzip", text)},
+		{"deflate", encode(t, "deflate", text)},
+		{"deflate", encode(t, "raw deflate", text)},
+		{"br", encode(t, "br", text)},
+		{"zstd", encode(t, "zstd", text)},
+		{"gzip, br", encode(t, "br", encode(t, "gzip", text))},
+		{"identity, GZIP", encode(t, "gzip", text)},
+	}
+	for _, tt := range tests {
+		resp := encodedResponse("GET", 200, tt.header, tt.body)
+		DecompressBody(resp)
+		got, err := io.ReadAll(resp.Body)
+		if err != nil {
+			t.Errorf("%s: %v", tt.header, err)
+			continue
+		}
+		if !bytes.Equal(got, text) {
+			t.Errorf("%s: decoded %d bytes, want %d", tt.header, len(got), len(text))
+		}
+		if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Length") != "" || resp.ContentLength != -1 || !resp.Uncompressed {
+			t.Errorf("%s: headers not updated: %v, length %d", tt.header, resp.Header, resp.ContentLength)
+		}
+	}
+}
+
+func TestDecompressBodyWithoutBody(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		status  int
+		decoded bool // whether DecompressBody takes the body over
+	}{
+		{"HEAD", "HEAD", 200, false},
+		{"304", "GET", 304, false},
+		{"204", "GET", 204, false},
+		{"103", "GET", 103, false},
+		{"empty 200", "GET", 200, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := encodedResponse(tt.method, tt.status, "gzip", nil)
+			DecompressBody(resp)
+			if got := resp.Header.Get("Content-Encoding") == ""; got != tt.decoded {
+				t.Errorf("Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+			}
+			got, err := io.ReadAll(resp.Body)
+			if err != nil || len(got) != 0 {
+				t.Errorf("read %q, %v; want an empty body", got, err)
+			}
+		})
+	}
+}
+
+func TestDecompressBodyTruncated(t *testing.T) {
+	text := []byte(strings.Repeat("0123456789", 1000))
+	for _, coding := range []string{"gzip", "deflate", "br", "zstd"} {
+		full := encode(t, coding, text)
+		resp := encodedResponse("GET", 200, coding, full[:len(full)/2])
+		DecompressBody(resp)
+		if _, err := io.ReadAll(resp.Body); err == nil {
+			t.Errorf("%s: body cut mid-stream decoded without error", coding)
+		}
+	}
+
+	resp := encodedResponse("GET", 200, "gzip", []byte("not gzip"))
+	DecompressBody(resp)
+	if _, err := io.ReadAll(resp.Body); err == nil || !strings.Contains(err.Error(), "gzip") {
+		t.Errorf("invalid gzip: error %v", err)
+	}
+}
+
+func TestDecompressBodyUnknownCoding(t *testing.T) {
+	resp := encodedResponse("GET", 200, "gzip, compress", []byte("raw"))
+	DecompressBody(resp)
+	if resp.Header.Get("Content-Encoding") != "gzip, compress" {
+		t.Errorf("Content-Encoding %q", resp.Header.Get("Content-Encoding"))
+	}
+	if got, _ := io.ReadAll(resp.Body); string(got) != "raw" {
+		t.Errorf("body %q", got)
+	}
+}
+
+func TestDecompressHTTP2Head(t *testing.T) {
+	gz := encode(t, "gzip", []byte("hello"))
+	cc := serveHTTP2(t, testHTTP2Config(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.URL.Path == "/not-modified" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Write(gz)
+	})
+	for _, tt := range []struct{ method, path, want string }{
+		{"HEAD", "/", ""},
+		{"GET", "/not-modified", ""},
+		{"GET", "/", "hello"},
+	} {
+		req, _ := http.NewRequest(tt.method, "https://example.com"+tt.path, nil)
+		resp, err := cc.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		DecompressBody(resp)
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s %s: read %q, %v; want %q", tt.method, tt.path, got, err, tt.want)
+		}
+	}
+}
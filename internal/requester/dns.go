@@ -0,0 +1,471 @@
+package requester
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// dnsQueryTimeout bounds each query to one server.
+const dnsQueryTimeout = 5 * time.Second
+
+// DNSError is returned when a host name cannot be resolved with the
+// configured DNS servers (and, unless strict, the system resolver).
+type DNSError struct {
+	Host     string
+	NotFound bool    // a server answered that the name does not exist
+	Errs     []error // one per resolver tried, in order
+}
+
+func (e *DNSError) Error() string {
+	msgs := make([]string, len(e.Errs))
+	for i, err := range e.Errs {
+		msgs[i] = err.Error()
+	}
+	return fmt.Sprintf("dns: failed to resolve %s: %s", e.Host, strings.Join(msgs, "; "))
+}
+
+var errNoSuchHost = errors.New("no such host")
+
+// resolver resolves names with the servers of a DNSConfig.
+type resolver struct {
+	servers []dnsServer
+	strict  bool
+	cfg     *config.Config // for DoH requests, which carry the fingerprint
+	dialer  hostDialer     // for UDP, TCP and DoT servers
+}
+
+// dnsServer is one entry of DNSConfig.Servers.
+type dnsServer struct {
+	raw     string
+	network string // udp, tcp, tls or https
+	addr    string // host:port, or the URL for https
+}
+
+// newResolver returns nil when cfg uses the system resolver.
+func newResolver(cfg *config.Config) (*resolver, error) {
+	if len(cfg.DNS.Servers) == 0 {
+		return nil, nil
+	}
+	r := &resolver{
+		strict: cfg.DNS.Strict,
+		cfg:    cfg,
+		dialer: hostDialer{d: &net.Dialer{}, cfg: cfg},
+	}
+	for _, s := range cfg.DNS.Servers {
+		server, err := parseDNSServer(s)
+		if err != nil {
+			return nil, err
+		}
+		r.servers = append(r.servers, server)
+	}
+	return r, nil
+}
+
+func parseDNSServer(s string) (dnsServer, error) {
+	server := dnsServer{raw: s, network: "udp", addr: s}
+	if scheme, rest, ok := strings.Cut(s, "://"); ok {
+		server.network, server.addr = strings.ToLower(scheme), rest
+	}
+	port := "53"
+	switch server.network {
+	case "https":
+		server.addr = s
+		return server, nil
+	case "tls":
+		port = "853"
+	case "udp", "tcp":
+	default:
+		return server, fmt.Errorf("dns: unsupported server %q", s)
+	}
+	server.addr = strings.TrimSuffix(server.addr, "/")
+	if _, _, err := net.SplitHostPort(server.addr); err != nil {
+		server.addr = net.JoinHostPort(strings.Trim(server.addr, "[]"), port)
+	}
+	return server, nil
+}
+
+// lookup returns the addresses of host for network ip (IPv4 and IPv6),
+// ip4 or ip6, trying each server in order. A server that answers the name
+// does not exist ends the search.
+func (r *resolver) lookup(ctx context.Context, network, host string) ([]net.IP, error) {
+	// Queries are made on the side: they must not mark the request's phases.
+	qctx, cancel := detachedContext(ctx)
+	defer cancel()
+
+	dnsErr := &DNSError{Host: host}
+	for _, server := range r.servers {
+		ips, err := r.query(qctx, server, network, host)
+		if err == nil {
+			return ips, nil
+		}
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		dnsErr.Errs = append(dnsErr.Errs, fmt.Errorf("%s: %v", server.raw, err))
+		if errors.Is(err, errNoSuchHost) {
+			dnsErr.NotFound = true
+			return nil, dnsErr
+		}
+	}
+	if !r.strict {
+		ips, err := systemLookup(ctx, r.cfg, network, host)
+		if err == nil {
+			return ips, nil
+		}
+		dnsErr.Errs = append(dnsErr.Errs, fmt.Errorf("system resolver: %v", err))
+	}
+	return nil, dnsErr
+}
+
+// query asks one server for the A and AAAA records of host that network
+// wants, from the cache when it has them.
+func (r *resolver) query(ctx context.Context, server dnsServer, network, host string) ([]net.IP, error) {
+	var types []dnsmessage.Type
+	if network != "ip6" {
+		types = append(types, dnsmessage.TypeA)
+	}
+	if network != "ip4" {
+		types = append(types, dnsmessage.TypeAAAA)
+	}
+	type result struct {
+		ips []net.IP
+		err error
+	}
+	results := make(chan result, len(types))
+	for _, qtype := range types {
+		go func() {
+			ips, err := r.cachedExchange(ctx, server, host, qtype)
+			results <- result{ips, err}
+		}()
+	}
+
+	// IPv4 answers come first; an empty answer is only an error when
+	// every query had one.
+	var v4, v6 []net.IP
+	var firstErr error
+	for range types {
+		res := <-results
+		if res.err != nil && !errors.Is(res.err, errNoSuchHost) {
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			continue
+		}
+		for _, ip := range res.ips {
+			if ip.To4() != nil {
+				v4 = append(v4, ip)
+			} else {
+				v6 = append(v6, ip)
+			}
+		}
+	}
+	if ips := append(v4, v6...); len(ips) > 0 {
+		return ips, nil
+	}
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return nil, errNoSuchHost
+}
+
+// cachedExchange is exchange through dnsAnswers, unless the cache is
+// disabled.
+func (r *resolver) cachedExchange(ctx context.Context, server dnsServer, host string, qtype dnsmessage.Type) ([]net.IP, error) {
+	if r.cfg.DNS.CacheTTL < 0 {
+		ips, _, err := r.exchange(ctx, server, host, qtype)
+		return ips, err
+	}
+	key := newDNSCacheKey(server.raw, host, qtype.String())
+	if ips, ok := dnsAnswers.get(key); ok {
+		return ips, nil
+	}
+	ips, ttl, err := r.exchange(ctx, server, host, qtype)
+	if err == nil {
+		dnsAnswers.put(key, ips, ttl)
+	}
+	return ips, err
+}
+
+// exchange sends one query and parses the answer, returning its addresses
+// and how long they may be cached.
+func (r *resolver) exchange(ctx context.Context, server dnsServer, host string, qtype dnsmessage.Type) ([]net.IP, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsQueryTimeout)
+	defer cancel()
+
+	// DoH uses ID 0 so answers can be cached (RFC 8484 section 4.1)
+	var id uint16
+	if server.network != "https" {
+		id = uint16(rand.Uint32())
+	}
+	query, err := newDNSQuery(id, host, qtype)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var answer []byte
+	switch server.network {
+	case "udp":
+		answer, err = exchangeUDP(ctx, r.dialer, server.addr, query, id)
+		if err == nil && isTruncated(answer) {
+			answer, err = exchangeStream(ctx, r.dialer, "tcp", server.addr, query)
+		}
+	case "tcp", "tls":
+		answer, err = exchangeStream(ctx, r.dialer, server.network, server.addr, query)
+	case "https":
+		answer, err = r.exchangeHTTPS(ctx, server.addr, query)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+	return parseDNSAnswer(answer, id)
+}
+
+func newDNSQuery(id uint16, host string, qtype dnsmessage.Type) ([]byte, error) {
+	name, err := dnsmessage.NewName(strings.TrimSuffix(host, ".") + ".")
+	if err != nil {
+		return nil, fmt.Errorf("invalid host name %q", host)
+	}
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: name, Type: qtype, Class: dnsmessage.ClassINET})
+	// EDNS0 lets UDP answers exceed 512 bytes
+	b.StartAdditionals()
+	var opt dnsmessage.ResourceHeader
+	opt.SetEDNS0(1232, dnsmessage.RCodeSuccess, false)
+	b.OPTResource(opt, dnsmessage.OPTResource{})
+	return b.Finish()
+}
+
+// parseDNSAnswer returns the addresses in msg and their TTL: the lowest of
+// the answer records (CNAMEs included) or, for an answer without
+// addresses, the negative caching TTL of its SOA record (RFC 2308).
+func parseDNSAnswer(msg []byte, id uint16) ([]net.IP, time.Duration, error) {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	if err != nil {
+		return nil, 0, fmt.Errorf("invalid answer: %v", err)
+	}
+	if h.ID != id {
+		return nil, 0, errors.New("answer ID does not match the query")
+	}
+	switch h.RCode {
+	case dnsmessage.RCodeSuccess:
+	case dnsmessage.RCodeNameError:
+		return nil, 0, errNoSuchHost
+	default:
+		return nil, 0, fmt.Errorf("server returned %s", strings.TrimPrefix(h.RCode.String(), "RCode"))
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, 0, fmt.Errorf("invalid answer: %v", err)
+	}
+	var ips []net.IP
+	ttl := uint32(math.MaxUint32)
+	for {
+		rh, err := p.AnswerHeader()
+		if err == dnsmessage.ErrSectionDone {
+			break
+		}
+		if err != nil {
+			return nil, 0, fmt.Errorf("invalid answer: %v", err)
+		}
+		ttl = min(ttl, rh.TTL)
+		// CNAME chains arrive in the same answer section
+		switch rh.Type {
+		case dnsmessage.TypeA:
+			a, err := p.AResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid answer: %v", err)
+			}
+			ips = append(ips, net.IP(a.A[:]))
+		case dnsmessage.TypeAAAA:
+			aaaa, err := p.AAAAResource()
+			if err != nil {
+				return nil, 0, fmt.Errorf("invalid answer: %v", err)
+			}
+			ips = append(ips, net.IP(aaaa.AAAA[:]))
+		default:
+			if err := p.SkipAnswer(); err != nil {
+				return nil, 0, fmt.Errorf("invalid answer: %v", err)
+			}
+		}
+	}
+	if len(ips) == 0 {
+		ttl = negativeTTL(&p)
+	}
+	return ips, time.Duration(ttl) * time.Second, nil
+}
+
+// negativeTTL reads the SOA record of the authority section, which says
+// how long the absence of records may be cached; zero when there is none.
+func negativeTTL(p *dnsmessage.Parser) uint32 {
+	for {
+		rh, err := p.AuthorityHeader()
+		if err != nil {
+			return 0
+		}
+		if rh.Type != dnsmessage.TypeSOA {
+			if p.SkipAuthority() != nil {
+				return 0
+			}
+			continue
+		}
+		soa, err := p.SOAResource()
+		if err != nil {
+			return 0
+		}
+		return min(rh.TTL, soa.MinTTL)
+	}
+}
+
+func isTruncated(msg []byte) bool {
+	var p dnsmessage.Parser
+	h, err := p.Start(msg)
+	return err == nil && h.Truncated
+}
+
+func exchangeUDP(ctx context.Context, d hostDialer, addr string, query []byte, id uint16) ([]byte, error) {
+	conn, err := d.DialContext(ctx, "udp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, 4096)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Skip stray datagrams, e.g. late answers to an earlier query
+		if n >= 2 && binary.BigEndian.Uint16(buf) == id {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeStream sends a length-prefixed query over TCP or, for tls, DNS
+// over TLS (RFC 7858).
+func exchangeStream(ctx context.Context, d hostDialer, network, addr string, query []byte) ([]byte, error) {
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if network == "tls" {
+		host, _, _ := net.SplitHostPort(addr)
+		tlsConn := tls.Client(conn, &tls.Config{ServerName: host})
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			return nil, err
+		}
+		conn = tlsConn
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	msg := binary.BigEndian.AppendUint16(nil, uint16(len(query)))
+	if _, err := conn.Write(append(msg, query...)); err != nil {
+		return nil, err
+	}
+	var length [2]byte
+	if _, err := io.ReadFull(conn, length[:]); err != nil {
+		return nil, err
+	}
+	answer := make([]byte, binary.BigEndian.Uint16(length[:]))
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return nil, err
+	}
+	return answer, nil
+}
+
+// systemLookup resolves host with the system resolver, caching the answer
+// for the configured time since the resolver does not report TTLs. With a
+// local_address, the Go resolver queries the system's DNS servers from it.
+func systemLookup(ctx context.Context, cfg *config.Config, network, host string) ([]net.IP, error) {
+	ttl := cfg.DNS.SystemCacheTTL()
+	key := newDNSCacheKey("system", host, network)
+	if ttl > 0 {
+		if ips, ok := dnsAnswers.get(key); ok {
+			return ips, nil
+		}
+	}
+	res := net.DefaultResolver
+	if len(cfg.LocalAddress) > 0 {
+		res = &net.Resolver{PreferGo: true, Dial: hostDialer{d: &net.Dialer{}, cfg: cfg}.DialContext}
+	}
+	ips, err := res.LookupIP(ctx, network, host)
+	if err == nil {
+		dnsAnswers.put(key, ips, time.Duration(ttl)*time.Second)
+	}
+	return ips, err
+}
+
+// dohPool keeps DoH connections open across lookups.
+var dohPool = NewPool()
+
+// exchangeHTTPS POSTs the query to a DoH server (RFC 8484) with the
+// request's TLS and HTTP/2 fingerprint. The DoH server is reached directly
+// and its own name, if any, comes from the system resolver.
+func (r *resolver) exchangeHTTPS(ctx context.Context, url string, query []byte) ([]byte, error) {
+	cfg := *r.cfg
+	cfg.DNS = config.DNSConfig{}
+	cfg.Proxy = config.ProxyConfig{}
+	cfg.TLS = config.TLSConfig{}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-message")
+	req.Header.Set("Content-Type", "application/dns-message")
+	fingerprintKey := fmt.Sprintf("dns|%v|%v", cfg.Fingerprint, cfg.HTTP2)
+	resp, err := dohPool.RoundTrip(req, &cfg, fingerprintKey, false)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returned HTTP %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+}
+
+// detachedContext returns a context with ctx's deadline and cancellation
+// but none of its values, such as the request's Timer.
+func detachedContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	detached, cancel := context.WithCancel(context.Background())
+	stop := context.AfterFunc(ctx, cancel)
+	release := func() {
+		stop()
+		cancel()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		var cancelDeadline context.CancelFunc
+		detached, cancelDeadline = context.WithDeadline(detached, deadline)
+		release = func() {
+			stop()
+			cancelDeadline()
+			cancel()
+		}
+	}
+	return detached, release
+}
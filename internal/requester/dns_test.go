@@ -0,0 +1,170 @@
+package requester
+
+import (
+	"errors"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+type dnsRecord struct {
+	ttl  uint32
+	body dnsmessage.ResourceBody
+}
+
+// dnsAnswer builds a response to an A query for example.com.
+func dnsAnswer(t *testing.T, id uint16, rcode dnsmessage.RCode, answers, authorities []dnsRecord) []byte {
+	t.Helper()
+	name := dnsmessage.MustNewName("example.com.")
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, Response: true, RCode: rcode})
+	b.StartQuestions()
+	b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET})
+	add := func(r dnsRecord) {
+		h := dnsmessage.ResourceHeader{Name: name, Class: dnsmessage.ClassINET, TTL: r.ttl}
+		var err error
+		switch body := r.body.(type) {
+		case *dnsmessage.AResource:
+			err = b.AResource(h, *body)
+		case *dnsmessage.AAAAResource:
+			err = b.AAAAResource(h, *body)
+		case *dnsmessage.CNAMEResource:
+			err = b.CNAMEResource(h, *body)
+		case *dnsmessage.SOAResource:
+			err = b.SOAResource(h, *body)
+		case *dnsmessage.NSResource:
+			err = b.NSResource(h, *body)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	b.StartAnswers()
+	for _, r := range answers {
+		add(r)
+	}
+	b.StartAuthorities()
+	for _, r := range authorities {
+		add(r)
+	}
+	msg, err := b.Finish()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func TestParseDNSAnswer(t *testing.T) {
+	a := func(ttl uint32, ip string) dnsRecord {
+		var r dnsmessage.AResource
+		copy(r.A[:], net.ParseIP(ip).To4())
+		return dnsRecord{ttl, &r}
+	}
+	aaaa := func(ttl uint32, ip string) dnsRecord {
+		var r dnsmessage.AAAAResource
+		copy(r.AAAA[:], net.ParseIP(ip))
+		return dnsRecord{ttl, &r}
+	}
+	cname := func(ttl uint32) dnsRecord {
+		return dnsRecord{ttl, &dnsmessage.CNAMEResource{CNAME: dnsmessage.MustNewName("edge.example.net.")}}
+	}
+	soa := func(ttl, minTTL uint32) dnsRecord {
+		ns := dnsmessage.MustNewName("ns.example.com.")
+		return dnsRecord{ttl, &dnsmessage.SOAResource{NS: ns, MBox: ns, MinTTL: minTTL}}
+	}
+	ns := dnsRecord{3600, &dnsmessage.NSResource{NS: dnsmessage.MustNewName("ns.example.com.")}}
+
+	tests := []struct {
+		name        string
+		rcode       dnsmessage.RCode
+		answers     []dnsRecord
+		authorities []dnsRecord
+		wantIPs     []string
+		wantTTL     time.Duration
+	}{
+		{"single A", dnsmessage.RCodeSuccess, []dnsRecord{a(300, "192.0.2.1")}, nil, []string{"192.0.2.1"}, 300 * time.Second},
+		{"lowest TTL wins", dnsmessage.RCodeSuccess, []dnsRecord{a(300, "192.0.2.1"), a(60, "192.0.2.2")}, nil, []string{"192.0.2.1", "192.0.2.2"}, 60 * time.Second},
+		{"CNAME TTL counts", dnsmessage.RCodeSuccess, []dnsRecord{cname(30), a(300, "192.0.2.1")}, nil, []string{"192.0.2.1"}, 30 * time.Second},
+		{"AAAA", dnsmessage.RCodeSuccess, []dnsRecord{aaaa(120, "2001:db8::1")}, nil, []string{"2001:db8::1"}, 120 * time.Second},
+		{"zero TTL", dnsmessage.RCodeSuccess, []dnsRecord{a(0, "192.0.2.1")}, nil, []string{"192.0.2.1"}, 0},
+		{"NODATA uses SOA minimum", dnsmessage.RCodeSuccess, nil, []dnsRecord{soa(900, 60)}, nil, 60 * time.Second},
+		{"NODATA uses SOA TTL when lower", dnsmessage.RCodeSuccess, nil, []dnsRecord{soa(30, 600)}, nil, 30 * time.Second},
+		{"SOA after NS", dnsmessage.RCodeSuccess, nil, []dnsRecord{ns, soa(900, 120)}, nil, 120 * time.Second},
+		{"CNAME only, SOA", dnsmessage.RCodeSuccess, []dnsRecord{cname(30)}, []dnsRecord{soa(900, 45)}, nil, 45 * time.Second},
+		{"NODATA without SOA", dnsmessage.RCodeSuccess, nil, []dnsRecord{ns}, nil, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ips, ttl, err := parseDNSAnswer(dnsAnswer(t, 7, tt.rcode, tt.answers, tt.authorities), 7)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := []string{}
+			for _, ip := range ips {
+				got = append(got, ip.String())
+			}
+			if !slices.Equal(got, tt.wantIPs) {
+				t.Errorf("ips %v, want %v", got, tt.wantIPs)
+			}
+			if ttl != tt.wantTTL {
+				t.Errorf("ttl %v, want %v", ttl, tt.wantTTL)
+			}
+		})
+	}
+}
+
+func TestParseDNSAnswerErrors(t *testing.T) {
+	ok := dnsAnswer(t, 7, dnsmessage.RCodeSuccess, nil, nil)
+	tests := []struct {
+		name string
+		msg  []byte
+		err  string
+	}{
+		{"NXDOMAIN", dnsAnswer(t, 7, dnsmessage.RCodeNameError, nil, nil), errNoSuchHost.Error()},
+		{"SERVFAIL", dnsAnswer(t, 7, dnsmessage.RCodeServerFailure, nil, nil), "server returned ServerFailure"},
+		{"REFUSED", dnsAnswer(t, 7, dnsmessage.RCodeRefused, nil, nil), "server returned Refused"},
+		{"wrong ID", dnsAnswer(t, 8, dnsmessage.RCodeSuccess, nil, nil), "does not match"},
+		{"truncated header", ok[:6], "invalid answer"},
+		{"truncated question", ok[:len(ok)-2], "invalid answer"},
+	}
+	for _, tt := range tests {
+		_, _, err := parseDNSAnswer(tt.msg, 7)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+	if _, _, err := parseDNSAnswer(dnsAnswer(t, 7, dnsmessage.RCodeNameError, nil, nil), 7); !errors.Is(err, errNoSuchHost) {
+		t.Errorf("NXDOMAIN error %v is not errNoSuchHost", err)
+	}
+}
+
+func TestParseDNSServer(t *testing.T) {
+	tests := []struct {
+		in, network, addr string
+	}{
+		{"8.8.8.8", "udp", "8.8.8.8:53"},
+		{"8.8.8.8:5353", "udp", "8.8.8.8:5353"},
+		{"2001:4860:4860::8888", "udp", "[2001:4860:4860::8888]:53"},
+		{"[2001:4860:4860::8888]", "udp", "[2001:4860:4860::8888]:53"},
+		{"tcp://1.1.1.1", "tcp", "1.1.1.1:53"},
+		{"tls://dns.google", "tls", "dns.google:853"},
+		{"TLS://1.1.1.1:8853/", "tls", "1.1.1.1:8853"},
+		{"https://dns.google/dns-query", "https", "https://dns.google/dns-query"},
+	}
+	for _, tt := range tests {
+		s, err := parseDNSServer(tt.in)
+		if err != nil {
+			t.Errorf("%s: %v", tt.in, err)
+			continue
+		}
+		if s.network != tt.network || s.addr != tt.addr {
+			t.Errorf("%s: got %s %s, want %s %s", tt.in, s.network, s.addr, tt.network, tt.addr)
+		}
+	}
+	if _, err := parseDNSServer("quic://dns.adguard.com"); err == nil {
+		t.Error("quic:// accepted")
+	}
+}
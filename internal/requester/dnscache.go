@@ -0,0 +1,83 @@
+package requester
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+// dnsCacheMax bounds the cached answers: once reached, expired answers
+// are dropped, and if that is not enough, all of them.
+const dnsCacheMax = 1024
+
+// dnsAnswers is shared by every request in the process, so daemon and
+// API mode resolve each host once per TTL rather than once per connection.
+var dnsAnswers = &dnsCache{entries: map[dnsCacheKey]dnsCacheEntry{}}
+
+// dnsCache keeps lookup results until their TTL runs out. Failures are not
+// cached.
+type dnsCache struct {
+	mu      sync.Mutex
+	entries map[dnsCacheKey]dnsCacheEntry
+	now     func() time.Time // nil for time.Now; tests replace it
+}
+
+type dnsCacheKey struct {
+	source string // the DNS server, or "system"
+	host   string
+	kind   string // record type, or network for the system resolver
+}
+
+type dnsCacheEntry struct {
+	ips     []net.IP
+	expires time.Time
+}
+
+func newDNSCacheKey(source, host, kind string) dnsCacheKey {
+	return dnsCacheKey{source: source, host: strings.ToLower(strings.TrimSuffix(host, ".")), kind: kind}
+}
+
+// get returns a cached answer, which may be empty (the name has no
+// records of that type).
+func (c *dnsCache) get(key dnsCacheKey) ([]net.IP, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	if c.clock().After(e.expires) {
+		delete(c.entries, key)
+		return nil, false
+	}
+	return e.ips, true
+}
+
+// put caches ips for ttl; a zero ttl caches nothing.
+func (c *dnsCache) put(key dnsCacheKey, ips []net.IP, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	now := c.clock()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entries) >= dnsCacheMax {
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= dnsCacheMax {
+			clear(c.entries)
+		}
+	}
+	c.entries[key] = dnsCacheEntry{ips: ips, expires: now.Add(ttl)}
+}
+
+func (c *dnsCache) clock() time.Time {
+	if c.now != nil {
+		return c.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,98 @@
+package requester
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+)
+
+// fakeClock is a time that only moves when told to.
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestDNSCache() (*dnsCache, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	return &dnsCache{entries: map[dnsCacheKey]dnsCacheEntry{}, now: clock.now}, clock
+}
+
+func TestDNSCacheTTL(t *testing.T) {
+	c, clock := newTestDNSCache()
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+	key := newDNSCacheKey("8.8.8.8", "Example.COM.", "A")
+	c.put(key, ips, 30*time.Second)
+
+	if got, ok := c.get(newDNSCacheKey("8.8.8.8", "example.com", "A")); !ok || !got[0].Equal(ips[0]) {
+		t.Fatalf("get after put: %v, %v", got, ok)
+	}
+	for _, other := range []dnsCacheKey{
+		newDNSCacheKey("1.1.1.1", "example.com", "A"),
+		newDNSCacheKey("8.8.8.8", "example.com", "AAAA"),
+		newDNSCacheKey("8.8.8.8", "www.example.com", "A"),
+	} {
+		if _, ok := c.get(other); ok {
+			t.Errorf("%+v answered from %+v", other, key)
+		}
+	}
+
+	clock.advance(30 * time.Second)
+	if _, ok := c.get(key); !ok {
+		t.Error("expired at exactly its TTL")
+	}
+	clock.advance(time.Second)
+	if _, ok := c.get(key); ok {
+		t.Error("answer outlived its TTL")
+	}
+	if len(c.entries) != 0 {
+		t.Errorf("expired answer kept: %d entries", len(c.entries))
+	}
+
+	// An empty answer is cached too; a zero TTL caches nothing.
+	c.put(key, nil, time.Minute)
+	if got, ok := c.get(key); !ok || len(got) != 0 {
+		t.Errorf("empty answer: %v, %v", got, ok)
+	}
+	zero := newDNSCacheKey("8.8.8.8", "zero.example", "A")
+	c.put(zero, ips, 0)
+	if _, ok := c.get(zero); ok {
+		t.Error("zero TTL answer cached")
+	}
+}
+
+func TestDNSCacheEviction(t *testing.T) {
+	c, clock := newTestDNSCache()
+	key := func(i int) dnsCacheKey { return newDNSCacheKey("system", fmt.Sprintf("host%d.example", i), "ip") }
+	ips := []net.IP{net.ParseIP("192.0.2.1")}
+
+	// Half the entries expire before the cache fills up.
+	for i := range dnsCacheMax / 2 {
+		c.put(key(i), ips, time.Minute)
+	}
+	for i := dnsCacheMax / 2; i < dnsCacheMax; i++ {
+		c.put(key(i), ips, time.Hour)
+	}
+	clock.advance(2 * time.Minute)
+	c.put(key(dnsCacheMax), ips, time.Hour)
+	if len(c.entries) != dnsCacheMax/2+1 {
+		t.Fatalf("%d entries after dropping expired ones, want %d", len(c.entries), dnsCacheMax/2+1)
+	}
+	if _, ok := c.get(key(dnsCacheMax - 1)); !ok {
+		t.Error("live answer dropped")
+	}
+
+	// With every entry live, a full cache starts over.
+	for i := dnsCacheMax + 1; len(c.entries) < dnsCacheMax; i++ {
+		c.put(key(i), ips, time.Hour)
+	}
+	c.put(key(-1), ips, time.Hour)
+	if len(c.entries) != 1 {
+		t.Errorf("%d entries after overflowing with live answers, want 1", len(c.entries))
+	}
+	if _, ok := c.get(key(-1)); !ok {
+		t.Error("newest answer dropped")
+	}
+}
@@ -0,0 +1,44 @@
+package requester
+
+import (
+	"errors"
+	"net"
+	"strings"
+)
+
+// ConfigError is a config section that cannot be used as given, found
+// only when a request uses it (request overrides are not checked on
+// load).
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string { return e.Err.Error() }
+func (e *ConfigError) Unwrap() error { return e.Err }
+
+// ClassifyError maps a request error to its error_type and the exit code
+// the command line uses for it.
+func ClassifyError(err error) (string, int) {
+	var certErr *CertificateError
+	if errors.As(err, &certErr) {
+		return "CERTIFICATE_ERROR", 5
+	}
+	var authErr *ProxyAuthError
+	if errors.As(err, &authErr) {
+		return "PROXY_AUTH_ERROR", 6
+	}
+	var cfgErr *ConfigError
+	if errors.As(err, &cfgErr) {
+		return "CONFIG_ERROR", 4
+	}
+	var dnsErr *DNSError
+	var sysDNSErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.As(err, &sysDNSErr) {
+		return "DNS_ERROR", 7
+	}
+	errMsg := err.Error()
+	if strings.Contains(errMsg, "timeout") || strings.Contains(errMsg, "deadline") {
+		return "TIMEOUT_ERROR", 3
+	}
+	return "NETWORK_ERROR", 2 // Default: network error
+}
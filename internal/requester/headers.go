@@ -0,0 +1,231 @@
+package requester
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"sort"
+	"strconv"
+	"strings"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// HeaderOrderKey is a pseudo header listing header names in wire order. It
+// is never sent. A name may repeat to interleave duplicate headers; names
+// keep the case given here, which matters for HTTP/1.1.
+const HeaderOrderKey = "Header-Order:"
+
+// newHeader builds an http.Header from an ordered list, recording the order
+// under HeaderOrderKey.
+func newHeader(headers config.Headers) http.Header {
+	h := make(http.Header)
+	names := make([]string, 0, len(headers))
+	for _, hdr := range headers {
+		h.Add(hdr.Name, hdr.Value)
+		names = append(names, hdr.Name)
+	}
+	h[HeaderOrderKey] = names
+	return h
+}
+
+// prepareHeaders returns a copy of req.Header with the headers Go adds
+// implicitly (Host for HTTP/1.1, User-Agent, Content-Length) and a
+// HeaderOrderKey entry naming every header. Implicit headers the caller did
+// not place go where Go puts them: Host first, the others last.
+func prepareHeaders(req *http.Request, http1 bool) http.Header {
+	h := req.Header.Clone()
+	if h == nil {
+		h = make(http.Header)
+	}
+	names := h[HeaderOrderKey]
+	delete(h, HeaderOrderKey)
+
+	listed := map[string]bool{}
+	for _, name := range names {
+		listed[http.CanonicalHeaderKey(name)] = true
+	}
+	rest := []string{}
+	for key := range h {
+		if !listed[http.CanonicalHeaderKey(key)] {
+			rest = append(rest, key)
+			listed[http.CanonicalHeaderKey(key)] = true
+		}
+	}
+	sort.Strings(rest)
+	names = append(append([]string{}, names...), rest...)
+
+	if http1 && !listed["Host"] {
+		host := req.Host
+		if host == "" {
+			host = req.URL.Host
+		}
+		h.Set("Host", host)
+		names = append([]string{"Host"}, names...)
+	}
+	if !listed["User-Agent"] {
+		ua := "Go-http-client/2.0"
+		if http1 {
+			ua = "Go-http-client/1.1"
+		}
+		h.Set("User-Agent", ua)
+		names = append(names, "User-Agent")
+	}
+	if sendsContentLength(req) && !listed["Content-Length"] {
+		h.Set("Content-Length", strconv.FormatInt(req.ContentLength, 10))
+		names = append(names, "Content-Length")
+	}
+	if http1 && chunkedBody(req) {
+		h.Set("Transfer-Encoding", "chunked")
+		names = append(names, "Transfer-Encoding")
+	}
+
+	h[HeaderOrderKey] = names
+	return h
+}
+
+// wireHeaders flattens h into wire order: the names in h[HeaderOrderKey],
+// each occurrence taking the next value (the last occurrence takes any
+// left), then stable-sorted so names in order (case-insensitive) come first
+// in that order.
+func wireHeaders(h http.Header, order []string) config.Headers {
+	names := h[HeaderOrderKey]
+	remaining := map[string]int{}
+	for _, name := range names {
+		remaining[http.CanonicalHeaderKey(name)]++
+	}
+
+	out := config.Headers{}
+	used := map[string]int{}
+	for _, name := range names {
+		key := http.CanonicalHeaderKey(name)
+		values, ok := h[name]
+		if !ok {
+			values = h[key]
+		}
+		remaining[key]--
+		i := used[key]
+		if i >= len(values) {
+			continue
+		}
+		end := i + 1
+		if remaining[key] == 0 {
+			end = len(values)
+		}
+		for _, v := range values[i:end] {
+			out = append(out, config.Header{Name: name, Value: v})
+		}
+		used[key] = end
+	}
+
+	if len(order) > 0 {
+		rank := map[string]int{}
+		for i, name := range order {
+			if _, ok := rank[strings.ToLower(name)]; !ok {
+				rank[strings.ToLower(name)] = i
+			}
+		}
+		rankOf := func(name string) int {
+			if r, ok := rank[strings.ToLower(name)]; ok {
+				return r
+			}
+			return len(order)
+		}
+		sort.SliceStable(out, func(i, j int) bool { return rankOf(out[i].Name) < rankOf(out[j].Name) })
+	}
+	return out
+}
+
+// ResponseHeaders returns resp's headers in the order the server sent them,
+// duplicates included. Responses without a recorded order (HeaderOrderKey)
+// list their headers sorted by name.
+func ResponseHeaders(resp *http.Response) config.Headers {
+	h := resp.Header
+	if _, ok := h[HeaderOrderKey]; !ok {
+		names := make([]string, 0, len(h))
+		for k := range h {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		h = h.Clone()
+		h[HeaderOrderKey] = names
+	}
+	return wireHeaders(h, nil)
+}
+
+// sendsContentLength reports whether req carries a Content-Length header.
+// Like net/http, an empty POST, PUT or PATCH sends "0", since servers may
+// answer a body-less one with 411 Length Required.
+func sendsContentLength(req *http.Request) bool {
+	if req.ContentLength > 0 {
+		return true
+	}
+	if req.ContentLength < 0 || (req.Body != nil && req.Body != http.NoBody) {
+		return false
+	}
+	switch req.Method {
+	case "POST", "PUT", "PATCH":
+		return true
+	}
+	return false
+}
+
+// chunkedBody reports a body of unknown length, which HTTP/1.1 sends with
+// chunked transfer encoding.
+func chunkedBody(req *http.Request) bool {
+	return req.ContentLength < 0 && req.Body != nil && req.Body != http.NoBody
+}
+
+// writeRequest writes an HTTP/1.1 request with headers exactly in the
+// given order, which http.Request.Write does not allow.
+func writeRequest(w io.Writer, req *http.Request, headers config.Headers) error {
+	for _, hdr := range headers {
+		if err := config.CheckHeaderName(hdr.Name); err != nil {
+			return err
+		}
+	}
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s %s HTTP/1.1\r\n", req.Method, req.URL.RequestURI())
+	for _, hdr := range headers {
+		fmt.Fprintf(bw, "%s: %s\r\n", hdr.Name, strings.NewReplacer("\r", " ", "\n", " ").Replace(hdr.Value))
+	}
+	bw.WriteString("\r\n")
+	if req.Body != nil && req.Body != http.NoBody {
+		var body io.Writer = bw
+		chunked := chunkedBody(req)
+		if chunked {
+			body = httputil.NewChunkedWriter(bw)
+		}
+		_, err := io.Copy(body, req.Body)
+		req.Body.Close()
+		if err != nil {
+			return err
+		}
+		if chunked {
+			body.(io.Closer).Close()
+			bw.WriteString("\r\n") // no trailers
+		}
+	}
+	return bw.Flush()
+}
+
+// withCookies returns req with jar cookies added to its Cookie header,
+// after any the caller set. req itself is not modified, so a redirect
+// starts again from the caller's headers.
+func withCookies(req *http.Request, cookies []*http.Cookie) *http.Request {
+	if len(cookies) == 0 {
+		return req
+	}
+	pairs := make([]string, 0, len(cookies)+1)
+	if existing := req.Header.Get("Cookie"); existing != "" {
+		pairs = append(pairs, existing)
+	}
+	for _, c := range cookies {
+		pairs = append(pairs, c.Name+"="+c.Value)
+	}
+	r := req.Clone(req.Context())
+	r.Header.Set("Cookie", strings.Join(pairs, "; "))
+	return r
+}
@@ -0,0 +1,84 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestEmptyBodyContentLength(t *testing.T) {
+	tests := []struct {
+		method string
+		body   string
+		length int64 // -1 for a body of unknown length
+		want   string
+	}{
+		{"GET", "", 0, ""},
+		{"HEAD", "", 0, ""},
+		{"DELETE", "", 0, ""},
+		{"POST", "", 0, "0"},
+		{"PUT", "", 0, "0"},
+		{"PATCH", "", 0, "0"},
+		{"POST", "a=1", 3, "3"},
+		{"POST", "a=1", -1, ""},
+	}
+	newRequest := func(method, body string, length int64) *http.Request {
+		req, _ := http.NewRequest(method, "https://example.com/", strings.NewReader(body))
+		if length < 0 {
+			req.Body = io.NopCloser(strings.NewReader(body))
+			req.ContentLength = -1
+		}
+		return req
+	}
+	for _, tt := range tests {
+		name := tt.method
+		if tt.length != 0 {
+			name += " with body"
+		}
+
+		t.Run("HTTP/1.1 "+name, func(t *testing.T) {
+			req := newRequest(tt.method, tt.body, tt.length)
+			var wire bytes.Buffer
+			if err := writeRequest(&wire, req, wireHeaders(prepareHeaders(req, true), nil)); err != nil {
+				t.Fatal(err)
+			}
+			got, err := http.ReadRequest(bufio.NewReader(&wire))
+			if err != nil {
+				t.Fatalf("%v\n%s", err, wire.Bytes())
+			}
+			if cl := got.Header.Get("Content-Length"); cl != tt.want {
+				t.Errorf("Content-Length %q, want %q", cl, tt.want)
+			}
+			if chunked := len(got.TransferEncoding) > 0; chunked != (tt.length < 0) {
+				t.Errorf("Transfer-Encoding %v", got.TransferEncoding)
+			}
+		})
+
+		t.Run("HTTP/2 "+name, func(t *testing.T) {
+			client, server := connPair(t)
+			cc, err := newHTTP2Conn(client, testHTTP2Config())
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cc.Close()
+			go cc.RoundTrip(newRequest(tt.method, tt.body, tt.length))
+
+			_, _, headers := readPreface(t, server)
+			cl, sent := "", false
+			for _, f := range headers.RegularFields() {
+				if f.Name == "content-length" {
+					cl, sent = f.Value, true
+				}
+				if f.Name == "transfer-encoding" {
+					t.Errorf("sent transfer-encoding over HTTP/2")
+				}
+			}
+			if cl != tt.want || sent != (tt.want != "") {
+				t.Errorf("content-length %q (sent %v), want %q", cl, sent, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package requester
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// connectTarget is the host:port dialed for u: its origin, unless a
+// connect_to entry sends it elsewhere. SNI and Host still use u.
+func connectTarget(u *url.URL, cfg *config.Config) (string, error) {
+	addr := originAddr(u)
+	host, port, _ := net.SplitHostPort(addr)
+	for _, entry := range cfg.ConnectTo {
+		f, err := splitEntry(entry, 4)
+		if err != nil {
+			return "", fmt.Errorf("invalid connect_to entry %q: %v", entry, err)
+		}
+		if f[0] != "" && !strings.EqualFold(f[0], host) || f[1] != "" && f[1] != port {
+			continue
+		}
+		if f[2] != "" {
+			host = f[2]
+		}
+		if f[3] != "" {
+			port = f[3]
+		}
+		return net.JoinHostPort(host, port), nil
+	}
+	return addr, nil
+}
+
+// staticLookup returns the addresses a resolve entry or the hosts map
+// gives host:port, so that no DNS query is made for it.
+func staticLookup(cfg *config.Config, host, port string) ([]net.IP, error) {
+	for _, entry := range cfg.Resolve {
+		f, err := splitEntry(entry, 3)
+		if err != nil {
+			return nil, fmt.Errorf("invalid resolve entry %q: %v", entry, err)
+		}
+		if f[0] != "*" && !strings.EqualFold(f[0], host) || f[1] != port {
+			continue
+		}
+		var ips []net.IP
+		for _, a := range strings.Split(f[2], ",") {
+			ip := net.ParseIP(strings.Trim(a, "[]"))
+			if ip == nil {
+				return nil, fmt.Errorf("invalid resolve entry %q: %q is not an IP address", entry, a)
+			}
+			ips = append(ips, ip)
+		}
+		return ips, nil
+	}
+
+	host = strings.ToLower(host)
+	if addr, ok := cfg.Hosts[host]; ok {
+		return hostsAddr(host, addr)
+	}
+	for _, pattern := range hostsPatterns(cfg.Hosts) {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return hostsAddr(pattern, cfg.Hosts[pattern])
+		}
+	}
+	return nil, nil
+}
+
+// hostsPatterns returns the glob keys of hosts in the order they are
+// tried: longest, so most specific, first, then alphabetically, so that
+// "*.api.example.com" wins over "*.example.com" every time.
+func hostsPatterns(hosts map[string]string) []string {
+	var patterns []string
+	for name := range hosts {
+		if strings.ContainsAny(name, "*?[") {
+			patterns = append(patterns, name)
+		}
+	}
+	slices.SortFunc(patterns, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(b) - len(a)
+		}
+		return strings.Compare(a, b)
+	})
+	return patterns
+}
+
+func hostsAddr(name, addr string) ([]net.IP, error) {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return nil, fmt.Errorf("invalid hosts entry %q: %q is not an IP address", name, addr)
+	}
+	return []net.IP{ip}, nil
+}
+
+// splitEntry splits a curl-style colon-separated entry into n fields;
+// colons inside [brackets] (IPv6 addresses) do not split, and the last
+// field keeps any remaining text.
+func splitEntry(entry string, n int) ([]string, error) {
+	var fields []string
+	depth, start := 0, 0
+	for i := 0; i < len(entry) && len(fields) < n-1; i++ {
+		switch entry[i] {
+		case '[':
+			depth++
+		case ']':
+			depth--
+		case ':':
+			if depth == 0 {
+				fields = append(fields, strings.Trim(entry[start:i], "[]"))
+				start = i + 1
+			}
+		}
+	}
+	fields = append(fields, entry[start:])
+	if len(fields) != n {
+		return nil, fmt.Errorf("want %d colon-separated fields", n)
+	}
+	return fields, nil
+}
+
+// overrideKey describes where connect_to, resolve and hosts send u, for
+// the pool key; it is empty when none of them apply.
+func overrideKey(u *url.URL, cfg *config.Config) string {
+	if len(cfg.ConnectTo) == 0 && len(cfg.Resolve) == 0 && len(cfg.Hosts) == 0 {
+		return ""
+	}
+	addr, err := connectTarget(u, cfg)
+	if err != nil {
+		return err.Error()
+	}
+	host, port, _ := net.SplitHostPort(addr)
+	ips, err := staticLookup(cfg, host, port)
+	if err != nil {
+		return err.Error()
+	}
+	if addr == originAddr(u) && ips == nil {
+		return ""
+	}
+	return fmt.Sprintf("%s%v", addr, ips)
+}
@@ -0,0 +1,171 @@
+package requester
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+)
+
+func TestSplitEntry(t *testing.T) {
+	tests := []struct {
+		entry string
+		n     int
+		want  []string // nil for an error
+	}{
+		{"example.com:443:192.0.2.1", 3, []string{"example.com", "443", "192.0.2.1"}},
+		{"example.com:443:192.0.2.1,192.0.2.2", 3, []string{"example.com", "443", "192.0.2.1,192.0.2.2"}},
+		{"example.com:443:[2001:db8::1]", 3, []string{"example.com", "443", "[2001:db8::1]"}},
+		{"example.com:443:[2001:db8::1],[2001:db8::2]", 3, []string{"example.com", "443", "[2001:db8::1],[2001:db8::2]"}},
+		{"[::1]:443:[2001:db8::1]:8443", 4, []string{"::1", "443", "2001:db8::1", "8443"}},
+		{"::127.0.0.1:", 4, []string{"", "", "127.0.0.1", ""}},
+		{"example.com:443:", 3, []string{"example.com", "443", ""}},
+		{"example.com:443", 3, nil},
+		{"example.com", 4, nil},
+	}
+	for _, tt := range tests {
+		got, err := splitEntry(tt.entry, tt.n)
+		if tt.want == nil {
+			if err == nil {
+				t.Errorf("%q: got %q, want an error", tt.entry, got)
+			}
+			continue
+		}
+		if err != nil || !slices.Equal(got, tt.want) {
+			t.Errorf("%q: got %q, %v; want %q", tt.entry, got, err, tt.want)
+		}
+	}
+}
+
+func TestConnectTarget(t *testing.T) {
+	tests := []struct {
+		url       string
+		connectTo []string
+		want      string
+		err       bool
+	}{
+		{"https://example.com/", nil, "example.com:443", false},
+		{"http://example.com/", nil, "example.com:80", false},
+		{"https://example.com/", []string{"example.com:443:origin.example.net:8443"}, "origin.example.net:8443", false},
+		{"https://EXAMPLE.com/", []string{"example.com:443:origin.example.net:"}, "origin.example.net:443", false},
+		{"https://example.com/", []string{"example.com:80:origin.example.net:8443"}, "example.com:443", false},
+		{"https://example.com:8443/", []string{"::127.0.0.1:"}, "127.0.0.1:8443", false},
+		{"https://example.com/", []string{":443::8443"}, "example.com:8443", false},
+		{"https://example.com/", []string{"::[2001:db8::1]:"}, "[2001:db8::1]:443", false},
+		{"https://[2001:db8::2]/", []string{"[2001:db8::2]:443:192.0.2.1:"}, "192.0.2.1:443", false},
+		{"https://example.com/", []string{"other.example:443:a.example:", "example.com::b.example:", "::c.example:"}, "b.example:443", false},
+		{"https://example.com/", []string{"example.com"}, "", true},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{ConnectTo: tt.connectTo}
+		got, err := connectTarget(mustURL(tt.url), cfg)
+		if tt.err {
+			if err == nil || !strings.Contains(err.Error(), "invalid connect_to entry") {
+				t.Errorf("%s %q: error %v", tt.url, tt.connectTo, err)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("%s %q: got %s, %v; want %s", tt.url, tt.connectTo, got, err, tt.want)
+		}
+	}
+}
+
+func TestStaticLookup(t *testing.T) {
+	hosts := map[string]string{
+		"api.example.com":     "192.0.2.1",
+		"*.example.com":       "192.0.2.2",
+		"*.api.example.com":   "192.0.2.3",
+		"*.b.api.example.com": "192.0.2.4",
+		"?.api.example.com":   "192.0.2.5",
+		"*.example.org":       "2001:db8::1",
+	}
+	tests := []struct {
+		host, port string
+		resolve    []string
+		want       string // "" for no match; "error" for an error
+	}{
+		{"api.example.com", "443", nil, "[192.0.2.1]"},
+		{"API.Example.COM", "443", nil, "[192.0.2.1]"},
+		{"www.example.com", "443", nil, "[192.0.2.2]"},
+		{"v1.api.example.com", "443", nil, "[192.0.2.3]"},
+		{"a.b.api.example.com", "443", nil, "[192.0.2.4]"},
+		// "?.api.example.com" and "*.api.example.com" are as long; the
+		// tie goes to "*" by byte order.
+		{"x.api.example.com", "443", nil, "[192.0.2.3]"},
+		{"www.example.org", "80", nil, "[2001:db8::1]"},
+		{"example.net", "443", nil, ""},
+		{"api.example.com", "443", []string{"api.example.com:443:203.0.113.1,[2001:db8::2]"}, "[203.0.113.1 2001:db8::2]"},
+		{"api.example.com", "8443", []string{"api.example.com:443:203.0.113.1"}, "[192.0.2.1]"},
+		{"anything.test", "443", []string{"*:443:203.0.113.9"}, "[203.0.113.9]"},
+		{"api.example.com", "443", []string{"api.example.com:443:not-an-ip"}, "error"},
+		{"api.example.com", "443", []string{"api.example.com"}, "error"},
+	}
+	for _, tt := range tests {
+		cfg := &config.Config{Hosts: hosts, Resolve: tt.resolve}
+		// Map order varies between runs; the answer must not.
+		for range 20 {
+			ips, err := staticLookup(cfg, tt.host, tt.port)
+			got := ""
+			switch {
+			case err != nil:
+				got = "error"
+			case ips != nil:
+				got = fmt.Sprint(ips)
+			}
+			if got != tt.want {
+				t.Errorf("%s:%s %q: got %s (%v), want %s", tt.host, tt.port, tt.resolve, got, err, tt.want)
+				break
+			}
+		}
+	}
+
+	_, err := staticLookup(&config.Config{Hosts: map[string]string{"*.example.com": "nope"}}, "a.example.com", "443")
+	if err == nil || !strings.Contains(err.Error(), "invalid hosts entry") {
+		t.Errorf("bad hosts address: error %v", err)
+	}
+}
+
+func TestOverrideKey(t *testing.T) {
+	u := mustURL("https://example.com/path")
+	tests := []struct {
+		name string
+		cfg  config.Config
+		want string
+	}{
+		{"none", config.Config{}, ""},
+		{"entries for other hosts", config.Config{
+			Hosts:     map[string]string{"other.example": "192.0.2.1"},
+			Resolve:   []string{"other.example:443:192.0.2.1"},
+			ConnectTo: []string{"other.example:443:x.example:"},
+		}, ""},
+		{"hosts", config.Config{Hosts: map[string]string{"example.com": "192.0.2.1"}}, "example.com:443[192.0.2.1]"},
+		{"resolve", config.Config{Resolve: []string{"example.com:443:192.0.2.1,192.0.2.2"}}, "example.com:443[192.0.2.1 192.0.2.2]"},
+		{"connect_to", config.Config{ConnectTo: []string{"example.com:443:origin.example.net:8443"}}, "origin.example.net:8443[]"},
+		{"connect_to then resolve", config.Config{
+			ConnectTo: []string{"::origin.example.net:"},
+			Resolve:   []string{"origin.example.net:443:[2001:db8::1]"},
+		}, "origin.example.net:443[2001:db8::1]"},
+		{"invalid entry", config.Config{ConnectTo: []string{"bad"}}, `invalid connect_to entry "bad": want 4 colon-separated fields`},
+	}
+	keys := map[string]string{}
+	for _, tt := range tests {
+		got := overrideKey(u, &tt.cfg)
+		if got != tt.want {
+			t.Errorf("%s: key %q, want %q", tt.name, got, tt.want)
+		}
+		if other, ok := keys[got]; ok && got != "" {
+			t.Errorf("%s and %s share key %q", tt.name, other, got)
+		}
+		keys[got] = tt.name
+	}
+
+	// Keys differ whenever the dialed addresses do.
+	a := overrideKey(u, &config.Config{Hosts: map[string]string{"*.com": "192.0.2.1"}})
+	b := overrideKey(u, &config.Config{Hosts: map[string]string{"*.com": "192.0.2.2"}})
+	if a == b {
+		t.Errorf("different hosts addresses share key %q", a)
+	}
+}
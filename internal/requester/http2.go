@@ -0,0 +1,761 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"fingerPrintRequester/internal/config"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// http2Conn is a client HTTP/2 connection whose preface (SETTINGS order and
+// values, connection WINDOW_UPDATE, PRIORITY frames) and request HEADERS
+// follow config.HTTP2Config, which x/net/http2.Transport cannot do.
+type http2Conn struct {
+	conn        net.Conn
+	tlsState    *tls.ConnectionState
+	cfg         config.HTTP2Config
+	pseudoOrder []string
+	headerOrder []string
+
+	// wmu serializes frame writes and the HPACK encoder state.
+	wmu  sync.Mutex
+	bw   *bufio.Writer
+	fr   *http2.Framer
+	henc *hpack.Encoder
+	hbuf bytes.Buffer
+
+	mu                sync.Mutex
+	cond              *sync.Cond // send windows changed or connection died
+	streams           map[uint32]*http2Stream
+	nextStreamID      uint32
+	peerMaxFrameSize  uint32
+	peerInitialWindow int32
+	peerMaxStreams    uint32
+	sendWindow        int32
+	recvWindow        int32 // connection window we advertised
+	recvUnacked       int32
+	streamRecvWindow  int32
+	goAway            bool
+	retired           bool // close once the last stream ends
+	err               error
+}
+
+type http2Stream struct {
+	id   uint32
+	cc   *http2Conn
+	req  *http.Request
+	body *http2Body
+	resp *http.Response // set by the read loop
+
+	sendWindow int32 // guarded by cc.mu
+	abortErr   error // guarded by cc.mu
+
+	resOnce sync.Once
+	resc    chan http2Result
+	done    chan struct{}
+}
+
+type http2Result struct {
+	resp *http.Response
+	err  error
+}
+
+func newHTTP2Conn(conn net.Conn, c *config.Config) (*http2Conn, error) {
+	cfg := c.HTTP2.WithDefaults()
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	cc := &http2Conn{
+		conn:              conn,
+		tlsState:          connectionState(conn),
+		cfg:               cfg,
+		pseudoOrder:       c.PseudoHeaderOrder,
+		headerOrder:       c.HeaderOrder,
+		streams:           map[uint32]*http2Stream{},
+		nextStreamID:      1,
+		peerMaxFrameSize:  16384,
+		peerInitialWindow: 65535,
+		peerMaxStreams:    100,
+		sendWindow:        65535,
+		recvWindow:        65535 + int32(*cfg.WindowUpdate),
+		streamRecvWindow:  int32(cfg.Setting(uint16(http2.SettingInitialWindowSize), 65535)),
+	}
+	cc.cond = sync.NewCond(&cc.mu)
+	cc.bw = bufio.NewWriter(conn)
+	cc.fr = http2.NewFramer(cc.bw, bufio.NewReader(conn))
+	cc.fr.ReadMetaHeaders = hpack.NewDecoder(cfg.Setting(uint16(http2.SettingHeaderTableSize), 4096), nil)
+	cc.fr.MaxHeaderListSize = cfg.Setting(uint16(http2.SettingMaxHeaderListSize), 10<<20)
+	cc.fr.SetMaxReadFrameSize(cfg.Setting(uint16(http2.SettingMaxFrameSize), 16384))
+	cc.henc = hpack.NewEncoder(&cc.hbuf)
+
+	settings := make([]http2.Setting, len(cfg.Settings))
+	for i, s := range cfg.Settings {
+		settings[i] = http2.Setting{ID: http2.SettingID(s.ID), Val: s.Value}
+	}
+
+	cc.bw.WriteString(http2.ClientPreface)
+	cc.fr.WriteSettings(settings...)
+	if *cfg.WindowUpdate > 0 {
+		cc.fr.WriteWindowUpdate(0, *cfg.WindowUpdate)
+	}
+	for _, p := range cfg.Priorities {
+		cc.fr.WritePriority(p.StreamID, priorityParam(p.HTTP2PriorityParam))
+		// Streams named in the priority tree are idle placeholders
+		if p.StreamID >= cc.nextStreamID {
+			cc.nextStreamID = p.StreamID + 2
+		}
+	}
+	if err := cc.bw.Flush(); err != nil {
+		return nil, err
+	}
+
+	go cc.readLoop()
+	return cc, nil
+}
+
+// priorityParam converts a validated priority to its wire form.
+func priorityParam(p config.HTTP2PriorityParam) http2.PriorityParam {
+	return http2.PriorityParam{
+		StreamDep: p.DependsOn,
+		Exclusive: p.Exclusive,
+		Weight:    uint8(p.Weight - 1),
+	}
+}
+
+// writePrioritizedHeaders writes a HEADERS frame with the PRIORITY flag
+// set. Framer.WriteHeaders drops the flag for an all-zero priority
+// (weight 1, no dependency), which is still a priority the fingerprint
+// has to carry.
+func (cc *http2Conn) writePrioritizedHeaders(p http2.HeadersFrameParam, prio config.HTTP2PriorityParam) error {
+	flags := http2.FlagHeadersPriority
+	if p.EndStream {
+		flags |= http2.FlagHeadersEndStream
+	}
+	if p.EndHeaders {
+		flags |= http2.FlagHeadersEndHeaders
+	}
+	dep := prio.DependsOn
+	if prio.Exclusive {
+		dep |= 1 << 31
+	}
+	payload := binary.BigEndian.AppendUint32(make([]byte, 0, 5+len(p.BlockFragment)), dep)
+	payload = append(payload, uint8(prio.Weight-1))
+	payload = append(payload, p.BlockFragment...)
+	return cc.fr.WriteRawFrame(http2.FrameHeaders, flags, p.StreamID, payload)
+}
+
+// CanTakeNewRequest reports whether another stream can be opened.
+func (cc *http2Conn) CanTakeNewRequest() bool {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.err == nil && !cc.goAway && uint32(len(cc.streams)) < cc.peerMaxStreams && cc.nextStreamID < 1<<31
+}
+
+// retire closes the connection once its open streams finish; a pool calls
+// it when the session stops taking new requests.
+func (cc *http2Conn) retire() {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	cc.retired = true
+	if len(cc.streams) == 0 {
+		cc.conn.Close()
+	}
+}
+
+func (cc *http2Conn) Close() error {
+	cc.closeWithError(errors.New("http2: connection closed"))
+	return nil
+}
+
+// RoundTrip sends req on a new stream and returns once response headers
+// arrive. The body streams from the connection as the caller reads it.
+func (cc *http2Conn) RoundTrip(req *http.Request) (*http.Response, error) {
+	hasBody := req.Body != nil && req.Body != http.NoBody
+
+	cs := &http2Stream{
+		cc:   cc,
+		req:  req,
+		resc: make(chan http2Result, 1),
+		done: make(chan struct{}),
+	}
+	cs.body = &http2Body{stream: cs}
+	cs.body.cond = sync.NewCond(&cs.body.mu)
+
+	// Stream IDs must hit the wire in increasing order, so allocate the ID
+	// under the write lock.
+	cc.wmu.Lock()
+	cc.mu.Lock()
+	if cc.err != nil || cc.goAway {
+		err := cc.err
+		cc.mu.Unlock()
+		cc.wmu.Unlock()
+		if err == nil {
+			err = errors.New("http2: connection is shutting down")
+		}
+		return nil, err
+	}
+	cs.id = cc.nextStreamID
+	cc.nextStreamID += 2
+	cs.sendWindow = cc.peerInitialWindow
+	cc.streams[cs.id] = cs
+	cc.mu.Unlock()
+	err := cc.writeHeaders(cs.id, cc.encodeHeaders(req), !hasBody)
+	cc.wmu.Unlock()
+	if err != nil {
+		cc.closeWithError(err)
+		return nil, err
+	}
+
+	if hasBody {
+		if err := cs.writeBody(req.Body); err != nil {
+			cs.cancel(err)
+			return nil, err
+		}
+	}
+	timerFrom(req.Context()).wrote()
+
+	ctx := req.Context()
+	select {
+	case res := <-cs.resc:
+		if res.err != nil {
+			return nil, res.err
+		}
+		if ctx.Done() != nil {
+			go func() {
+				select {
+				case <-ctx.Done():
+					cs.cancel(ctx.Err())
+				case <-cs.done:
+				}
+			}()
+		}
+		return res.resp, nil
+	case <-ctx.Done():
+		cs.cancel(ctx.Err())
+		return nil, ctx.Err()
+	}
+}
+
+// encodeHeaders HPACK-encodes the request. Must be called with wmu held.
+func (cc *http2Conn) encodeHeaders(req *http.Request) []byte {
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+	pseudo := map[string]string{
+		":method":    req.Method,
+		":authority": host,
+		":scheme":    req.URL.Scheme,
+		":path":      req.URL.RequestURI(),
+	}
+
+	cc.hbuf.Reset()
+	for _, name := range config.PseudoOrder(cc.pseudoOrder) {
+		cc.henc.WriteField(hpack.HeaderField{Name: name, Value: pseudo[name]})
+	}
+
+	for _, hdr := range wireHeaders(prepareHeaders(req, false), cc.headerOrder) {
+		name := strings.ToLower(hdr.Name)
+		if isConnectionHeader(name) {
+			continue
+		}
+		cc.henc.WriteField(hpack.HeaderField{Name: name, Value: hdr.Value})
+	}
+	return append([]byte{}, cc.hbuf.Bytes()...)
+}
+
+// isConnectionHeader reports headers HTTP/2 forbids (RFC 9113 8.2.2);
+// Host travels as :authority.
+func isConnectionHeader(lower string) bool {
+	switch lower {
+	case "host", "connection", "keep-alive", "proxy-connection", "transfer-encoding", "upgrade":
+		return true
+	}
+	return false
+}
+
+// writeHeaders sends a header block, split into CONTINUATION frames if it
+// exceeds the peer's frame size. Must be called with wmu held.
+func (cc *http2Conn) writeHeaders(streamID uint32, block []byte, endStream bool) error {
+	cc.mu.Lock()
+	maxFrame := int(cc.peerMaxFrameSize)
+	cc.mu.Unlock()
+
+	first := true
+	for first || len(block) > 0 {
+		chunk := block
+		if len(chunk) > maxFrame {
+			chunk = chunk[:maxFrame]
+		}
+		block = block[len(chunk):]
+		endHeaders := len(block) == 0
+		var err error
+		if first {
+			p := http2.HeadersFrameParam{
+				StreamID:      streamID,
+				BlockFragment: chunk,
+				EndStream:     endStream,
+				EndHeaders:    endHeaders,
+			}
+			if cc.cfg.HeaderPriority != nil {
+				err = cc.writePrioritizedHeaders(p, *cc.cfg.HeaderPriority)
+			} else {
+				err = cc.fr.WriteHeaders(p)
+			}
+			first = false
+		} else {
+			err = cc.fr.WriteContinuation(streamID, endHeaders, chunk)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return cc.bw.Flush()
+}
+
+// writeBody sends the request body as DATA frames within the flow-control
+// windows, ending the stream after the last chunk.
+func (cs *http2Stream) writeBody(body io.ReadCloser) error {
+	defer body.Close()
+	cc := cs.cc
+	buf := make([]byte, 16384)
+	for {
+		n, rerr := body.Read(buf)
+		data := buf[:n]
+		for len(data) > 0 {
+			take, err := cs.awaitSendWindow(len(data))
+			if err != nil {
+				return err
+			}
+			cc.wmu.Lock()
+			err = cc.fr.WriteData(cs.id, false, data[:take])
+			if err == nil {
+				err = cc.bw.Flush()
+			}
+			cc.wmu.Unlock()
+			if err != nil {
+				return err
+			}
+			data = data[take:]
+		}
+		if rerr == io.EOF {
+			break
+		}
+		if rerr != nil {
+			return rerr
+		}
+	}
+	cc.wmu.Lock()
+	defer cc.wmu.Unlock()
+	if err := cc.fr.WriteData(cs.id, true, nil); err != nil {
+		return err
+	}
+	return cc.bw.Flush()
+}
+
+// awaitSendWindow blocks until some of want bytes may be sent and reserves
+// them from the connection and stream windows.
+func (cs *http2Stream) awaitSendWindow(want int) (int, error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	for {
+		if cc.err != nil {
+			return 0, cc.err
+		}
+		if cs.abortErr != nil {
+			return 0, cs.abortErr
+		}
+		if cc.sendWindow > 0 && cs.sendWindow > 0 {
+			take := min(int32(want), cc.sendWindow, cs.sendWindow, int32(cc.peerMaxFrameSize))
+			cc.sendWindow -= take
+			cs.sendWindow -= take
+			return int(take), nil
+		}
+		cc.cond.Wait()
+	}
+}
+
+// resolve delivers the RoundTrip result exactly once.
+func (cs *http2Stream) resolve(resp *http.Response, err error) {
+	cs.resOnce.Do(func() {
+		cs.resc <- http2Result{resp: resp, err: err}
+	})
+}
+
+// abort fails the stream locally without telling the peer.
+func (cs *http2Stream) abort(err error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	if cs.abortErr == nil {
+		cs.abortErr = err
+	}
+	cc.forgetLocked(cs)
+	cc.cond.Broadcast()
+	cc.mu.Unlock()
+	cs.resolve(nil, err)
+	cs.body.closeWithError(err)
+}
+
+// cancel aborts the stream and sends RST_STREAM(CANCEL) if it is still open.
+func (cs *http2Stream) cancel(err error) {
+	cc := cs.cc
+	cc.mu.Lock()
+	_, open := cc.streams[cs.id]
+	cc.mu.Unlock()
+	cs.abort(err)
+	if open {
+		cc.wmu.Lock()
+		cc.fr.WriteRSTStream(cs.id, http2.ErrCodeCancel)
+		cc.bw.Flush()
+		cc.wmu.Unlock()
+	}
+}
+
+// forgetLocked removes cs from the stream table. Must hold cc.mu.
+func (cc *http2Conn) forgetLocked(cs *http2Stream) {
+	if _, ok := cc.streams[cs.id]; ok {
+		delete(cc.streams, cs.id)
+		close(cs.done)
+	}
+	if cc.retired && len(cc.streams) == 0 {
+		cc.conn.Close()
+	}
+}
+
+func (cc *http2Conn) stream(id uint32) *http2Stream {
+	cc.mu.Lock()
+	defer cc.mu.Unlock()
+	return cc.streams[id]
+}
+
+func (cc *http2Conn) closeWithError(err error) {
+	cc.mu.Lock()
+	if cc.err == nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		cc.err = err
+	}
+	streams := make([]*http2Stream, 0, len(cc.streams))
+	for _, cs := range cc.streams {
+		streams = append(streams, cs)
+	}
+	cc.cond.Broadcast()
+	err = cc.err
+	cc.mu.Unlock()
+
+	for _, cs := range streams {
+		cs.abort(err)
+	}
+	cc.conn.Close()
+}
+
+func (cc *http2Conn) readLoop() {
+	for {
+		f, err := cc.fr.ReadFrame()
+		if se, ok := err.(http2.StreamError); ok {
+			if cs := cc.stream(se.StreamID); cs != nil {
+				cs.cancel(se)
+			}
+			continue
+		}
+		if err != nil {
+			cc.closeWithError(err)
+			return
+		}
+
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			if f.IsAck() {
+				continue
+			}
+			cc.applySettings(f)
+			cc.writeFrame(func() error { return cc.fr.WriteSettingsAck() })
+		case *http2.PingFrame:
+			if !f.IsAck() {
+				cc.writeFrame(func() error { return cc.fr.WritePing(true, f.Data) })
+			}
+		case *http2.WindowUpdateFrame:
+			cc.mu.Lock()
+			if f.StreamID == 0 {
+				cc.sendWindow += int32(f.Increment)
+			} else if cs := cc.streams[f.StreamID]; cs != nil {
+				cs.sendWindow += int32(f.Increment)
+			}
+			cc.cond.Broadcast()
+			cc.mu.Unlock()
+		case *http2.GoAwayFrame:
+			cc.mu.Lock()
+			cc.goAway = true
+			failed := []*http2Stream{}
+			for id, cs := range cc.streams {
+				if id > f.LastStreamID {
+					failed = append(failed, cs)
+				}
+			}
+			cc.mu.Unlock()
+			for _, cs := range failed {
+				cs.abort(fmt.Errorf("http2: server sent GOAWAY (%v)", f.ErrCode))
+			}
+		case *http2.RSTStreamFrame:
+			if cs := cc.stream(f.StreamID); cs != nil {
+				cs.abort(fmt.Errorf("http2: stream reset by server (%v)", f.ErrCode))
+			}
+		case *http2.MetaHeadersFrame:
+			cc.handleHeaders(f)
+		case *http2.DataFrame:
+			cc.handleData(f)
+		case *http2.PushPromiseFrame:
+			cc.writeFrame(func() error { return cc.fr.WriteRSTStream(f.PromiseID, http2.ErrCodeRefusedStream) })
+		}
+	}
+}
+
+func (cc *http2Conn) writeFrame(write func() error) {
+	cc.wmu.Lock()
+	err := write()
+	if err == nil {
+		err = cc.bw.Flush()
+	}
+	cc.wmu.Unlock()
+	if err != nil {
+		cc.closeWithError(err)
+	}
+}
+
+// applySettings takes the peer's settings. The HPACK encoder belongs to
+// the writer, and wmu is taken before mu everywhere else, so a new header
+// table size is applied after mu is released.
+func (cc *http2Conn) applySettings(f *http2.SettingsFrame) {
+	var tableSize uint32
+	hasTableSize := false
+	cc.mu.Lock()
+	f.ForeachSetting(func(s http2.Setting) error {
+		switch s.ID {
+		case http2.SettingMaxFrameSize:
+			cc.peerMaxFrameSize = s.Val
+		case http2.SettingMaxConcurrentStreams:
+			cc.peerMaxStreams = s.Val
+		case http2.SettingInitialWindowSize:
+			delta := int32(s.Val) - cc.peerInitialWindow
+			for _, cs := range cc.streams {
+				cs.sendWindow += delta
+			}
+			cc.peerInitialWindow = int32(s.Val)
+			cc.cond.Broadcast()
+		case http2.SettingHeaderTableSize:
+			tableSize, hasTableSize = s.Val, true
+		}
+		return nil
+	})
+	cc.mu.Unlock()
+
+	if hasTableSize {
+		cc.wmu.Lock()
+		cc.henc.SetMaxDynamicTableSize(tableSize)
+		cc.wmu.Unlock()
+	}
+}
+
+func (cc *http2Conn) handleHeaders(f *http2.MetaHeadersFrame) {
+	cs := cc.stream(f.StreamID)
+	if cs == nil {
+		return
+	}
+
+	if cs.resp == nil {
+		timerFrom(cs.req.Context()).firstByte()
+		code, err := strconv.Atoi(f.PseudoValue("status"))
+		if err != nil {
+			cs.cancel(fmt.Errorf("http2: invalid :status %q", f.PseudoValue("status")))
+			return
+		}
+		if code >= 100 && code < 200 {
+			return // interim response (100 Continue, 103 Early Hints)
+		}
+		resp := &http.Response{
+			Status:        fmt.Sprintf("%d %s", code, http.StatusText(code)),
+			StatusCode:    code,
+			Proto:         "HTTP/2.0",
+			ProtoMajor:    2,
+			Header:        make(http.Header),
+			ContentLength: -1,
+			Body:          cs.body,
+			Request:       cs.req,
+			TLS:           cc.tlsState,
+		}
+		names := []string{}
+		for _, hf := range f.RegularFields() {
+			resp.Header.Add(http.CanonicalHeaderKey(hf.Name), hf.Value)
+			names = append(names, hf.Name)
+		}
+		resp.Header[HeaderOrderKey] = names
+		if cl := resp.Header.Get("Content-Length"); cl != "" {
+			if n, err := strconv.ParseInt(cl, 10, 64); err == nil {
+				resp.ContentLength = n
+			}
+		}
+		if f.StreamEnded() {
+			// HEAD, 204, 304 and the like: nothing will follow
+			resp.Body = http.NoBody
+		}
+		cs.resp = resp
+		cs.resolve(resp, nil)
+	} else {
+		// Trailers
+		cs.body.mu.Lock()
+		if cs.resp.Trailer == nil {
+			cs.resp.Trailer = make(http.Header)
+		}
+		for _, hf := range f.RegularFields() {
+			cs.resp.Trailer.Add(http.CanonicalHeaderKey(hf.Name), hf.Value)
+		}
+		cs.body.mu.Unlock()
+	}
+
+	if f.StreamEnded() {
+		cc.endStream(cs)
+	}
+}
+
+func (cc *http2Conn) handleData(f *http2.DataFrame) {
+	data := f.Data()
+	cs := cc.stream(f.StreamID)
+	if cs == nil {
+		// Closed stream: the bytes still count against the connection window
+		cc.returnConnWindow(int32(f.Length))
+		return
+	}
+	if padding := int32(f.Length) - int32(len(data)); padding > 0 {
+		cc.returnConnWindow(padding)
+	}
+	if len(data) > 0 {
+		cs.body.write(data)
+	}
+	if f.StreamEnded() {
+		cc.endStream(cs)
+	}
+}
+
+func (cc *http2Conn) endStream(cs *http2Stream) {
+	cc.mu.Lock()
+	cc.forgetLocked(cs)
+	cc.mu.Unlock()
+	cs.body.closeWithError(io.EOF)
+}
+
+// returnConnWindow credits consumed bytes to the connection receive window,
+// sending WINDOW_UPDATE once half of it is used.
+func (cc *http2Conn) returnConnWindow(n int32) {
+	cc.mu.Lock()
+	cc.recvUnacked += n
+	inc := int32(0)
+	if cc.recvUnacked >= cc.recvWindow/2 {
+		inc, cc.recvUnacked = cc.recvUnacked, 0
+	}
+	cc.mu.Unlock()
+	if inc > 0 {
+		cc.writeFrame(func() error { return cc.fr.WriteWindowUpdate(0, uint32(inc)) })
+	}
+}
+
+// http2Body is a response body fed by the read loop. Flow-control credit is
+// returned as the caller reads, so a slow reader throttles the server.
+type http2Body struct {
+	stream  *http2Stream
+	mu      sync.Mutex
+	cond    *sync.Cond
+	buf     bytes.Buffer
+	err     error // io.EOF once the stream ended
+	unacked int32
+	closed  bool
+}
+
+func (b *http2Body) write(p []byte) {
+	b.mu.Lock()
+	if !b.closed {
+		b.buf.Write(p)
+	}
+	closed := b.closed
+	b.mu.Unlock()
+	b.cond.Broadcast()
+	if closed {
+		b.stream.cc.returnConnWindow(int32(len(p)))
+	}
+}
+
+func (b *http2Body) closeWithError(err error) {
+	b.mu.Lock()
+	if b.err == nil {
+		b.err = err
+	}
+	b.mu.Unlock()
+	b.cond.Broadcast()
+}
+
+func (b *http2Body) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	for b.buf.Len() == 0 && b.err == nil && !b.closed {
+		b.cond.Wait()
+	}
+	if b.closed {
+		b.mu.Unlock()
+		return 0, errors.New("http2: read on closed body")
+	}
+	if b.buf.Len() == 0 {
+		err := b.err
+		b.mu.Unlock()
+		return 0, err
+	}
+	n, _ := b.buf.Read(p)
+	streamOpen := b.err == nil
+	b.unacked += int32(n)
+	inc := int32(0)
+	cc := b.stream.cc
+	if streamOpen && b.unacked >= cc.streamRecvWindow/2 {
+		inc, b.unacked = b.unacked, 0
+	}
+	b.mu.Unlock()
+
+	cc.returnConnWindow(int32(n))
+	if inc > 0 {
+		cc.writeFrame(func() error { return cc.fr.WriteWindowUpdate(b.stream.id, uint32(inc)) })
+	}
+	return n, nil
+}
+
+func (b *http2Body) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	buffered := int32(b.buf.Len())
+	b.buf.Reset()
+	ended := b.err != nil
+	b.mu.Unlock()
+	b.cond.Broadcast()
+
+	if buffered > 0 {
+		b.stream.cc.returnConnWindow(buffered)
+	}
+	if !ended {
+		b.stream.cancel(errors.New("http2: response body closed"))
+	}
+	return nil
+}
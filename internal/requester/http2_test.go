@@ -0,0 +1,202 @@
+package requester
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/fingerprint"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/hpack"
+)
+
+// connPair returns the two ends of a loopback TCP connection.
+func connPair(t *testing.T) (client, server net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, _ := ln.Accept()
+		accepted <- c
+	}()
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	server = <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() { client.Close(); server.Close() })
+	return client, server
+}
+
+// serveHTTP2 runs an x/net/http2 server with prior knowledge on one end of
+// a connection and returns an http2Conn for cfg on the other.
+func serveHTTP2(t *testing.T, cfg *config.Config, h http.HandlerFunc) *http2Conn {
+	t.Helper()
+	client, server := connPair(t)
+	go (&http2.Server{}).ServeConn(server, &http2.ServeConnOpts{Handler: h})
+	cc, err := newHTTP2Conn(client, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { cc.Close() })
+	return cc
+}
+
+// readPreface reads what a client sends up to its first HEADERS frame and
+// returns the Akamai fingerprint of it, the frames named in order and the
+// HEADERS frame itself.
+func readPreface(t *testing.T, conn net.Conn) (akamai string, frames []string, headers *http2.MetaHeadersFrame) {
+	t.Helper()
+	preface := make([]byte, len(http2.ClientPreface))
+	if _, err := io.ReadFull(conn, preface); err != nil || string(preface) != http2.ClientPreface {
+		t.Fatalf("preface %q, %v", preface, err)
+	}
+	fr := http2.NewFramer(io.Discard, conn)
+	fr.ReadMetaHeaders = hpack.NewDecoder(4096, nil)
+	var settings, priorities, pseudo []string
+	window := "00"
+	for {
+		f, err := fr.ReadFrame()
+		if err != nil {
+			t.Fatal(err)
+		}
+		frames = append(frames, f.Header().Type.String())
+		switch f := f.(type) {
+		case *http2.SettingsFrame:
+			f.ForeachSetting(func(s http2.Setting) error {
+				settings = append(settings, fmt.Sprintf("%d:%d", s.ID, s.Val))
+				return nil
+			})
+		case *http2.WindowUpdateFrame:
+			window = fmt.Sprint(f.Increment)
+		case *http2.PriorityFrame:
+			priorities = append(priorities, fmt.Sprintf("%d:%d:%d:%d", f.StreamID, boolInt(f.Exclusive), f.StreamDep, int(f.Weight)+1))
+		case *http2.MetaHeadersFrame:
+			for _, hf := range f.PseudoFields() {
+				pseudo = append(pseudo, hf.Name[1:2])
+			}
+			if len(priorities) == 0 {
+				priorities = []string{"0"}
+			}
+			return strings.Join([]string{
+				strings.Join(settings, ";"),
+				window,
+				strings.Join(priorities, ","),
+				strings.Join(pseudo, ","),
+			}, "|"), frames, f
+		}
+	}
+}
+
+// testHTTP2Config is the default config with HTTP/2 enabled.
+func testHTTP2Config() *config.Config {
+	cfg := config.Default()
+	cfg.Fingerprint.HTTP2 = true
+	return cfg
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+func TestHTTP2Preface(t *testing.T) {
+	window := func(v uint32) *uint32 { return &v }
+	tests := []struct {
+		name   string
+		http2  config.HTTP2Config
+		pseudo []string
+		frames string
+	}{
+		{"defaults", config.HTTP2Config{}, nil, "SETTINGS WINDOW_UPDATE HEADERS"},
+		{"chrome", config.HTTP2Config{
+			Settings:       []config.HTTP2Setting{{ID: 1, Value: 65536}, {ID: 2, Value: 0}, {ID: 4, Value: 6291456}, {ID: 6, Value: 262144}},
+			WindowUpdate:   window(15663105),
+			HeaderPriority: &config.HTTP2PriorityParam{Weight: 256, Exclusive: true},
+		}, []string{":method", ":authority", ":scheme", ":path"}, "SETTINGS WINDOW_UPDATE HEADERS"},
+		{"firefox priority tree", config.HTTP2Config{
+			Settings:     []config.HTTP2Setting{{ID: 1, Value: 65536}, {ID: 4, Value: 131072}, {ID: 5, Value: 16384}},
+			WindowUpdate: window(12517377),
+			Priorities: []config.HTTP2Priority{
+				{StreamID: 3, HTTP2PriorityParam: config.HTTP2PriorityParam{Weight: 201}},
+				{StreamID: 5, HTTP2PriorityParam: config.HTTP2PriorityParam{Weight: 101}},
+				{StreamID: 7, HTTP2PriorityParam: config.HTTP2PriorityParam{Weight: 1}},
+			},
+		}, []string{":method", ":path", ":authority", ":scheme"}, "SETTINGS WINDOW_UPDATE PRIORITY PRIORITY PRIORITY HEADERS"},
+		{"no WINDOW_UPDATE", config.HTTP2Config{
+			Settings:     []config.HTTP2Setting{{ID: 2, Value: 0}, {ID: 4, Value: 65535}},
+			WindowUpdate: window(0),
+		}, nil, "SETTINGS HEADERS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := testHTTP2Config()
+			cfg.HTTP2 = tt.http2
+			cfg.PseudoHeaderOrder = tt.pseudo
+			client, server := connPair(t)
+			cc, err := newHTTP2Conn(client, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer cc.Close()
+			req, _ := http.NewRequest("GET", "https://example.com/", nil)
+			go cc.RoundTrip(req)
+
+			akamai, frames, _ := readPreface(t, server)
+			if got := strings.Join(frames, " "); got != tt.frames {
+				t.Errorf("frames %s, want %s", got, tt.frames)
+			}
+			if want := fingerprint.AkamaiFingerprint(cfg); akamai != want {
+				t.Errorf("sent %s\nAkamaiFingerprint %s", akamai, want)
+			}
+		})
+	}
+}
+
+func TestHTTP2FlowControl(t *testing.T) {
+	window := uint32(0)
+	cfg := testHTTP2Config()
+	// The smallest windows, so both directions need WINDOW_UPDATEs.
+	cfg.HTTP2 = config.HTTP2Config{
+		Settings:     []config.HTTP2Setting{{ID: 4, Value: 65535}},
+		WindowUpdate: &window,
+	}
+	cc := serveHTTP2(t, cfg, func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Write(body)
+		w.Write(body)
+	})
+
+	upload := bytes.Repeat([]byte("0123456789abcdef"), 64<<10) // 1 MiB
+	for i := range 3 {
+		req, _ := http.NewRequest("POST", "https://example.com/echo", bytes.NewReader(upload))
+		resp, err := cc.RoundTrip(req)
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		got, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			t.Fatalf("request %d: %v", i, err)
+		}
+		if want := append(append([]byte{}, upload...), upload...); sha256.Sum256(got) != sha256.Sum256(want) {
+			t.Fatalf("request %d: got %d bytes back, want %d", i, len(got), len(want))
+		}
+	}
+}
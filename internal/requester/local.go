@@ -0,0 +1,142 @@
+package requester
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// localCounters hold the round-robin position of each local_address list,
+// shared by every request in the process.
+var localCounters sync.Map // string -> *atomic.Uint64
+
+// localPick is the local address of each family one request connects
+// from; nil where local_address has none of that family.
+type localPick struct {
+	v4, v6 net.IP
+}
+
+func (p *localPick) String() string {
+	return fmt.Sprintf("%v,%v", p.v4, p.v6)
+}
+
+type localPickKey struct{}
+
+// withLocalPick picks the local addresses for a request from cfg's
+// local_address and local_rotation, unless ctx carries a pick already,
+// so every hop and connection of the request leaves from the same one.
+func withLocalPick(ctx context.Context, cfg *config.Config) (context.Context, error) {
+	if len(cfg.LocalAddress) == 0 || localPickFrom(ctx) != nil {
+		return ctx, nil
+	}
+	pick, err := pickLocal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, localPickKey{}, pick), nil
+}
+
+func localPickFrom(ctx context.Context) *localPick {
+	pick, _ := ctx.Value(localPickKey{}).(*localPick)
+	return pick
+}
+
+// pickLocal takes the next local addresses in rotation. Interface names
+// stand for their addresses.
+func pickLocal(cfg *config.Config) (*localPick, error) {
+	rotation, err := cfg.Rotation()
+	if err != nil {
+		return nil, err
+	}
+	var v4, v6 []net.IP
+	for _, entry := range cfg.LocalAddress {
+		ips, err := localEntryIPs(entry)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			if ip.To4() != nil {
+				v4 = append(v4, ip)
+			} else {
+				v6 = append(v6, ip)
+			}
+		}
+	}
+
+	choose := rand.IntN
+	if rotation == config.RotateRoundRobin {
+		counter, _ := localCounters.LoadOrStore(strings.Join(cfg.LocalAddress, ","), new(atomic.Uint64))
+		next := counter.(*atomic.Uint64).Add(1) - 1
+		choose = func(n int) int { return int(next % uint64(n)) }
+	}
+	pick := &localPick{}
+	if len(v4) > 0 {
+		pick.v4 = v4[choose(len(v4))]
+	}
+	if len(v6) > 0 {
+		pick.v6 = v6[choose(len(v6))]
+	}
+	return pick, nil
+}
+
+// localIP returns the address to dial remote from, or nil to let the
+// system choose. Dials outside a request, such as to DNS servers, pick
+// afresh each time.
+func localIP(ctx context.Context, cfg *config.Config, remote net.IP) (net.IP, error) {
+	if len(cfg.LocalAddress) == 0 {
+		return nil, nil
+	}
+	pick := localPickFrom(ctx)
+	if pick == nil {
+		var err error
+		if pick, err = pickLocal(cfg); err != nil {
+			return nil, err
+		}
+	}
+	local, family := pick.v6, "IPv6"
+	if remote.To4() != nil {
+		local, family = pick.v4, "IPv4"
+	}
+	if local == nil {
+		return nil, fmt.Errorf("no %s address in local_address %s to reach %s from", family, strings.Join(cfg.LocalAddress, ","), remote)
+	}
+	return local, nil
+}
+
+// localEntryIPs returns the IP a local_address entry names or, for an
+// interface name, the interface's addresses other than link-local ones,
+// which would need a zone.
+func localEntryIPs(entry string) ([]net.IP, error) {
+	if ip := net.ParseIP(strings.Trim(entry, "[]")); ip != nil {
+		return []net.IP{ip}, nil
+	}
+	iface, err := net.InterfaceByName(entry)
+	if err != nil {
+		return nil, fmt.Errorf("local_address %q is neither an IP address nor an interface: %v", entry, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("local_address %q: %v", entry, err)
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		if ipNet, ok := addr.(*net.IPNet); ok && !ipNet.IP.IsLinkLocalUnicast() {
+			ips = append(ips, ipNet.IP)
+		}
+	}
+	return ips, nil
+}
+
+// localAddr is the net.Addr for a Dialer's LocalAddr on network.
+func localAddr(network string, ip net.IP) net.Addr {
+	if strings.HasPrefix(network, "udp") {
+		return &net.UDPAddr{IP: ip}
+	}
+	return &net.TCPAddr{IP: ip}
+}
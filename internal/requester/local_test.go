@@ -0,0 +1,193 @@
+package requester
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// loopbackInterface returns the name of the loopback interface.
+func loopbackInterface(t *testing.T) string {
+	t.Helper()
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		t.Skip(err)
+	}
+	for _, iface := range ifaces {
+		if iface.Flags&net.FlagLoopback != 0 && iface.Flags&net.FlagUp != 0 {
+			return iface.Name
+		}
+	}
+	t.Skip("no loopback interface")
+	return ""
+}
+
+func TestLocalEntryIPs(t *testing.T) {
+	lo := loopbackInterface(t)
+	tests := []struct {
+		entry string
+		want  string // "error" for an error
+	}{
+		{"192.0.2.1", "[192.0.2.1]"},
+		{"2001:db8::1", "[2001:db8::1]"},
+		{"[2001:db8::1]", "[2001:db8::1]"},
+		{"no-such-interface0", "error"},
+	}
+	for _, tt := range tests {
+		ips, err := localEntryIPs(tt.entry)
+		got := fmt.Sprint(ips)
+		if err != nil {
+			got = "error"
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %s (%v), want %s", tt.entry, got, err, tt.want)
+		}
+	}
+
+	ips, err := localEntryIPs(lo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !slices.ContainsFunc(ips, func(ip net.IP) bool { return ip.Equal(net.IPv4(127, 0, 0, 1)) }) {
+		t.Errorf("%s: %v lacks 127.0.0.1", lo, ips)
+	}
+	if slices.ContainsFunc(ips, net.IP.IsLinkLocalUnicast) {
+		t.Errorf("%s: %v includes a link-local address", lo, ips)
+	}
+}
+
+func TestPickLocalRoundRobin(t *testing.T) {
+	// Counters are per process and keyed by the list, so the list is unique
+	// to this test.
+	cfg := &config.Config{LocalAddress: config.LocalAddresses{"192.0.2.101", "2001:db8::101", "192.0.2.102", "2001:db8::102", "192.0.2.103"}}
+	var got []string
+	for range 4 {
+		pick, err := pickLocal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, pick.String())
+	}
+	want := []string{
+		"192.0.2.101,2001:db8::101",
+		"192.0.2.102,2001:db8::102",
+		"192.0.2.103,2001:db8::101",
+		"192.0.2.101,2001:db8::102",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("picks %q, want %q", got, want)
+	}
+}
+
+func TestPickLocalRandom(t *testing.T) {
+	cfg := &config.Config{LocalAddress: config.LocalAddresses{"192.0.2.1", "192.0.2.2", "192.0.2.3"}, LocalRotation: config.RotateRandom}
+	seen := map[string]bool{}
+	for range 200 {
+		pick, err := pickLocal(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if pick.v6 != nil {
+			t.Fatalf("IPv6 pick %v from IPv4 addresses", pick.v6)
+		}
+		seen[pick.v4.String()] = true
+	}
+	if len(seen) != 3 {
+		t.Errorf("picked %v, want all three addresses", seen)
+	}
+
+	cfg.LocalRotation = "sticky"
+	if _, err := pickLocal(cfg); err == nil || !strings.Contains(err.Error(), "invalid local_rotation") {
+		t.Errorf("unknown rotation: error %v", err)
+	}
+	cfg.LocalRotation, cfg.LocalAddress = "", config.LocalAddresses{"no-such-interface0"}
+	if _, err := pickLocal(cfg); err == nil {
+		t.Error("unknown interface accepted")
+	}
+}
+
+func TestLocalIP(t *testing.T) {
+	cfg := &config.Config{LocalAddress: config.LocalAddresses{"192.0.2.201", "192.0.2.202"}}
+	v4, v6 := net.ParseIP("198.51.100.1"), net.ParseIP("2001:db8::1")
+
+	if ip, err := localIP(context.Background(), &config.Config{}, v4); ip != nil || err != nil {
+		t.Errorf("without local_address: %v, %v", ip, err)
+	}
+	if _, err := localIP(context.Background(), cfg, v6); err == nil || !strings.Contains(err.Error(), "no IPv6 address in local_address") {
+		t.Errorf("IPv6 remote from IPv4 addresses: error %v", err)
+	}
+
+	// Every connection of a request leaves from the request's pick, while
+	// dials outside a request rotate.
+	ctx, err := withLocalPick(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pick := localPickFrom(ctx)
+	for range 3 {
+		if ip, err := localIP(ctx, cfg, v4); err != nil || !ip.Equal(pick.v4) {
+			t.Errorf("request dial from %v, %v; want %v", ip, err, pick.v4)
+		}
+	}
+	if again, _ := withLocalPick(ctx, cfg); localPickFrom(again) != pick {
+		t.Error("withLocalPick replaced the request's pick")
+	}
+	first, _ := localIP(context.Background(), cfg, v4)
+	second, _ := localIP(context.Background(), cfg, v4)
+	if first.Equal(second) {
+		t.Errorf("dials outside a request both from %v", first)
+	}
+}
+
+func TestDialFromLocalAddress(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Addr, 1)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			accepted <- conn.RemoteAddr()
+			conn.Close()
+		}
+	}()
+
+	// Linux routes all of 127.0.0.0/8 to the loopback interface.
+	cfg := config.Default()
+	cfg.LocalAddress = config.LocalAddresses{"127.0.0.2"}
+	conn, err := DialContext(context.Background(), ln.Addr().String(), cfg)
+	if err != nil {
+		t.Skipf("cannot bind 127.0.0.2: %v", err)
+	}
+	conn.Close()
+	if from := (<-accepted).(*net.TCPAddr); !from.IP.Equal(net.ParseIP("127.0.0.2")) {
+		t.Errorf("connection came from %v, want 127.0.0.2", from)
+	}
+
+	cfg.LocalAddress = config.LocalAddresses{loopbackInterface(t)}
+	conn, err = DialContext(context.Background(), ln.Addr().String(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn.Close()
+	if from := (<-accepted).(*net.TCPAddr); !from.IP.IsLoopback() {
+		t.Errorf("connection from the loopback interface came from %v", from)
+	}
+
+	if addr := localAddr("udp4", net.IPv4(127, 0, 0, 1)); addr.Network() != "udp" {
+		t.Errorf("local address for udp4 is %s", addr.Network())
+	}
+	if addr := localAddr("tcp", net.IPv4(127, 0, 0, 1)); addr.Network() != "tcp" {
+		t.Errorf("local address for tcp is %s", addr.Network())
+	}
+}
@@ -0,0 +1,156 @@
+package requester
+
+import (
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// Output formats MakeRequest writes to stdout.
+const (
+	FormatRaw    = "raw"    // the HTTP response as text
+	FormatNDJSON = "ndjson" // a headers event, data events, then an end event
+)
+
+// headersEvent opens an ndjson response.
+type headersEvent struct {
+	Type       string         `json:"type"`
+	Status     int            `json:"status"`
+	StatusText string         `json:"status_text"`
+	Proto      string         `json:"proto"`
+	ALPN       string         `json:"alpn,omitempty"`
+	TLSVersion string         `json:"tls_version,omitempty"`
+	Cipher     string         `json:"cipher,omitempty"`
+	Headers    config.Headers `json:"headers"`
+	URL        string         `json:"url"`
+	RemoteAddr string         `json:"remote_addr,omitempty"` // server, or proxy, ip:port
+	Proxy      string         `json:"proxy,omitempty"`       // proxy URL without password
+	Redirects  []Redirect     `json:"redirects,omitempty"`
+}
+
+// dataEvent carries a body chunk, as text when it is valid UTF-8.
+type dataEvent struct {
+	Type     string `json:"type"`
+	Encoding string `json:"encoding"` // utf8 or base64
+	Data     string `json:"data"`
+}
+
+// endEvent closes an ndjson response, or is the only event when the
+// request failed before a response arrived.
+type endEvent struct {
+	Type      string         `json:"type"`
+	BodyBytes int64          `json:"body_bytes"`
+	Trailers  config.Headers `json:"trailers,omitempty"`
+	Timings   Timings        `json:"timings"`
+	Error     string         `json:"error,omitempty"`
+	ErrorType string         `json:"error_type,omitempty"`
+}
+
+// writeNDJSON streams resp to w as ndjson events and closes its body. err
+// reports a request that failed before resp (which is then nil). The end
+// event carries timer's phases.
+func writeNDJSON(w io.Writer, resp *http.Response, err error, timer *Timer) error {
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	end := endEvent{Type: "end"}
+	finish := func(err error) error {
+		end.Timings = timer.Finish()
+		if err != nil {
+			end.Error = err.Error()
+			end.ErrorType, _ = ClassifyError(err)
+		}
+		enc.Encode(end)
+		return err
+	}
+	if err != nil {
+		return finish(err)
+	}
+	defer resp.Body.Close()
+
+	head := headersEvent{
+		Type:       "headers",
+		Status:     resp.StatusCode,
+		StatusText: strings.TrimSpace(strings.TrimPrefix(resp.Status, strconv.Itoa(resp.StatusCode))),
+		Proto:      resp.Proto,
+		Headers:    ResponseHeaders(resp),
+		URL:        resp.Request.URL.String(),
+		RemoteAddr: timer.RemoteAddr(),
+		Proxy:      timer.Proxy(),
+		Redirects:  RedirectChain(resp),
+	}
+	if resp.TLS != nil {
+		head.ALPN = resp.TLS.NegotiatedProtocol
+		head.TLSVersion = tls.VersionName(resp.TLS.Version)
+		head.Cipher = tls.CipherSuiteName(resp.TLS.CipherSuite)
+	}
+	enc.Encode(head)
+
+	buf := make([]byte, 16384)
+	var pending []byte // an incomplete UTF-8 sequence held for the next chunk
+	for {
+		n, err := resp.Body.Read(buf)
+		if n > 0 {
+			end.BodyBytes += int64(n)
+			pending = writeData(enc, append(pending, buf[:n]...), false)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeData(enc, pending, true)
+			return finish(err)
+		}
+	}
+	writeData(enc, pending, true)
+
+	if len(resp.Trailer) > 0 {
+		names := make([]string, 0, len(resp.Trailer))
+		for k := range resp.Trailer {
+			names = append(names, k)
+		}
+		sort.Strings(names)
+		for _, k := range names {
+			for _, v := range resp.Trailer[k] {
+				end.Trailers = append(end.Trailers, config.Header{Name: k, Value: v})
+			}
+		}
+	}
+	return finish(nil)
+}
+
+// writeData emits chunk as one data event. Unless final, a multi-byte
+// character split at the end of chunk is returned for the next call so
+// text is not needlessly sent as base64.
+func writeData(enc *json.Encoder, chunk []byte, final bool) []byte {
+	if len(chunk) == 0 {
+		return nil
+	}
+	var rest []byte
+	if !final {
+		// Back up to the start of the last character; hold it if partial
+		i := len(chunk) - 1
+		for i > 0 && len(chunk)-i < utf8.UTFMax && !utf8.RuneStart(chunk[i]) {
+			i--
+		}
+		if !utf8.FullRune(chunk[i:]) {
+			chunk, rest = chunk[:i], append([]byte(nil), chunk[i:]...)
+		}
+	}
+	if len(chunk) == 0 {
+		return rest
+	}
+	if utf8.Valid(chunk) {
+		enc.Encode(dataEvent{Type: "data", Encoding: "utf8", Data: string(chunk)})
+	} else {
+		enc.Encode(dataEvent{Type: "data", Encoding: "base64", Data: base64.StdEncoding.EncodeToString(chunk)})
+	}
+	return rest
+}
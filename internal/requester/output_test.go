@@ -0,0 +1,137 @@
+package requester
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"flag"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// chunkReader returns its chunks one Read at a time, then err.
+type chunkReader struct {
+	chunks []string
+	err    error
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	if len(r.chunks) == 0 {
+		return 0, r.err
+	}
+	n := copy(p, r.chunks[0])
+	r.chunks = r.chunks[1:]
+	return n, nil
+}
+
+func (r *chunkReader) Close() error { return nil }
+
+// finishedTimer is a Timer whose phases are fixed, so output is stable.
+func finishedTimer(t Timings, remote, proxy string) *Timer {
+	return &Timer{t: t, done: true, remote: remote, proxy: proxy}
+}
+
+func mustURL(s string) *url.URL {
+	u, err := url.Parse(s)
+	if err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func TestWriteNDJSON(t *testing.T) {
+	// A 301 from http:// to https:// before the final response.
+	first := &http.Request{Method: "GET", URL: mustURL("http://example.com/start")}
+	redirect := &http.Response{StatusCode: 301, Request: first}
+	final := &http.Request{Method: "GET", URL: mustURL("https://example.com/"), Response: redirect}
+
+	tests := []struct {
+		name  string
+		resp  *http.Response
+		err   error
+		timer *Timer
+	}{
+		{
+			name: "full",
+			resp: &http.Response{
+				StatusCode: 200,
+				Status:     "200 OK",
+				Proto:      "HTTP/2.0",
+				Header: http.Header{
+					"Content-Type": {"text/plain; charset=utf-8"},
+					"Set-Cookie":   {"a=1", "b=2"},
+					HeaderOrderKey: {"set-cookie", "content-type", "set-cookie"},
+				},
+				Trailer: http.Header{"Grpc-Status": {"0"}, "Checksum": {"abc"}},
+				// "é" split across two reads stays text
+				Body:    &chunkReader{chunks: []string{"caf\xc3", "\xa9 <ok> & \"done\"\n"}, err: io.EOF},
+				Request: final,
+				TLS: &tls.ConnectionState{
+					Version:            tls.VersionTLS13,
+					CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+					NegotiatedProtocol: "h2",
+				},
+			},
+			timer: finishedTimer(Timings{
+				DNS: 1.5, Connect: 10, Proxy: 12.25, TLS: 30, WroteRequest: 31,
+				FirstByte: 80, Redirect: 40, Total: 95.5, Reused: true, ProxyQuarantined: true,
+			}, "203.0.113.7:8080", "http://user@proxy.example:8080"),
+		},
+		{
+			name: "minimal",
+			resp: &http.Response{
+				StatusCode: 404,
+				Status:     "404 Not Found",
+				Proto:      "HTTP/1.1",
+				Header:     http.Header{},
+				Body:       &chunkReader{chunks: []string{"\x00\x01\xff binary"}, err: io.EOF},
+				Request:    &http.Request{Method: "GET", URL: mustURL("http://example.com/missing")},
+			},
+			timer: finishedTimer(Timings{Total: 2}, "", ""),
+		},
+		{
+			name:  "request-error",
+			err:   errors.New("dial tcp 192.0.2.1:443: i/o timeout"),
+			timer: finishedTimer(Timings{DNS: 3, Total: 30000}, "", ""),
+		},
+		{
+			name: "body-error",
+			resp: &http.Response{
+				StatusCode: 200,
+				Status:     "200 OK",
+				Proto:      "HTTP/1.1",
+				Header:     http.Header{"Content-Length": {"100"}},
+				Body:       &chunkReader{chunks: []string{"partial \xe2\x82"}, err: io.ErrUnexpectedEOF},
+				Request:    &http.Request{Method: "GET", URL: mustURL("https://example.com/cut")},
+			},
+			timer: finishedTimer(Timings{FirstByte: 5, Total: 6}, "192.0.2.1:443", ""),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			writeNDJSON(&out, tt.resp, tt.err, tt.timer)
+
+			golden := filepath.Join("testdata", "ndjson", tt.name+".golden")
+			if *update {
+				os.MkdirAll(filepath.Dir(golden), 0755)
+				if err := os.WriteFile(golden, out.Bytes(), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("%v (run with -update to create it)", err)
+			}
+			if !bytes.Equal(out.Bytes(), want) {
+				t.Errorf("output differs from %s:\n%s\nwant:\n%s", golden, out.Bytes(), want)
+			}
+		})
+	}
+}
@@ -0,0 +1,464 @@
+package requester
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/cookies"
+)
+
+// maxIdlePerKey caps the idle HTTP/1.1 connections kept per pool key,
+// matching the six connections per host browsers open.
+const maxIdlePerKey = 6
+
+// Pool keeps fingerprinted connections open between requests so a
+// long-running process handshakes once per origin, the way a browser does.
+// HTTP/2 sessions are shared by concurrent requests; HTTP/1.1 connections
+// are reused once their response body has been read to the end.
+//
+// Connections are keyed by origin, proxy and fingerprint (config file and
+// profile), so requests with different fingerprints never share one.
+type Pool struct {
+	mu      sync.Mutex
+	h2      map[string]*http2Conn
+	idle    map[string][]*persistConn
+	dialing map[string]*dialLock
+	closed  bool
+}
+
+// dialLock lets one request at a time dial a key that may negotiate
+// HTTP/2; refs counts the requests holding or waiting for it.
+type dialLock struct {
+	mu   sync.Mutex
+	refs int
+}
+
+func NewPool() *Pool {
+	return &Pool{
+		h2:      map[string]*http2Conn{},
+		idle:    map[string][]*persistConn{},
+		dialing: map[string]*dialLock{},
+	}
+}
+
+// Do sends req, reusing a pooled connection when one fits, and follows
+// redirects and decodes the body if req asks to. With req.CookieJar, every hop sends and stores
+// cookies and the jar file is saved once the response headers arrive. The
+// response body must be read to EOF or closed. ctx cancels the request,
+// including reading the body.
+func (p *Pool) Do(ctx context.Context, req *config.Request, cfg *config.Config) (*http.Response, error) {
+	httpReq, err := newHTTPRequest(req)
+	if err != nil {
+		return nil, err
+	}
+	return p.do(ctx, req, httpReq, cfg)
+}
+
+// DoStream is Do with body sent in place of req.Body, copied upstream as
+// it is read instead of held in memory. contentLength is the length of
+// body, or -1 if unknown, in which case HTTP/1.1 sends it chunked. The
+// body cannot be sent twice, so the request is not retried and redirects
+// that would resend it fail.
+func (p *Pool) DoStream(ctx context.Context, req *config.Request, body io.ReadCloser, contentLength int64, cfg *config.Config) (*http.Response, error) {
+	httpReq, err := newHTTPRequest(req)
+	if err != nil {
+		body.Close()
+		return nil, err
+	}
+	httpReq.Body, httpReq.GetBody, httpReq.ContentLength = body, nil, contentLength
+	if contentLength == 0 {
+		body.Close()
+		httpReq.Body = http.NoBody
+	}
+	return p.do(ctx, req, httpReq, cfg)
+}
+
+func (p *Pool) do(ctx context.Context, req *config.Request, httpReq *http.Request, cfg *config.Config) (*http.Response, error) {
+	var err error
+	var jar *cookies.Jar
+	if req.CookieJar != "" {
+		if jar, err = cookies.Open(req.CookieJar); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every hop leaves from the same local address
+	if ctx, err = withLocalPick(ctx, cfg); err != nil {
+		return nil, err
+	}
+	fingerprintKey := req.ConfigPath + "|" + req.Profile
+	timer := timerFrom(ctx)
+	hops := 0
+	send := func(r *http.Request) (*http.Response, error) {
+		if hops++; hops > 1 {
+			timer.redirected()
+		}
+		if jar != nil {
+			r = withCookies(r, jar.Cookies(r.URL))
+		}
+		resp, err := p.RoundTrip(r, cfg, fingerprintKey, req.Verbose)
+		if err == nil && jar != nil {
+			jar.SetCookies(r.URL, resp.Cookies())
+		}
+		return resp, err
+	}
+
+	var resp *http.Response
+	if req.FollowRedirects {
+		max := req.MaxRedirects
+		if max <= 0 {
+			max = DefaultMaxRedirects
+		}
+		resp, err = followRedirects(httpReq.WithContext(ctx), max, send)
+	} else {
+		resp, err = send(httpReq.WithContext(ctx))
+	}
+
+	if err == nil && req.Decompress {
+		DecompressBody(resp)
+	}
+	if jar != nil {
+		// Save even on failure: cookies from redirects already followed
+		// are part of the session.
+		if saveErr := jar.Save(); saveErr != nil && err == nil {
+			resp.Body.Close()
+			return nil, fmt.Errorf("failed to save cookie jar: %v", saveErr)
+		}
+	}
+	return resp, err
+}
+
+// RoundTrip sends httpReq with cfg's fingerprint. Header order follows
+// HeaderOrderKey and cfg.HeaderOrder. fingerprintKey names the fingerprint
+// (e.g. config file and profile) so connections are only shared between
+// requests that would have made the same handshake.
+func (p *Pool) RoundTrip(httpReq *http.Request, cfg *config.Config, fingerprintKey string, verbose bool) (*http.Response, error) {
+	resp, err := p.roundTrip(httpReq, cfg, fingerprintKey, verbose)
+	if err != nil && httpReq.Body != nil {
+		httpReq.Body.Close()
+	}
+	return resp, err
+}
+
+func (p *Pool) roundTrip(httpReq *http.Request, cfg *config.Config, fingerprintKey string, verbose bool) (*http.Response, error) {
+	ctx, err := withLocalPick(httpReq.Context(), cfg)
+	if err == nil {
+		// Each hop picks its own proxy from a pool
+		ctx, err = withProxyPick(ctx, cfg, httpReq.URL.Hostname())
+	}
+	if err != nil {
+		return nil, err
+	}
+	if ctx != httpReq.Context() {
+		httpReq = httpReq.WithContext(ctx)
+	}
+	key := poolKey(ctx, httpReq.URL, cfg, fingerprintKey)
+	timer := timerFrom(ctx)
+	if cfg.Proxy.Enabled {
+		timer.setProxy(usedProxy(ctx, cfg), proxyPickFrom(ctx).allQuarantined())
+	}
+
+	if cc := p.getHTTP2(key); cc != nil {
+		timer.setReused(true)
+		timer.setRemote(cc.conn)
+		return cc.RoundTrip(httpReq)
+	}
+	if pc := p.getIdle(key); pc != nil {
+		timer.setReused(true)
+		timer.setRemote(pc.conn)
+		pc.cfg = cfg
+		resp, err := pc.roundTrip(httpReq)
+		if err == nil || ctx.Err() != nil || !canRetry(httpReq, err) {
+			return resp, err
+		}
+		// The server closed the idle connection; retry once on a fresh one
+		// with a fresh body.
+		httpReq = httpReq.Clone(ctx)
+		if httpReq.GetBody != nil {
+			if httpReq.Body, err = httpReq.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	// When HTTP/2 may be negotiated, dial one connection per key at a time
+	// so concurrent requests end up sharing the session. The lock only
+	// covers the dial: once the session is pooled, or the server picked
+	// HTTP/1.1, the others go ahead.
+	unlock := func() {}
+	if cfg.Fingerprint.HTTP2 && httpReq.URL.Scheme == "https" {
+		unlock = p.lockDial(key)
+		if cc := p.getHTTP2(key); cc != nil {
+			unlock()
+			timer.setReused(true)
+			timer.setRemote(cc.conn)
+			return cc.RoundTrip(httpReq)
+		}
+	}
+
+	timer.setReused(false)
+	conn, negotiatedProtocol, err := dialOrigin(ctx, httpReq.URL, cfg, verbose)
+	if err != nil {
+		unlock()
+		return nil, err
+	}
+	timer.setRemote(conn)
+
+	if cfg.Fingerprint.HTTP2 && negotiatedProtocol == "h2" {
+		cc, err := newHTTP2Conn(conn, cfg)
+		if err != nil {
+			unlock()
+			conn.Close()
+			return nil, err
+		}
+		pooled := p.putHTTP2(key, cc)
+		unlock()
+		if !pooled {
+			cc.Close()
+			return nil, errPoolClosed
+		}
+		return cc.RoundTrip(httpReq)
+	}
+	unlock()
+
+	rec := &headRecorder{r: conn}
+	pc := &persistConn{pool: p, key: key, conn: conn, rec: rec, br: bufio.NewReader(rec), cfg: cfg}
+	return pc.roundTrip(httpReq)
+}
+
+// canRetry reports whether a request that failed on a reused connection
+// may be sent again on a new one, by the rules net/http follows: the
+// connection must have been closed or reset before any response byte, and
+// unless none of the request was written, the request must be idempotent.
+func canRetry(req *http.Request, err error) bool {
+	var nr *noResponseError
+	if !errors.As(err, &nr) {
+		return false
+	}
+	if !errors.Is(nr.err, io.EOF) && !errors.Is(nr.err, io.ErrUnexpectedEOF) &&
+		!errors.Is(nr.err, syscall.ECONNRESET) && !errors.Is(nr.err, syscall.EPIPE) {
+		return false
+	}
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	return !nr.wrote || isIdempotent(req)
+}
+
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	_, hasKey := req.Header["Idempotency-Key"]
+	_, hasXKey := req.Header["X-Idempotency-Key"]
+	return hasKey || hasXKey
+}
+
+// CloseIdleConnections closes idle HTTP/1.1 connections and HTTP/2
+// sessions once their streams finish.
+func (p *Pool) CloseIdleConnections() {
+	p.mu.Lock()
+	h2, idle := p.h2, p.idle
+	p.h2, p.idle = map[string]*http2Conn{}, map[string][]*persistConn{}
+	p.mu.Unlock()
+
+	for _, cc := range h2 {
+		cc.retire()
+	}
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+}
+
+// Close closes every pooled connection. Responses in flight fail.
+func (p *Pool) Close() {
+	p.mu.Lock()
+	p.closed = true
+	h2, idle := p.h2, p.idle
+	p.h2, p.idle = map[string]*http2Conn{}, map[string][]*persistConn{}
+	p.mu.Unlock()
+
+	for _, cc := range h2 {
+		cc.Close()
+	}
+	for _, conns := range idle {
+		for _, pc := range conns {
+			pc.conn.Close()
+		}
+	}
+}
+
+var errPoolClosed = errors.New("connection pool is closed")
+
+// poolKey also takes in the local address and pool proxy picked for ctx.
+func poolKey(ctx context.Context, u *url.URL, cfg *config.Config, fingerprintKey string) string {
+	proxy := ""
+	if pick := proxyPickFrom(ctx); pick != nil {
+		proxy = fmt.Sprintf("%+v", pick.cfg)
+	} else if cfg.Proxy.Enabled {
+		// Every field counts: proxies that rotate exits per username must
+		// not share tunnels, for one.
+		proxy = fmt.Sprintf("%+v", cfg.Proxy)
+	}
+	// Connections verified under one TLS policy, or authenticated with one
+	// client certificate, must not serve another.
+	verify := fmt.Sprintf("%t,%s,%v,%s,%s", cfg.TLS.Insecure, cfg.TLS.CAFile, cfg.TLS.Pins, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+	// Nor may a connection made from one local address serve a request
+	// that picked another.
+	from := ""
+	if local := localPickFrom(ctx); local != nil {
+		from = local.String()
+	}
+	return strings.Join([]string{u.Scheme, originAddr(u), overrideKey(u, cfg), proxy, fingerprintKey, verify, from}, "|")
+}
+
+// lockDial takes the dial lock for key and returns its unlock, which
+// drops the lock from p.dialing once no other request wants it.
+func (p *Pool) lockDial(key string) (unlock func()) {
+	p.mu.Lock()
+	l, ok := p.dialing[key]
+	if !ok {
+		l = &dialLock{}
+		p.dialing[key] = l
+	}
+	l.refs++
+	p.mu.Unlock()
+
+	l.mu.Lock()
+	return func() {
+		l.mu.Unlock()
+		p.mu.Lock()
+		if l.refs--; l.refs == 0 {
+			delete(p.dialing, key)
+		}
+		p.mu.Unlock()
+	}
+}
+
+func (p *Pool) getHTTP2(key string) *http2Conn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	cc, ok := p.h2[key]
+	if !ok {
+		return nil
+	}
+	if !cc.CanTakeNewRequest() {
+		delete(p.h2, key)
+		cc.retire()
+		return nil
+	}
+	return cc
+}
+
+func (p *Pool) putHTTP2(key string, cc *http2Conn) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return false
+	}
+	p.h2[key] = cc
+	return true
+}
+
+func (p *Pool) getIdle(key string) *persistConn {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	conns := p.idle[key]
+	if len(conns) == 0 {
+		return nil
+	}
+	pc := conns[len(conns)-1]
+	p.idle[key] = conns[:len(conns)-1]
+	return pc
+}
+
+func (p *Pool) putIdle(pc *persistConn) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed || len(p.idle[pc.key]) >= maxIdlePerKey {
+		pc.conn.Close()
+		return
+	}
+	p.idle[pc.key] = append(p.idle[pc.key], pc)
+}
+
+// persistConn is a reusable HTTP/1.1 connection.
+type persistConn struct {
+	pool *Pool
+	key  string
+	conn net.Conn
+	rec  *headRecorder
+	br   *bufio.Reader
+	cfg  *config.Config
+}
+
+func (pc *persistConn) roundTrip(httpReq *http.Request) (*http.Response, error) {
+	// Closing the connection is the only way to interrupt HTTP/1.1
+	stop := context.AfterFunc(httpReq.Context(), func() { pc.conn.Close() })
+	resp, err := roundTripHTTP1(pc.conn, pc.br, pc.rec, httpReq, pc.cfg)
+	if err != nil {
+		stop()
+		pc.conn.Close()
+		if ctxErr := httpReq.Context().Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		return nil, err
+	}
+	resp.Body = &persistBody{pc: pc, body: resp.Body, reusable: !resp.Close, stop: stop}
+	return resp, nil
+}
+
+// persistBody hands the connection back to the pool once the body has been
+// read to EOF, and closes it if the body is abandoned early.
+type persistBody struct {
+	pc       *persistConn
+	body     io.ReadCloser
+	reusable bool
+	stop     func() bool // detaches the context watcher
+	done     bool
+}
+
+func (b *persistBody) Read(p []byte) (int, error) {
+	if b.done {
+		return 0, io.EOF
+	}
+	n, err := b.body.Read(p)
+	if err == io.EOF {
+		b.release(b.reusable)
+	} else if err != nil {
+		b.release(false)
+	}
+	return n, err
+}
+
+func (b *persistBody) Close() error {
+	b.release(false)
+	return nil
+}
+
+func (b *persistBody) release(reuse bool) {
+	if b.done {
+		return
+	}
+	b.done = true
+	b.body.Close()
+	// A false stop means the context fired and the connection is gone
+	if b.stop() && reuse {
+		b.pc.pool.putIdle(b.pc)
+	} else {
+		b.pc.conn.Close()
+	}
+}
@@ -0,0 +1,261 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/profiles"
+)
+
+// tlsServer starts an httptest TLS server, offering h2 if http2 is set,
+// and returns it with a count of the connections clients made to it.
+func tlsServer(t *testing.T, http2 bool, h http.HandlerFunc) (*httptest.Server, *atomic.Int32) {
+	t.Helper()
+	dials := &atomic.Int32{}
+	srv := httptest.NewUnstartedServer(h)
+	srv.EnableHTTP2 = http2
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			dials.Add(1)
+		}
+	}
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+	return srv, dials
+}
+
+// chromeConfig is the chrome profile with certificate checks off, for
+// httptest servers.
+func chromeConfig(t *testing.T) *config.Config {
+	t.Helper()
+	p, err := profiles.Get("chrome_latest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	p.Apply(cfg)
+	cfg.TLS.Insecure = true
+	return cfg
+}
+
+// get sends a request through p and returns the body read to the end.
+func get(t *testing.T, p *Pool, cfg *config.Config, method, url, body string) (*http.Response, string) {
+	t.Helper()
+	resp, err := p.Do(context.Background(), &config.Request{Method: method, URL: url, Body: body}, cfg)
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, url, err)
+	}
+	data, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, string(data)
+}
+
+func TestPoolSharesHTTP2(t *testing.T) {
+	srv, dials := tlsServer(t, true, func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+	p := NewPool()
+	defer p.Close()
+	cfg := chromeConfig(t)
+
+	// Concurrent requests wait on the dial lock for the first session.
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, proto := get(t, p, cfg, "GET", srv.URL, ""); proto != "HTTP/2.0" {
+				t.Errorf("request went over %s", proto)
+			}
+		}()
+	}
+	wg.Wait()
+	get(t, p, cfg, "GET", srv.URL+"/again", "")
+	if n := dials.Load(); n != 1 {
+		t.Errorf("%d connections for 9 HTTP/2 requests, want 1", n)
+	}
+
+	// A different fingerprint must not share the session.
+	if _, err := p.Do(context.Background(), &config.Request{Method: "GET", URL: srv.URL, Profile: "other"}, cfg); err != nil {
+		t.Fatal(err)
+	}
+	if n := dials.Load(); n != 2 {
+		t.Errorf("%d connections after a request with another profile, want 2", n)
+	}
+}
+
+func TestPoolReusesHTTP1(t *testing.T) {
+	release := make(chan struct{})
+	var started sync.WaitGroup
+	srv, dials := tlsServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/wait" {
+			started.Done()
+			<-release
+		}
+		io.WriteString(w, r.Proto)
+	})
+	p := NewPool()
+	defer p.Close()
+	cfg := chromeConfig(t)
+
+	for range 3 {
+		if _, proto := get(t, p, cfg, "GET", srv.URL, ""); proto != "HTTP/1.1" {
+			t.Fatalf("request went over %s", proto)
+		}
+	}
+	if n := dials.Load(); n != 1 {
+		t.Errorf("%d connections for 3 sequential HTTP/1.1 requests, want 1", n)
+	}
+
+	// A body closed before EOF takes its connection with it.
+	resp, err := p.Do(context.Background(), &config.Request{Method: "GET", URL: srv.URL}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	get(t, p, cfg, "GET", srv.URL, "")
+	if n := dials.Load(); n != 2 {
+		t.Errorf("%d connections after abandoning a body, want 2", n)
+	}
+
+	// Only the dial is serialized: concurrent HTTP/1.1 requests each get a
+	// connection of their own.
+	before := dials.Load()
+	var wg sync.WaitGroup
+	for range 3 {
+		started.Add(1)
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			get(t, p, cfg, "GET", srv.URL+"/wait", "")
+		}()
+	}
+	started.Wait()
+	close(release)
+	wg.Wait()
+	if n := dials.Load() - before; n < 2 {
+		t.Errorf("3 concurrent HTTP/1.1 requests used %d new connections", n)
+	}
+}
+
+func TestPoolRetriesStaleConnection(t *testing.T) {
+	tests := []struct {
+		name    string
+		req     config.Request
+		stream  bool // send the body with DoStream, which cannot replay it
+		retried bool
+	}{
+		{"GET", config.Request{Method: "GET"}, false, true},
+		{"PUT with body", config.Request{Method: "PUT", Body: "x"}, false, true},
+		{"POST", config.Request{Method: "POST", Body: "x"}, false, false},
+		{"POST with Idempotency-Key", config.Request{Method: "POST", Body: "x", Headers: config.Headers{{Name: "Idempotency-Key", Value: "1"}}}, false, true},
+		{"streamed PUT", config.Request{Method: "PUT"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests atomic.Int32
+			srv, dials := tlsServer(t, false, func(w http.ResponseWriter, r *http.Request) {
+				requests.Add(1)
+				io.Copy(io.Discard, r.Body)
+			})
+			p := NewPool()
+			defer p.Close()
+			cfg := chromeConfig(t)
+
+			get(t, p, cfg, "GET", srv.URL, "")
+			// The server drops the idle connection the pool still holds.
+			srv.CloseClientConnections()
+
+			req := tt.req
+			req.URL = srv.URL
+			var resp *http.Response
+			var err error
+			if tt.stream {
+				resp, err = p.DoStream(context.Background(), &req, io.NopCloser(strings.NewReader("xyz")), 3, cfg)
+			} else {
+				resp, err = p.Do(context.Background(), &req, cfg)
+			}
+			if err == nil {
+				resp.Body.Close()
+			}
+			if tt.retried != (err == nil) {
+				t.Errorf("error %v, want retried = %v", err, tt.retried)
+			}
+			wantDials := int32(1)
+			if tt.retried {
+				wantDials = 2
+			}
+			if n := dials.Load(); n != wantDials {
+				t.Errorf("%d connections, want %d", n, wantDials)
+			}
+			if n := requests.Load(); n != wantDials {
+				t.Errorf("server saw %d requests, want %d", n, wantDials)
+			}
+		})
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	get, _ := http.NewRequest("GET", "https://example.com/", nil)
+	post, _ := http.NewRequest("POST", "https://example.com/", strings.NewReader("x"))
+	streamed, _ := http.NewRequest("PUT", "https://example.com/", strings.NewReader("x"))
+	streamed.GetBody = nil
+	tests := []struct {
+		name string
+		req  *http.Request
+		err  error
+		want bool
+	}{
+		{"GET after EOF", get, &noResponseError{io.EOF, true}, true},
+		{"GET after reset", get, &noResponseError{syscall.ECONNRESET, true}, true},
+		{"GET after broken pipe", get, &noResponseError{syscall.EPIPE, false}, true},
+		{"GET after timeout", get, &noResponseError{context.DeadlineExceeded, true}, false},
+		{"error after a response byte", get, io.ErrUnexpectedEOF, false},
+		{"POST before writing", post, &noResponseError{io.EOF, false}, true},
+		{"POST after writing", post, &noResponseError{io.EOF, true}, false},
+		{"body that cannot be replayed", streamed, &noResponseError{io.EOF, false}, false},
+		{"wrapped", get, errors.Join(errors.New("read"), &noResponseError{io.ErrUnexpectedEOF, true}), true},
+	}
+	for _, tt := range tests {
+		if got := canRetry(tt.req, tt.err); got != tt.want {
+			t.Errorf("%s: canRetry = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLockDial(t *testing.T) {
+	p := NewPool()
+	unlock := p.lockDial("a")
+	otherUnlock := p.lockDial("b") // other keys do not wait
+
+	acquired := make(chan func())
+	go func() { acquired <- p.lockDial("a") }()
+	select {
+	case <-acquired:
+		t.Fatal("second lockDial on the same key did not wait")
+	case <-time.After(50 * time.Millisecond):
+	}
+	unlock()
+	(<-acquired)()
+	otherUnlock()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.dialing) != 0 {
+		t.Errorf("%d dial locks left after unlocking", len(p.dialing))
+	}
+}
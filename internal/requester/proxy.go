@@ -3,10 +3,15 @@ package requester
 import (
 	"bufio"
 	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 	"time"
 
 	"fingerPrintRequester/internal/config"
@@ -15,80 +20,588 @@ import (
 )
 
 func DialWithProxy(addr string, cfg *config.Config) (net.Conn, error) {
-	baseDialer := &net.Dialer{
-		Timeout: time.Duration(cfg.Timeout.Connect) * time.Second,
-	}
-
-	// Custom DNS resolver with fallback to system DNS
-	if len(cfg.DNS.Servers) > 0 {
-		baseDialer.Resolver = &net.Resolver{
-			PreferGo: true,
-			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
-				for _, server := range cfg.DNS.Servers {
-					d := net.Dialer{
-						Timeout: time.Duration(cfg.Timeout.Connect) * time.Second,
-					}
-					conn, err := d.DialContext(ctx, "udp", server)
-					if err == nil {
-						return conn, nil
-					}
-				}
-				// Fallback to system DNS
-				d := net.Dialer{
-					Timeout: time.Duration(cfg.Timeout.Connect) * time.Second,
-				}
-				return d.DialContext(ctx, network, address)
-			},
-		}
-	}
+	return DialContext(context.Background(), addr, cfg)
+}
 
-	var dialer proxy.Dialer = baseDialer
+// DialContext is DialWithProxy with ctx bounding the dial. A Timer in ctx
+// (see WithTimer) records the DNS, connect and proxy tunnel phases. With a
+// proxy pool, the proxy picked for ctx's request is used, or one is picked
+// for addr, and its failures count towards quarantining it.
+func DialContext(ctx context.Context, addr string, cfg *config.Config) (net.Conn, error) {
+	timer := timerFrom(ctx)
+	res, err := newResolver(cfg)
+	if err != nil {
+		return nil, err
+	}
+	baseDialer := hostDialer{
+		d:   &net.Dialer{Timeout: time.Duration(cfg.Timeout.Connect) * time.Second},
+		res: res,
+		cfg: cfg,
+	}
 
-	if cfg.Proxy.Enabled {
-		proxyURL, err := url.Parse(cfg.Proxy.URL)
+	if !cfg.Proxy.Enabled {
+		conn, err := baseDialer.DialContext(ctx, "tcp", addr)
 		if err != nil {
 			return nil, err
 		}
-		if cfg.Proxy.Type == "socks5" {
-			dialer, err = proxy.SOCKS5("tcp", proxyURL.Host, nil, dialer.(*net.Dialer))
-			if err != nil {
+		timer.connected()
+		return conn, nil
+	}
+
+	pc := &cfg.Proxy
+	var pick *proxyPick
+	if pc.IsPool() {
+		if pick = proxyPickFrom(ctx); pick == nil {
+			host, _, _ := net.SplitHostPort(addr)
+			if pick, err = pickProxy(pc, host); err != nil {
 				return nil, err
 			}
 		}
+		pc = &pick.cfg
+	}
+	proxyType, proxyURL, err := pc.Endpoint()
+	if err != nil {
+		return nil, &ConfigError{err}
+	}
+	// socks4 and socks5 resolve the target here; the other types leave it
+	// to the proxy, so no DNS query leaves this machine, unless resolve or
+	// hosts fix its address.
+	switch proxyType {
+	case "socks4":
+		addr, err = baseDialer.resolveAddr(ctx, "ip4", addr)
+	case "socks5":
+		addr, err = baseDialer.resolveAddr(ctx, "ip", addr)
+	default:
+		addr, err = baseDialer.staticAddr(addr)
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	var conn net.Conn
-	var err error
+	conn, err := tunnel(ctx, baseDialer, pc, proxyType, proxyURL, addr)
+	pick.report(err)
+	return conn, err
+}
 
-	if cfg.Proxy.Enabled && cfg.Proxy.Type == "http" {
-		proxyURL, _ := url.Parse(cfg.Proxy.URL)
-		conn, err = dialer.Dial("tcp", proxyURL.Host)
+// tunnel connects to the proxy at proxyURL and has it open a tunnel to
+// addr.
+func tunnel(ctx context.Context, baseDialer hostDialer, pc *config.ProxyConfig, proxyType string, proxyURL *url.URL, addr string) (net.Conn, error) {
+	timer := timerFrom(ctx)
+	if proxyType == "socks5" || proxyType == "socks5h" {
+		var auth *proxy.Auth
+		if user, pass, ok := proxyCredentials(pc, proxyURL); ok {
+			auth = &proxy.Auth{User: user, Password: pass}
+		}
+		dialer, err := proxy.SOCKS5("tcp", proxyURL.Host, auth, timedDialer{baseDialer, timer})
 		if err != nil {
 			return nil, err
 		}
-		connectReq := &http.Request{
-			Method: "CONNECT",
-			URL:    &url.URL{Host: addr},
-			Host:   addr,
-			Header: make(http.Header),
-		}
-		connectReq.Write(conn)
-		br := bufio.NewReader(conn)
-		resp, err := http.ReadResponse(br, connectReq)
+		conn, err := dialer.(proxy.ContextDialer).DialContext(ctx, "tcp", addr)
 		if err != nil {
-			conn.Close()
+			if isSOCKSAuthError(err) {
+				return nil, &ProxyAuthError{Proxy: proxyURL.Host, Err: err}
+			}
+			if isSOCKSReplyError(err) {
+				return nil, &proxyRefusedError{err}
+			}
 			return nil, err
 		}
-		if resp.StatusCode != 200 {
-			conn.Close()
-			return nil, fmt.Errorf("proxy connect failed: %s", resp.Status)
+		timer.tunneled()
+		return conn, nil
+	}
+
+	conn, err := baseDialer.DialContext(ctx, "tcp", proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+	timer.connected()
+	// The tunnel handshake gets the connect timeout too
+	if timeout := baseDialer.d.Timeout; timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+	switch proxyType {
+	case "https":
+		if conn, err = proxyTLS(ctx, conn, pc, proxyURL); err == nil {
+			conn, err = connectTunnel(conn, addr, pc, proxyURL)
+		}
+	case "http":
+		conn, err = connectTunnel(conn, addr, pc, proxyURL)
+	case "socks4", "socks4a":
+		err = socks4Connect(conn, addr, proxyURL)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetDeadline(time.Time{})
+	timer.tunneled()
+	return conn, nil
+}
+
+// hostDialer dials from cfg's local_address, resolving host names with
+// cfg's resolve and hosts entries, then its DNS servers, or the system
+// resolver when res is nil.
+type hostDialer struct {
+	d   *net.Dialer
+	res *resolver
+	cfg *config.Config
+	// dial makes one connection attempt; nil for dialIP. Tests replace it.
+	dial func(ctx context.Context, network string, ip net.IP, port string) (net.Conn, error)
+}
+
+func (h hostDialer) Dial(network, addr string) (net.Conn, error) {
+	return h.DialContext(context.Background(), network, addr)
+}
+
+// DialContext races the addresses of the host (see lookupFamilies and
+// dialParallel).
+func (h hostDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return h.attempt(ctx, network, ip, port)
+	}
+	ips, late, err := h.lookupFamilies(ctx, host, port)
+	if err != nil {
+		return nil, err
+	}
+	return h.dialParallel(ctx, network, interleaveFamilies(ips), late, port)
+}
+
+// connectionAttemptDelay is how long a connection attempt runs alone
+// before the next address is tried alongside it (RFC 8305 section 5).
+const connectionAttemptDelay = 250 * time.Millisecond
+
+// dialParallel dials ips in order, starting the next attempt when the
+// previous one fails or has had connectionAttemptDelay, and returns the
+// first connection made; the others are closed. Addresses that arrive on
+// late are interleaved with those not yet tried.
+func (h hostDialer) dialParallel(ctx context.Context, network string, ips []net.IP, late <-chan familyAnswer, port string) (net.Conn, error) {
+	if len(ips) == 1 && late == nil {
+		return h.attempt(ctx, network, ips[0], port)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		conn net.Conn
+		err  error
+	}
+	results := make(chan result)
+	queue, pending := ips, 0
+	delay := time.NewTimer(connectionAttemptDelay)
+	defer delay.Stop()
+	armed := true // delay is running
+	start := func() {
+		ip := queue[0]
+		queue = queue[1:]
+		pending++
+		delay.Reset(connectionAttemptDelay)
+		armed = true
+		go func() {
+			conn, err := h.attempt(ctx, network, ip, port)
+			select {
+			case results <- result{conn, err}:
+			case <-ctx.Done():
+				// Another attempt won
+				if conn != nil {
+					conn.Close()
+				}
+			}
+		}()
+	}
+
+	start()
+	var firstErr error
+	for pending > 0 || late != nil {
+		select {
+		case res := <-results:
+			pending--
+			if res.err == nil {
+				return res.conn, nil
+			}
+			if firstErr == nil {
+				firstErr = res.err
+			}
+			if len(queue) > 0 && ctx.Err() == nil {
+				start()
+			}
+		case <-delay.C:
+			armed = false
+			if len(queue) > 0 {
+				start()
+			}
+		case ans := <-late:
+			late = nil
+			if ans.err != nil {
+				continue
+			}
+			queue = interleaveFamilies(append(queue, ans.ips...))
+			// Without an attempt in its delay, the new addresses need not
+			// wait for one.
+			if (pending == 0 || !armed) && ctx.Err() == nil {
+				start()
+			}
+		}
+	}
+	return nil, firstErr
+}
+
+// attempt is h.dial, or dialIP.
+func (h hostDialer) attempt(ctx context.Context, network string, ip net.IP, port string) (net.Conn, error) {
+	if h.dial != nil {
+		return h.dial(ctx, network, ip, port)
+	}
+	return h.dialIP(ctx, network, ip, port)
+}
+
+// dialIP dials ip:port from the next local address.
+func (h hostDialer) dialIP(ctx context.Context, network string, ip net.IP, port string) (net.Conn, error) {
+	local, err := localIP(ctx, h.cfg, ip)
+	if err != nil {
+		return nil, err
+	}
+	d := h.d
+	if local != nil {
+		bound := *h.d
+		bound.LocalAddr = localAddr(network, local)
+		d = &bound
+	}
+	return d.DialContext(ctx, network, net.JoinHostPort(ip.String(), port))
+}
+
+// interleaveFamilies orders ips IPv6, IPv4, IPv6, ... keeping the order
+// within each family, so a broken family costs one attempt delay at most
+// (RFC 8305 section 4).
+func interleaveFamilies(ips []net.IP) []net.IP {
+	var v4, v6 []net.IP
+	for _, ip := range ips {
+		if ip.To4() != nil {
+			v4 = append(v4, ip)
+		} else {
+			v6 = append(v6, ip)
+		}
+	}
+	out := make([]net.IP, 0, len(ips))
+	for i := 0; i < len(v4) || i < len(v6); i++ {
+		if i < len(v6) {
+			out = append(out, v6[i])
+		}
+		if i < len(v4) {
+			out = append(out, v4[i])
+		}
+	}
+	return out
+}
+
+// resolutionDelay is how long dialing waits for the AAAA answer once the
+// A answer is in (RFC 8305 section 3).
+const resolutionDelay = 50 * time.Millisecond
+
+// familyAnswer is the lookup of one address family.
+type familyAnswer struct {
+	ips []net.IP
+	err error
+}
+
+// lookupFamilies resolves host, to be dialed on port, and returns as soon
+// as dialing should start. When ip_version allows both families and no
+// resolve or hosts entry applies, the AAAA and A queries are made apart:
+// dialing starts with the AAAA answer, or resolutionDelay after the A
+// answer, and the answer still outstanding then comes on late. When both
+// lookups fail, the A lookup's error is returned.
+func (h hostDialer) lookupFamilies(ctx context.Context, host, port string) (ips []net.IP, late <-chan familyAnswer, err error) {
+	network, err := ipNetwork("ip", &h.cfg.DNS)
+	if err != nil {
+		return nil, nil, err
+	}
+	if static, err := staticLookup(h.cfg, host, port); network != "ip" || static != nil || err != nil {
+		ips, err := h.lookup(ctx, "ip", host, port)
+		return ips, nil, err
+	}
+	defer func() {
+		if err == nil {
+			timerFrom(ctx).resolved()
+		}
+	}()
+
+	v6, v4 := make(chan familyAnswer, 1), make(chan familyAnswer, 1)
+	go func() {
+		ips, err := h.resolve(ctx, "ip6", host)
+		v6 <- familyAnswer{ips, err}
+	}()
+	go func() {
+		ips, err := h.resolve(ctx, "ip4", host)
+		v4 <- familyAnswer{ips, err}
+	}()
+
+	var a4 familyAnswer
+	select {
+	case a6 := <-v6:
+		if a6.err == nil {
+			return a6.ips, v4, nil
+		}
+		if a4 = <-v4; a4.err != nil {
+			return nil, nil, a4.err
 		}
-	} else {
-		conn, err = dialer.Dial("tcp", addr)
+		return a4.ips, nil, nil
+	case a4 = <-v4:
+	}
+	if a4.err != nil {
+		if a6 := <-v6; a6.err == nil {
+			return a6.ips, nil, nil
+		}
+		return nil, nil, a4.err
+	}
+	wait := time.NewTimer(resolutionDelay)
+	defer wait.Stop()
+	select {
+	case a6 := <-v6:
+		if a6.err == nil {
+			return append(a6.ips, a4.ips...), nil, nil
+		}
+		return a4.ips, nil, nil
+	case <-wait.C:
+		return a4.ips, v6, nil
+	}
+}
+
+// lookup resolves host, to be dialed on port, for network ip or ip4,
+// narrowed to the configured ip_version.
+func (h hostDialer) lookup(ctx context.Context, network, host, port string) ([]net.IP, error) {
+	network, err := ipNetwork(network, &h.cfg.DNS)
+	if err != nil {
+		return nil, err
+	}
+	ips, err := staticLookup(h.cfg, host, port)
+	if err != nil {
+		return nil, err
+	}
+	if ips != nil {
+		if network != "ip" {
+			ips = slices.DeleteFunc(ips, func(ip net.IP) bool { return (ip.To4() != nil) != (network == "ip4") })
+			if len(ips) == 0 {
+				return nil, fmt.Errorf("no %s address for %s in resolve or hosts", ipFamilyName[network], host)
+			}
+		}
+		return ips, nil
+	}
+	ips, err = h.resolve(ctx, network, host)
+	if err == nil {
+		timerFrom(ctx).resolved()
+	}
+	return ips, err
+}
+
+// resolve looks host up for network with the DNS servers, or the system
+// resolver when there are none.
+func (h hostDialer) resolve(ctx context.Context, network, host string) ([]net.IP, error) {
+	if h.res == nil {
+		return systemLookup(ctx, h.cfg, network, host)
+	}
+	return h.res.lookup(ctx, network, host)
+}
+
+var ipFamilyName = map[string]string{"ip4": "IPv4", "ip6": "IPv6"}
+
+// ipNetwork narrows network, ip or the ip4 a proxy requires, to the
+// address family ip_version allows.
+func ipNetwork(network string, dns *config.DNSConfig) (string, error) {
+	allowed, err := dns.Network()
+	if err != nil {
+		return "", err
+	}
+	switch {
+	case allowed == "ip":
+		return network, nil
+	case network == "ip" || network == allowed:
+		return allowed, nil
+	}
+	return "", fmt.Errorf("ip_version %s excludes the %s addresses the proxy needs", dns.IPVersion, ipFamilyName[network])
+}
+
+// resolveAddr replaces the host in addr with its first address of the
+// given network (ip or ip4), for proxies that only take addresses. An
+// address literal in addr is not checked against ip_version.
+func (h hostDialer) resolveAddr(ctx context.Context, network, addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	if net.ParseIP(host) != nil {
+		return addr, nil
+	}
+	ips, err := h.lookup(ctx, network, host, port)
+	if err != nil {
+		return "", err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// staticAddr is resolveAddr for resolve and hosts entries only; other
+// names are left for the proxy to resolve.
+func (h hostDialer) staticAddr(addr string) (string, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return "", err
+	}
+	ips, err := staticLookup(h.cfg, host, port)
+	if err != nil || ips == nil {
+		return addr, err
+	}
+	return net.JoinHostPort(ips[0].String(), port), nil
+}
+
+// proxyTLS runs a standard TLS handshake with an https proxy. Only the
+// tunneled connection to the origin carries the configured fingerprint.
+func proxyTLS(ctx context.Context, conn net.Conn, pc *config.ProxyConfig, proxyURL *url.URL) (net.Conn, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         proxyURL.Hostname(),
+		InsecureSkipVerify: pc.Insecure,
+		NextProtos:         []string{"http/1.1"},
+	}
+	if pc.CAFile != "" {
+		pool, err := loadCAFile(pc.CAFile)
 		if err != nil {
-			return nil, err
+			return conn, err
 		}
+		tlsConfig.RootCAs = pool
 	}
+	tlsConn := tls.Client(conn, tlsConfig)
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		var verr *tls.CertificateVerificationError
+		if errors.As(err, &verr) {
+			return conn, &CertificateError{Host: proxyURL.Hostname(), Err: err}
+		}
+		return conn, err
+	}
+	return tlsConn, nil
+}
 
-	return conn, nil
+// ProxyAuthError is returned when the proxy rejects the credentials, or
+// asks for credentials that were not given: an HTTP 407 or a failed SOCKS5
+// authentication.
+type ProxyAuthError struct {
+	Proxy string // host:port of the proxy
+	Err   error
+}
+
+func (e *ProxyAuthError) Error() string {
+	return fmt.Sprintf("proxy authentication failed for %s: %v", e.Proxy, e.Err)
+}
+
+func (e *ProxyAuthError) Unwrap() error { return e.Err }
+
+// proxyCredentials returns the configured proxy username and password,
+// falling back to the userinfo of the proxy URL.
+func proxyCredentials(pc *config.ProxyConfig, u *url.URL) (user, pass string, ok bool) {
+	if pc.Username != "" {
+		return pc.Username, pc.Password, true
+	}
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		return u.User.Username(), pass, true
+	}
+	return "", "", false
+}
+
+// connectTunnel asks the HTTP proxy on conn to open a tunnel to addr,
+// sending Basic credentials and the configured CONNECT headers. It returns
+// the conn to use for the tunnel, which replays any bytes the proxy sent
+// after its response, and conn itself on error.
+func connectTunnel(conn net.Conn, addr string, pc *config.ProxyConfig, proxyURL *url.URL) (net.Conn, error) {
+	headers := config.Headers{{Name: "Host", Value: addr}}
+	if user, pass, ok := proxyCredentials(pc, proxyURL); ok && pc.ConnectHeaders.Get("Proxy-Authorization") == "" {
+		token := base64.StdEncoding.EncodeToString([]byte(user + ":" + pass))
+		headers = append(headers, config.Header{Name: "Proxy-Authorization", Value: "Basic " + token})
+	}
+	for _, hdr := range pc.ConnectHeaders {
+		if strings.EqualFold(hdr.Name, "Host") {
+			headers[0].Value = hdr.Value
+			continue
+		}
+		headers = append(headers, hdr)
+	}
+
+	for _, hdr := range headers {
+		if err := config.CheckHeaderName(hdr.Name); err != nil {
+			return conn, &ConfigError{fmt.Errorf("proxy connect_headers: %v", err)}
+		}
+	}
+	bw := bufio.NewWriter(conn)
+	fmt.Fprintf(bw, "CONNECT %s HTTP/1.1\r\n", addr)
+	for _, hdr := range headers {
+		fmt.Fprintf(bw, "%s: %s\r\n", hdr.Name, strings.NewReplacer("\r", " ", "\n", " ").Replace(hdr.Value))
+	}
+	bw.WriteString("\r\n")
+	if err := bw.Flush(); err != nil {
+		return conn, err
+	}
+
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, &http.Request{Method: "CONNECT"})
+	if err != nil {
+		return conn, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		if br.Buffered() > 0 {
+			return &bufferedConn{conn, br}, nil
+		}
+		return conn, nil
+	case http.StatusProxyAuthRequired:
+		return conn, &ProxyAuthError{Proxy: proxyURL.Host, Err: fmt.Errorf("proxy connect failed: %s", resp.Status)}
+	}
+	return conn, &proxyRefusedError{fmt.Errorf("proxy connect failed: %s", resp.Status)}
+}
+
+// bufferedConn reads through r, which holds bytes already read from Conn.
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) { return c.r.Read(p) }
+
+// proxyRefusedError is a proxy answering that it will not or cannot open
+// the tunnel, such as a CONNECT 502 for an unreachable target or a SOCKS
+// "host unreachable" reply. The proxy itself is working.
+type proxyRefusedError struct {
+	err error
+}
+
+func (e *proxyRefusedError) Error() string { return e.err.Error() }
+func (e *proxyRefusedError) Unwrap() error { return e.err }
+
+// isSOCKSAuthError reports a SOCKS5 handshake that failed on
+// authentication; x/net/proxy does not export typed errors for it.
+func isSOCKSAuthError(err error) bool {
+	msg := err.Error()
+	return strings.Contains(msg, "authentication failed") || strings.Contains(msg, "no acceptable authentication methods")
+}
+
+// isSOCKSReplyError reports a SOCKS5 server replying to CONNECT with a
+// failure code, which x/net/proxy reports as "unknown error <reason>".
+func isSOCKSReplyError(err error) bool {
+	return strings.Contains(err.Error(), "unknown error ")
+}
+
+// timedDialer dials a SOCKS proxy, marking when its TCP connection is up.
+type timedDialer struct {
+	d     hostDialer
+	timer *Timer
+}
+
+func (t timedDialer) Dial(network, addr string) (net.Conn, error) {
+	return t.DialContext(context.Background(), network, addr)
+}
+
+func (t timedDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.d.DialContext(ctx, network, addr)
+	if err == nil {
+		t.timer.connected()
+	}
+	return conn, err
 }
@@ -0,0 +1,708 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"net/textproto"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// connectProxy is an in-process HTTP proxy that answers CONNECT. Targets
+// starting with "unreachable" get a 502; with wantAuth set, requests
+// without that Proxy-Authorization get a 407. An opened tunnel writes
+// "tunnel to <Host header>" and closes.
+type connectProxy struct {
+	addr     string
+	wantAuth string
+
+	mu       sync.Mutex
+	requests []connectRequest
+}
+
+// connectRequest is a CONNECT as the proxy read it, headers unmodified.
+type connectRequest struct {
+	target string
+	header textproto.MIMEHeader
+}
+
+// newConnectProxy starts a connectProxy, serving TLS with tlsConfig if it
+// is not nil.
+func newConnectProxy(t *testing.T, tlsConfig *tls.Config) *connectProxy {
+	t.Helper()
+	p := &connectProxy{}
+	p.addr = serveStub(t, func(conn net.Conn) {
+		if tlsConfig != nil {
+			conn = tls.Server(conn, tlsConfig)
+		}
+		r := textproto.NewReader(bufio.NewReader(conn))
+		line, err := r.ReadLine()
+		if err != nil {
+			return
+		}
+		header, err := r.ReadMIMEHeader()
+		if err != nil {
+			return
+		}
+		method, target, _ := strings.Cut(line, " ")
+		target, _, _ = strings.Cut(target, " ")
+		p.mu.Lock()
+		p.requests = append(p.requests, connectRequest{target, header})
+		p.mu.Unlock()
+		switch {
+		case method != "CONNECT":
+			io.WriteString(conn, "HTTP/1.1 405 Method Not Allowed\r\nContent-Length: 0\r\n\r\n")
+		case p.wantAuth != "" && header.Get("Proxy-Authorization") != p.wantAuth:
+			io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\nProxy-Authenticate: Basic realm=\"proxy\"\r\nContent-Length: 0\r\n\r\n")
+		case strings.HasPrefix(target, "unreachable"):
+			io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\nContent-Length: 0\r\n\r\n")
+		default:
+			// The greeting goes in the same write as the response, so it
+			// arrives while the client is still reading the response.
+			io.WriteString(conn, "HTTP/1.1 200 Connection Established\r\n\r\ntunnel to "+header.Get("Host"))
+		}
+	})
+	return p
+}
+
+// lastRequest returns the last CONNECT the proxy received.
+func (p *connectProxy) lastRequest() connectRequest {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.requests[len(p.requests)-1]
+}
+
+// proxyConfig is the default config sending everything through pc.
+func proxyConfig(pc config.ProxyConfig) *config.Config {
+	cfg := config.Default()
+	pc.Enabled = true
+	cfg.Proxy = pc
+	return cfg
+}
+
+// dialTunnel dials addr through cfg's proxy and returns what the tunnel
+// delivered.
+func dialTunnel(t *testing.T, addr string, cfg *config.Config) (string, error) {
+	t.Helper()
+	conn, err := DialContext(context.Background(), addr, cfg)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	data, err := io.ReadAll(conn)
+	return string(data), err
+}
+
+func basicAuth(user, pass string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(user+":"+pass))
+}
+
+func TestConnectProxyAuthorization(t *testing.T) {
+	p := newConnectProxy(t, nil)
+	host := p.addr
+	tests := []struct {
+		name     string
+		proxy    config.ProxyConfig
+		wantAuth string // Proxy-Authorization the proxy must receive
+		wantHost string
+	}{
+		{"no credentials", config.ProxyConfig{URL: "http://" + host}, "", "example.com:443"},
+		{"URL userinfo", config.ProxyConfig{URL: "http://user:p%40ss@" + host}, basicAuth("user", "p@ss"), "example.com:443"},
+		{"username and password", config.ProxyConfig{URL: "http://ignored:x@" + host, Username: "alice", Password: "s:cret"}, basicAuth("alice", "s:cret"), "example.com:443"},
+		{"connect_headers", config.ProxyConfig{
+			URL:            "http://user:pass@" + host,
+			ConnectHeaders: config.Headers{{Name: "Proxy-Authorization", Value: "Bearer token"}, {Name: "Host", Value: "example.com"}, {Name: "X-Session", Value: "7"}},
+		}, "Bearer token", "example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dialTunnel(t, "example.com:443", proxyConfig(tt.proxy))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "tunnel to "+tt.wantHost {
+				t.Errorf("tunnel delivered %q", got)
+			}
+			r := p.lastRequest()
+			if r.target != "example.com:443" {
+				t.Errorf("CONNECT %s, want example.com:443", r.target)
+			}
+			if auth := r.header.Get("Proxy-Authorization"); auth != tt.wantAuth {
+				t.Errorf("Proxy-Authorization %q, want %q", auth, tt.wantAuth)
+			}
+			if vals := r.header.Values("Proxy-Authorization"); len(vals) > 1 {
+				t.Errorf("Proxy-Authorization sent %d times", len(vals))
+			}
+			for _, hdr := range tt.proxy.ConnectHeaders {
+				if r.header.Get(hdr.Name) != hdr.Value {
+					t.Errorf("%s %q, want %q", hdr.Name, r.header.Get(hdr.Name), hdr.Value)
+				}
+			}
+		})
+	}
+}
+
+func TestConnectProxyRejects(t *testing.T) {
+	p := newConnectProxy(t, nil)
+	p.wantAuth = basicAuth("user", "right")
+	host := p.addr
+
+	_, err := dialTunnel(t, "example.com:443", proxyConfig(config.ProxyConfig{URL: "http://user:wrong@" + host}))
+	var authErr *ProxyAuthError
+	if !errors.As(err, &authErr) || authErr.Proxy != host {
+		t.Errorf("wrong password: error %v, want a ProxyAuthError for %s", err, host)
+	}
+	if errType, code := ClassifyError(err); errType != "PROXY_AUTH_ERROR" || code != 6 {
+		t.Errorf("wrong password classified as %s (%d)", errType, code)
+	}
+
+	_, err = dialTunnel(t, "unreachable.example:443", proxyConfig(config.ProxyConfig{URL: "http://user:right@" + host}))
+	var refused *proxyRefusedError
+	if !errors.As(err, &refused) || !strings.Contains(err.Error(), "502") {
+		t.Errorf("502: error %v, want a proxyRefusedError", err)
+	}
+
+	// The header name check catches a bad connect_headers entry.
+	_, err = dialTunnel(t, "example.com:443", proxyConfig(config.ProxyConfig{
+		URL:            "http://user:right@" + host,
+		ConnectHeaders: config.Headers{{Name: "Bad Header", Value: "x"}},
+	}))
+	var cfgErr *ConfigError
+	if !errors.As(err, &cfgErr) {
+		t.Errorf("bad header name: error %v, want a ConfigError", err)
+	}
+}
+
+// socks5Proxy is an in-process SOCKS5 proxy requiring user:pass
+// authentication. A granted CONNECT writes "socks5 to <host:port>" and
+// closes.
+func socks5Proxy(t *testing.T, user, pass string) string {
+	t.Helper()
+	return serveStub(t, func(conn net.Conn) {
+		buf := make([]byte, 512)
+		// Greeting: VER NMETHODS METHODS
+		if _, err := io.ReadFull(conn, buf[:2]); err != nil {
+			return
+		}
+		methods := buf[2 : 2+buf[1]]
+		io.ReadFull(conn, methods)
+		if !bytes.Contains(methods, []byte{2}) {
+			conn.Write([]byte{5, 0xff})
+			return
+		}
+		conn.Write([]byte{5, 2})
+		// RFC 1929: VER ULEN UNAME PLEN PASSWD
+		io.ReadFull(conn, buf[:2])
+		u := make([]byte, buf[1])
+		io.ReadFull(conn, u)
+		io.ReadFull(conn, buf[:1])
+		p := make([]byte, buf[0])
+		io.ReadFull(conn, p)
+		if string(u) != user || string(p) != pass {
+			conn.Write([]byte{1, 1})
+			return
+		}
+		conn.Write([]byte{1, 0})
+		// VER CMD RSV ATYP, with a domain name for socks5h
+		io.ReadFull(conn, buf[:4])
+		target := ""
+		switch buf[3] {
+		case 1:
+			io.ReadFull(conn, buf[:4])
+			target = net.IP(buf[:4]).String()
+		case 3:
+			io.ReadFull(conn, buf[:1])
+			name := make([]byte, buf[0])
+			io.ReadFull(conn, name)
+			target = string(name)
+		}
+		io.ReadFull(conn, buf[:2])
+		port := int(buf[0])<<8 | int(buf[1])
+		if target == "unreachable.example" {
+			conn.Write([]byte{5, 4, 0, 1, 0, 0, 0, 0, 0, 0}) // host unreachable
+			return
+		}
+		conn.Write([]byte{5, 0, 0, 1, 127, 0, 0, 1, 0, 80})
+		io.WriteString(conn, "socks5 to "+net.JoinHostPort(target, strconv.Itoa(port)))
+	})
+}
+
+// serveStub runs handle for every connection to a new loopback listener
+// and returns its address.
+func serveStub(t *testing.T, handle func(net.Conn)) string {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				handle(conn)
+			}()
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func TestSOCKS5ProxyAuthentication(t *testing.T) {
+	addr := socks5Proxy(t, "user", "pass")
+
+	got, err := dialTunnel(t, "example.com:443", proxyConfig(config.ProxyConfig{URL: "socks5h://user:pass@" + addr}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "socks5 to example.com:443" {
+		t.Errorf("tunnel delivered %q", got)
+	}
+
+	for name, pc := range map[string]config.ProxyConfig{
+		"wrong password": {URL: "socks5h://user:wrong@" + addr},
+		"no credentials": {URL: "socks5h://" + addr},
+	} {
+		_, err := dialTunnel(t, "example.com:443", proxyConfig(pc))
+		var authErr *ProxyAuthError
+		if !errors.As(err, &authErr) {
+			t.Errorf("%s: error %v, want a ProxyAuthError", name, err)
+		}
+	}
+
+	_, err = dialTunnel(t, "unreachable.example:443", proxyConfig(config.ProxyConfig{URL: "socks5h://user:pass@" + addr}))
+	var refused *proxyRefusedError
+	if !errors.As(err, &refused) {
+		t.Errorf("host unreachable reply: error %v, want a proxyRefusedError", err)
+	}
+}
+
+func TestHTTPSProxy(t *testing.T) {
+	// httptest's certificate is valid for 127.0.0.1.
+	ts := httptest.NewTLSServer(nil)
+	tlsConfig := ts.TLS.Clone()
+	caFile := writeFile(t, t.TempDir(), "ca.pem", certPEM(ts.Certificate()))
+	ts.Close()
+
+	p := newConnectProxy(t, tlsConfig)
+	p.wantAuth = basicAuth("user", "pass")
+	tests := []struct {
+		name  string
+		proxy config.ProxyConfig
+		err   bool
+	}{
+		{"insecure", config.ProxyConfig{URL: "https://user:pass@" + p.addr, Insecure: true}, false},
+		{"ca_file", config.ProxyConfig{URL: "https://user:pass@" + p.addr, CAFile: caFile}, false},
+		{"untrusted", config.ProxyConfig{URL: "https://user:pass@" + p.addr}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := dialTunnel(t, "example.com:443", proxyConfig(tt.proxy))
+			if tt.err {
+				var certErr *CertificateError
+				if !errors.As(err, &certErr) || certErr.Host != "127.0.0.1" {
+					t.Errorf("error %v, want a CertificateError for 127.0.0.1", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The stub only parses CONNECT inside TLS, and only answers
+			// with a tunnel when the credentials arrived.
+			if got != "tunnel to example.com:443" {
+				t.Errorf("tunnel delivered %q", got)
+			}
+		})
+	}
+}
+
+func TestInterleaveFamilies(t *testing.T) {
+	ips := func(s string) []net.IP {
+		var out []net.IP
+		for _, f := range strings.Fields(s) {
+			out = append(out, net.ParseIP(f))
+		}
+		return out
+	}
+	tests := []struct{ in, want string }{
+		{"192.0.2.1 192.0.2.2 2001:db8::1 2001:db8::2", "2001:db8::1 192.0.2.1 2001:db8::2 192.0.2.2"},
+		{"192.0.2.1 2001:db8::1 2001:db8::2 2001:db8::3", "2001:db8::1 192.0.2.1 2001:db8::2 2001:db8::3"},
+		{"2001:db8::1 192.0.2.1 192.0.2.2 192.0.2.3", "2001:db8::1 192.0.2.1 192.0.2.2 192.0.2.3"},
+		{"192.0.2.2 192.0.2.1", "192.0.2.2 192.0.2.1"},
+		{"::ffff:192.0.2.1 2001:db8::1", "2001:db8::1 192.0.2.1"},
+	}
+	for _, tt := range tests {
+		got := fmt.Sprint(interleaveFamilies(ips(tt.in)))
+		if want := fmt.Sprint(ips(tt.want)); got != want {
+			t.Errorf("%s: got %s, want %s", tt.in, got, want)
+		}
+	}
+}
+
+// fakeConn is a connection to ip that records being closed.
+type fakeConn struct {
+	net.Conn
+	ip     string
+	once   sync.Once
+	closed chan struct{}
+}
+
+func (c *fakeConn) Close() error {
+	c.once.Do(func() { close(c.closed) })
+	return nil
+}
+
+// dialBehavior is how a fakeDialer's attempt to one address goes: it
+// fails after a time, succeeds after a time (ignoring cancellation, like
+// a connect that completed as it was abandoned), or hangs until canceled.
+type dialBehavior struct {
+	after time.Duration
+	err   error
+	hang  bool
+}
+
+// fakeDialer stands in for hostDialer.dial, recording when each address
+// was tried and the connections it made.
+type fakeDialer struct {
+	start    time.Time
+	behavior map[string]dialBehavior
+
+	mu       sync.Mutex
+	attempts []string // "ip@ms", ms rounded down to 50
+	conns    map[string]*fakeConn
+}
+
+func newFakeDialer(behavior map[string]dialBehavior) *fakeDialer {
+	return &fakeDialer{start: time.Now(), behavior: behavior, conns: map[string]*fakeConn{}}
+}
+
+func (d *fakeDialer) dial(ctx context.Context, network string, ip net.IP, port string) (net.Conn, error) {
+	at := time.Since(d.start).Truncate(50 * time.Millisecond).Milliseconds()
+	d.mu.Lock()
+	d.attempts = append(d.attempts, fmt.Sprintf("%s@%d", ip, at))
+	d.mu.Unlock()
+
+	b := d.behavior[ip.String()]
+	if b.hang {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	time.Sleep(b.after)
+	if b.err != nil {
+		return nil, b.err
+	}
+	conn := &fakeConn{ip: ip.String(), closed: make(chan struct{})}
+	d.mu.Lock()
+	d.conns[ip.String()] = conn
+	d.mu.Unlock()
+	return conn, nil
+}
+
+func TestDialParallel(t *testing.T) {
+	v6, v4, v4b := net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1"), net.ParseIP("192.0.2.2")
+	refused := errors.New("connection refused")
+	type lateAnswer struct {
+		after time.Duration
+		familyAnswer
+	}
+	tests := []struct {
+		name     string
+		ips      []net.IP
+		late     *lateAnswer
+		behavior map[string]dialBehavior
+		attempts []string // "ip@ms" as fakeDialer records them
+		winner   string   // "" for an error
+		closed   []string // connections that lost the race
+	}{
+		{
+			name:     "first address answers",
+			ips:      []net.IP{v6, v4},
+			attempts: []string{"2001:db8::1@0"},
+			winner:   "2001:db8::1",
+		},
+		{
+			name:     "next attempt after the attempt delay",
+			ips:      []net.IP{v6, v4},
+			behavior: map[string]dialBehavior{"2001:db8::1": {hang: true}},
+			attempts: []string{"2001:db8::1@0", "192.0.2.1@250"},
+			winner:   "192.0.2.1",
+		},
+		{
+			name:     "next attempt as soon as one fails",
+			ips:      []net.IP{v6, v4, v4b},
+			behavior: map[string]dialBehavior{"2001:db8::1": {err: refused}, "192.0.2.1": {err: refused}},
+			attempts: []string{"2001:db8::1@0", "192.0.2.1@0", "192.0.2.2@0"},
+			winner:   "192.0.2.2",
+		},
+		{
+			name:     "loser closed",
+			ips:      []net.IP{v6, v4},
+			behavior: map[string]dialBehavior{"2001:db8::1": {after: 350 * time.Millisecond}},
+			attempts: []string{"2001:db8::1@0", "192.0.2.1@250"},
+			winner:   "192.0.2.1",
+			closed:   []string{"2001:db8::1"},
+		},
+		{
+			name:     "all fail",
+			ips:      []net.IP{v6, v4},
+			behavior: map[string]dialBehavior{"2001:db8::1": {err: refused}, "192.0.2.1": {err: errors.New("no route to host")}},
+			attempts: []string{"2001:db8::1@0", "192.0.2.1@0"},
+		},
+		{
+			name:     "late answer waits out the attempt delay",
+			ips:      []net.IP{v4},
+			late:     &lateAnswer{20 * time.Millisecond, familyAnswer{ips: []net.IP{v6}}},
+			behavior: map[string]dialBehavior{"192.0.2.1": {hang: true}},
+			attempts: []string{"192.0.2.1@0", "2001:db8::1@250"},
+			winner:   "2001:db8::1",
+		},
+		{
+			name:     "late answer after the attempt delay",
+			ips:      []net.IP{v4},
+			late:     &lateAnswer{300 * time.Millisecond, familyAnswer{ips: []net.IP{v6}}},
+			behavior: map[string]dialBehavior{"192.0.2.1": {hang: true}},
+			attempts: []string{"192.0.2.1@0", "2001:db8::1@300"},
+			winner:   "2001:db8::1",
+		},
+		{
+			name:     "late answer after every attempt failed",
+			ips:      []net.IP{v4},
+			late:     &lateAnswer{100 * time.Millisecond, familyAnswer{ips: []net.IP{v6}}},
+			behavior: map[string]dialBehavior{"192.0.2.1": {err: refused}},
+			attempts: []string{"192.0.2.1@0", "2001:db8::1@100"},
+			winner:   "2001:db8::1",
+		},
+		{
+			name:     "late lookup fails",
+			ips:      []net.IP{v4},
+			late:     &lateAnswer{50 * time.Millisecond, familyAnswer{err: errors.New("no such host")}},
+			behavior: map[string]dialBehavior{"192.0.2.1": {err: refused}},
+			attempts: []string{"192.0.2.1@0"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			d := newFakeDialer(tt.behavior)
+			h := hostDialer{d: &net.Dialer{}, cfg: config.Default(), dial: d.dial}
+			var late chan familyAnswer
+			if tt.late != nil {
+				late = make(chan familyAnswer, 1)
+				time.AfterFunc(tt.late.after, func() { late <- tt.late.familyAnswer })
+			}
+
+			conn, err := h.dialParallel(context.Background(), "tcp", tt.ips, late, "443")
+			if tt.winner == "" {
+				if !errors.Is(err, refused) {
+					t.Errorf("error %v, want the first attempt's", err)
+				}
+			} else if err != nil {
+				t.Fatal(err)
+			} else if got := conn.(*fakeConn).ip; got != tt.winner {
+				t.Errorf("connected to %s, want %s", got, tt.winner)
+			}
+
+			for _, ip := range tt.closed {
+				// The loser's connect completes after the race is over.
+				deadline := time.Now().Add(time.Second)
+				var loser *fakeConn
+				for loser == nil && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+					d.mu.Lock()
+					loser = d.conns[ip]
+					d.mu.Unlock()
+				}
+				if loser == nil {
+					t.Errorf("no connection to %s was made", ip)
+					continue
+				}
+				select {
+				case <-loser.closed:
+				case <-time.After(time.Second):
+					t.Errorf("losing connection to %s left open", ip)
+				}
+			}
+			if conn != nil {
+				select {
+				case <-conn.(*fakeConn).closed:
+					t.Error("winning connection closed")
+				default:
+				}
+			}
+			d.mu.Lock()
+			defer d.mu.Unlock()
+			if !slices.Equal(d.attempts, tt.attempts) {
+				t.Errorf("attempts %q, want %q", d.attempts, tt.attempts)
+			}
+		})
+	}
+}
+
+// dnsStub is an in-process UDP DNS server. Each query type answers
+// after its delay with its addresses, or with no records.
+type dnsStub struct {
+	addr   string
+	delay  map[dnsmessage.Type]time.Duration
+	answer map[dnsmessage.Type][]net.IP
+
+	mu      sync.Mutex
+	queries []string
+}
+
+func newDNSStub(t *testing.T, delay map[dnsmessage.Type]time.Duration, answer map[dnsmessage.Type][]net.IP) *dnsStub {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+	s := &dnsStub{addr: pc.LocalAddr().String(), delay: delay, answer: answer}
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+			s.mu.Lock()
+			s.queries = append(s.queries, q.Type.String())
+			s.mu.Unlock()
+			time.AfterFunc(s.delay[q.Type], func() {
+				b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: h.ID, Response: true})
+				b.StartQuestions()
+				b.Question(q)
+				b.StartAnswers()
+				rh := dnsmessage.ResourceHeader{Name: q.Name, Class: dnsmessage.ClassINET, TTL: 60}
+				for _, ip := range s.answer[q.Type] {
+					if q.Type == dnsmessage.TypeA {
+						var r dnsmessage.AResource
+						copy(r.A[:], ip.To4())
+						b.AResource(rh, r)
+					} else {
+						var r dnsmessage.AAAAResource
+						copy(r.AAAA[:], ip)
+						b.AAAAResource(rh, r)
+					}
+				}
+				msg, _ := b.Finish()
+				pc.WriteTo(msg, from)
+			})
+		}
+	}()
+	return s
+}
+
+func (s *dnsStub) queried() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return slices.Sorted(slices.Values(s.queries))
+}
+
+func TestLookupFamilies(t *testing.T) {
+	v6, v4 := net.ParseIP("2001:db8::1"), net.ParseIP("192.0.2.1")
+	both := map[dnsmessage.Type][]net.IP{dnsmessage.TypeA: {v4}, dnsmessage.TypeAAAA: {v6}}
+	const slow = 300 * time.Millisecond
+	tests := []struct {
+		name      string
+		delay     map[dnsmessage.Type]time.Duration
+		answer    map[dnsmessage.Type][]net.IP
+		ipVersion string
+		hosts     map[string]string
+		first     string // addresses to dial at once, or "error: ..."
+		within    time.Duration
+		late      string // addresses arriving later, "" for none
+		queried   []string
+	}{
+		{"AAAA first", map[dnsmessage.Type]time.Duration{dnsmessage.TypeA: slow}, both, "", nil,
+			"[2001:db8::1]", 200 * time.Millisecond, "[192.0.2.1]", []string{"TypeA", "TypeAAAA"}},
+		{"AAAA within the resolution delay", map[dnsmessage.Type]time.Duration{dnsmessage.TypeAAAA: 20 * time.Millisecond}, both, "", nil,
+			"[2001:db8::1 192.0.2.1]", 45 * time.Millisecond, "", []string{"TypeA", "TypeAAAA"}},
+		{"AAAA after the resolution delay", map[dnsmessage.Type]time.Duration{dnsmessage.TypeAAAA: slow}, both, "", nil,
+			"[192.0.2.1]", 200 * time.Millisecond, "[2001:db8::1]", []string{"TypeA", "TypeAAAA"}},
+		{"no AAAA records", nil, map[dnsmessage.Type][]net.IP{dnsmessage.TypeA: {v4}}, "", nil,
+			"[192.0.2.1]", 45 * time.Millisecond, "", []string{"TypeA", "TypeAAAA"}},
+		{"no A records", map[dnsmessage.Type]time.Duration{dnsmessage.TypeAAAA: 20 * time.Millisecond}, map[dnsmessage.Type][]net.IP{dnsmessage.TypeAAAA: {v6}}, "", nil,
+			"[2001:db8::1]", 45 * time.Millisecond, "", []string{"TypeA", "TypeAAAA"}},
+		{"no records", nil, nil, "", nil,
+			"error: dns: failed to resolve example.com", time.Second, "", []string{"TypeA", "TypeAAAA"}},
+		{"ip_version 4", nil, both, "4", nil,
+			"[192.0.2.1]", 45 * time.Millisecond, "", []string{"TypeA"}},
+		{"ip_version 6", nil, both, "6", nil,
+			"[2001:db8::1]", 45 * time.Millisecond, "", []string{"TypeAAAA"}},
+		{"hosts entry", nil, both, "", map[string]string{"example.com": "192.0.2.9"},
+			"[192.0.2.9]", 45 * time.Millisecond, "", nil},
+		{"hosts entry outside ip_version", nil, both, "6", map[string]string{"example.com": "192.0.2.9"},
+			"error: no IPv6 address for example.com in resolve or hosts", 45 * time.Millisecond, "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			stub := newDNSStub(t, tt.delay, tt.answer)
+			cfg := config.Default()
+			cfg.DNS = config.DNSConfig{Servers: []string{stub.addr}, Strict: true, IPVersion: tt.ipVersion, CacheTTL: -1}
+			cfg.Hosts = tt.hosts
+			res, err := newResolver(cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			h := hostDialer{d: &net.Dialer{}, res: res, cfg: cfg}
+
+			start := time.Now()
+			ips, late, err := h.lookupFamilies(context.Background(), "example.com", "443")
+			elapsed := time.Since(start)
+			got := fmt.Sprint(ips)
+			if err != nil {
+				got = "error: " + err.Error()
+			}
+			if !strings.HasPrefix(got, tt.first) {
+				t.Errorf("got %s, want %s", got, tt.first)
+			}
+			if elapsed > tt.within {
+				t.Errorf("returned after %v, want within %v", elapsed, tt.within)
+			}
+			switch {
+			case tt.late == "" && late != nil:
+				t.Error("an answer is left to come late")
+			case tt.late != "" && late == nil:
+				t.Errorf("no late answer, want %s", tt.late)
+			case late != nil:
+				ans := <-late
+				if ans.err != nil || fmt.Sprint(ans.ips) != tt.late {
+					t.Errorf("late answer %v, %v; want %s", ans.ips, ans.err, tt.late)
+				}
+			}
+			if q := stub.queried(); !slices.Equal(q, tt.queried) {
+				t.Errorf("queried %q, want %q", q, tt.queried)
+			}
+		})
+	}
+}
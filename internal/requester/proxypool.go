@@ -0,0 +1,326 @@
+package requester
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// Quarantine defaults, see ProxyConfig.MaxFailures.
+const (
+	defaultProxyMaxFailures = 3
+	defaultProxyQuarantine  = 300 * time.Second
+)
+
+// proxyPools hold the state of each configured pool (rotation position,
+// failures) for the life of the process, keyed by type, file and URLs.
+var proxyPools = struct {
+	sync.Mutex
+	m map[string]*proxyPool
+}{m: map[string]*proxyPool{}}
+
+type proxyPool struct {
+	mu      sync.Mutex
+	entries []*proxyEntry
+	next    uint64
+	loaded  time.Time        // File's modification time when it was read
+	now     func() time.Time // nil for time.Now; tests replace it
+}
+
+type proxyEntry struct {
+	url      string
+	weight   int
+	failures int       // consecutive
+	until    time.Time // quarantined until
+}
+
+// proxyPick is the pool proxy one hop goes through.
+type proxyPick struct {
+	pool   *proxyPool
+	entry  *proxyEntry
+	cfg    config.ProxyConfig // the pool's settings with URL set to entry's
+	max    int
+	period time.Duration
+	// quarantined is set when every proxy was quarantined, so entry was
+	// picked from all of them.
+	quarantined bool
+}
+
+type proxyPickKey struct{}
+
+// withProxyPick picks the proxy for a request to host when cfg has a
+// proxy pool, unless ctx carries a pick already.
+func withProxyPick(ctx context.Context, cfg *config.Config, host string) (context.Context, error) {
+	if !cfg.Proxy.Enabled || !cfg.Proxy.IsPool() || proxyPickFrom(ctx) != nil {
+		return ctx, nil
+	}
+	pick, err := pickProxy(&cfg.Proxy, host)
+	if err != nil {
+		return nil, err
+	}
+	return context.WithValue(ctx, proxyPickKey{}, pick), nil
+}
+
+func proxyPickFrom(ctx context.Context) *proxyPick {
+	pick, _ := ctx.Value(proxyPickKey{}).(*proxyPick)
+	return pick
+}
+
+// pickProxy chooses a proxy from pc's pool for a connection to host.
+// Quarantined proxies are skipped while any other is left.
+func pickProxy(pc *config.ProxyConfig, host string) (*proxyPick, error) {
+	strategy, err := pc.PoolStrategy()
+	if err != nil {
+		return nil, &ConfigError{err}
+	}
+	pool, err := loadProxyPool(pc)
+	if err != nil {
+		return nil, &ConfigError{err}
+	}
+
+	pool.mu.Lock()
+	defer pool.mu.Unlock()
+	now := pool.clock()
+	var live []*proxyEntry
+	for _, e := range pool.entries {
+		if now.After(e.until) {
+			live = append(live, e)
+		}
+	}
+	quarantined := len(live) == 0
+	if quarantined {
+		live = pool.entries
+	}
+
+	var entry *proxyEntry
+	switch strategy {
+	case config.ProxyRoundRobin:
+		entry = live[pool.next%uint64(len(live))]
+		pool.next++
+	case config.ProxyRandom:
+		entry = live[rand.IntN(len(live))]
+	case config.ProxyWeighted:
+		total := 0
+		for _, e := range live {
+			total += e.weight
+		}
+		n := rand.IntN(total)
+		for _, e := range live {
+			if n -= e.weight; n < 0 {
+				entry = e
+				break
+			}
+		}
+	case config.ProxyStickyHost:
+		entry = rendezvous(live, strings.ToLower(host))
+	case config.ProxyStickySession:
+		entry = rendezvous(live, pc.SessionKey)
+	}
+
+	pick := &proxyPick{
+		pool:   pool,
+		entry:  entry,
+		cfg:    pc.Single(entry.url),
+		max:    pc.MaxFailures,
+		period: time.Duration(pc.Quarantine) * time.Second,
+
+		quarantined: quarantined,
+	}
+	if pick.max <= 0 {
+		pick.max = defaultProxyMaxFailures
+	}
+	if pick.period <= 0 {
+		pick.period = defaultProxyQuarantine
+	}
+	return pick, nil
+}
+
+func (p *proxyPool) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// usedProxy is the proxy URL, without its password, that requests on
+// ctx go through.
+func usedProxy(ctx context.Context, cfg *config.Config) string {
+	pc := &cfg.Proxy
+	if pick := proxyPickFrom(ctx); pick != nil {
+		pc = &pick.cfg
+	}
+	_, u, err := pc.Endpoint()
+	if err != nil {
+		return ""
+	}
+	return u.Redacted()
+}
+
+// rendezvous returns the entry with the highest hash of key and URL, so
+// a key keeps its proxy while that proxy is live, and only the keys of a
+// quarantined proxy move.
+func rendezvous(entries []*proxyEntry, key string) *proxyEntry {
+	var best *proxyEntry
+	var bestScore uint64
+	for _, e := range entries {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(e.url))
+		if score := mix64(h.Sum64()); best == nil || score > bestScore {
+			best, bestScore = e, score
+		}
+	}
+	return best
+}
+
+// mix64 is the splitmix64 finalizer. FNV alone barely changes its high
+// bits between keys that differ in a few bytes, which would send most
+// keys to one proxy.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	return x ^ x>>31
+}
+
+// allQuarantined reports a pick made while every proxy was quarantined.
+func (p *proxyPick) allQuarantined() bool {
+	return p != nil && p.quarantined
+}
+
+// report records how a dial through the picked proxy went. Once its
+// consecutive failures reach the limit, the proxy is quarantined, and it
+// stays on probation afterwards: its next failure quarantines it again.
+// Cancellations are not counted, and neither is a proxy that answered but
+// refused the target or the credentials: that proxy works.
+func (p *proxyPick) report(err error) {
+	if p == nil || errors.Is(err, context.Canceled) {
+		return
+	}
+	p.pool.mu.Lock()
+	defer p.pool.mu.Unlock()
+	e := p.entry
+	if !proxyAtFault(err) {
+		e.failures = 0
+		return
+	}
+	if e.failures++; e.failures >= p.max {
+		e.until = p.pool.clock().Add(p.period)
+	}
+}
+
+// proxyAtFault reports whether err means the proxy could not be reached
+// or did not complete its handshake.
+func proxyAtFault(err error) bool {
+	var refusedErr *proxyRefusedError
+	var authErr *ProxyAuthError
+	var cfgErr *ConfigError
+	return err != nil && !errors.As(err, &refusedErr) && !errors.As(err, &authErr) && !errors.As(err, &cfgErr)
+}
+
+// loadProxyPool returns the pool state for pc, reading File again when it
+// has changed; proxies still listed keep their failures.
+func loadProxyPool(pc *config.ProxyConfig) (*proxyPool, error) {
+	id := pc.Type + "|" + pc.File + "|" + strings.Join(pc.URLs, "\n")
+	var modTime time.Time
+	if pc.File != "" {
+		info, err := os.Stat(pc.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy file: %v", err)
+		}
+		modTime = info.ModTime()
+	}
+
+	proxyPools.Lock()
+	defer proxyPools.Unlock()
+	old := proxyPools.m[id]
+	if old != nil && old.loaded.Equal(modTime) {
+		return old, nil
+	}
+	entries, err := proxyPoolEntries(pc)
+	if err != nil {
+		return nil, err
+	}
+	pool := &proxyPool{entries: entries, loaded: modTime}
+	if old != nil {
+		old.mu.Lock()
+		for _, e := range entries {
+			for _, o := range old.entries {
+				if o.url == e.url {
+					e.failures, e.until = o.failures, o.until
+				}
+			}
+		}
+		pool.next, pool.now = old.next, old.now
+		old.mu.Unlock()
+	}
+	proxyPools.m[id] = pool
+	return pool, nil
+}
+
+// proxyPoolEntries parses pc.URLs, then the lines of pc.File, skipping
+// blank lines and # comments.
+func proxyPoolEntries(pc *config.ProxyConfig) ([]*proxyEntry, error) {
+	var entries []*proxyEntry
+	add := func(line, where string) error {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			return nil
+		}
+		e := &proxyEntry{url: fields[0], weight: 1}
+		if len(fields) > 2 {
+			return fmt.Errorf("invalid proxy %s: want \"url [weight]\"", where)
+		}
+		if len(fields) == 2 {
+			w, err := strconv.Atoi(fields[1])
+			if err != nil || w <= 0 {
+				return fmt.Errorf("invalid proxy %s: weight must be a positive integer", where)
+			}
+			e.weight = w
+		}
+		single := pc.Single(e.url)
+		if _, _, err := single.Endpoint(); err != nil {
+			return fmt.Errorf("invalid proxy %s: %v", where, err)
+		}
+		entries = append(entries, e)
+		return nil
+	}
+
+	for i, line := range pc.URLs {
+		if err := add(line, fmt.Sprintf("urls[%d]", i)); err != nil {
+			return nil, err
+		}
+	}
+	if pc.File != "" {
+		f, err := os.Open(pc.File)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read proxy file: %v", err)
+		}
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for n := 1; scanner.Scan(); n++ {
+			if err := add(scanner.Text(), fmt.Sprintf("%s line %d", pc.File, n)); err != nil {
+				return nil, err
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read proxy file: %v", err)
+		}
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("proxy pool is empty")
+	}
+	return entries, nil
+}
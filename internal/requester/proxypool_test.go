@@ -0,0 +1,307 @@
+package requester
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// testProxyPool is a pool of n proxies named after the test, so no other
+// test shares its state, running on a fake clock.
+func testProxyPool(t *testing.T, n int, pc config.ProxyConfig) (*config.ProxyConfig, *fakeClock) {
+	t.Helper()
+	name := strings.ToLower(strings.NewReplacer("/", "-", "_", "-").Replace(t.Name()))
+	for i := range n {
+		pc.URLs = append(pc.URLs, fmt.Sprintf("http://p%d.%s.test:8080", i, name))
+	}
+	pc.Enabled = true
+	pool, err := loadProxyPool(&pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	pool.mu.Lock()
+	pool.now = clock.now
+	pool.mu.Unlock()
+	return &pc, clock
+}
+
+// pickName picks a proxy for host and returns its name, "p0" to "pN".
+func pickName(t *testing.T, pc *config.ProxyConfig, host string) (string, *proxyPick) {
+	t.Helper()
+	pick, err := pickProxy(pc, host)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _, _ := strings.Cut(strings.TrimPrefix(pick.entry.url, "http://"), ".")
+	return name, pick
+}
+
+// fail reports n dial failures through the proxy named name, whichever
+// proxy the strategy would pick.
+func fail(t *testing.T, pc *config.ProxyConfig, name string, n int) {
+	t.Helper()
+	for range n {
+		_, pick := pickName(t, pc, "example.com")
+		i := slices.IndexFunc(pick.pool.entries, func(e *proxyEntry) bool {
+			return strings.HasPrefix(e.url, "http://"+name+".")
+		})
+		pick.entry = pick.pool.entries[i]
+		pick.report(errors.New("dial tcp: connection refused"))
+	}
+}
+
+func TestProxyPoolRoundRobin(t *testing.T) {
+	pc, _ := testProxyPool(t, 3, config.ProxyConfig{MaxFailures: 1})
+	var got []string
+	for range 6 {
+		name, _ := pickName(t, pc, "example.com")
+		got = append(got, name)
+	}
+	if want := []string{"p0", "p1", "p2", "p0", "p1", "p2"}; !slices.Equal(got, want) {
+		t.Errorf("picks %q, want %q", got, want)
+	}
+
+	fail(t, pc, "p1", 1)
+	got = got[:0]
+	for range 4 {
+		name, _ := pickName(t, pc, "example.com")
+		got = append(got, name)
+	}
+	if slices.Contains(got, "p1") || !slices.Contains(got, "p0") || !slices.Contains(got, "p2") {
+		t.Errorf("picks %q with p1 quarantined", got)
+	}
+}
+
+func TestProxyPoolQuarantine(t *testing.T) {
+	pc, clock := testProxyPool(t, 2, config.ProxyConfig{MaxFailures: 3, Quarantine: 60})
+	picks := func() map[string]int {
+		seen := map[string]int{}
+		for range 10 {
+			name, _ := pickName(t, pc, "example.com")
+			seen[name]++
+		}
+		return seen
+	}
+
+	// Failures must be consecutive: a success starts over.
+	fail(t, pc, "p0", 2)
+	for {
+		if name, pick := pickName(t, pc, "example.com"); name == "p0" {
+			pick.report(nil)
+			break
+		}
+	}
+	fail(t, pc, "p0", 2)
+	if picks()["p0"] == 0 {
+		t.Fatal("p0 quarantined after failures a success separated")
+	}
+
+	// Errors where the proxy answered show it works, like a success;
+	// cancellations leave the count alone.
+	for _, err := range []error{
+		&proxyRefusedError{errors.New("proxy connect failed: 502 Bad Gateway")},
+		&ProxyAuthError{Err: errors.New("proxy connect failed: 407")},
+		&ConfigError{errors.New("bad connect_headers")},
+	} {
+		for {
+			if name, pick := pickName(t, pc, "example.com"); name == "p0" {
+				pick.report(err)
+				break
+			}
+		}
+		fail(t, pc, "p0", 2)
+		if picks()["p0"] == 0 {
+			t.Fatalf("p0 quarantined although %v came between its failures", err)
+		}
+	}
+	for {
+		if name, pick := pickName(t, pc, "example.com"); name == "p0" {
+			pick.report(fmt.Errorf("proxy connect: %w", context.Canceled))
+			break
+		}
+	}
+	fail(t, pc, "p0", 1) // the third consecutive failure, cancellation aside
+	if n := picks()["p0"]; n != 0 {
+		t.Fatalf("quarantined p0 picked %d times", n)
+	}
+	clock.advance(60 * time.Second)
+	if n := picks()["p0"]; n != 0 {
+		t.Fatalf("p0 picked %d times at the end of its quarantine", n)
+	}
+	clock.advance(time.Second)
+	if picks()["p0"] == 0 {
+		t.Fatal("p0 still quarantined after the cooldown")
+	}
+
+	// On probation, one failure quarantines it again; a success clears it.
+	fail(t, pc, "p0", 1)
+	if n := picks()["p0"]; n != 0 {
+		t.Fatalf("p0 picked %d times after failing on probation", n)
+	}
+	clock.advance(61 * time.Second)
+	for {
+		if name, pick := pickName(t, pc, "example.com"); name == "p0" {
+			pick.report(nil)
+			break
+		}
+	}
+	fail(t, pc, "p0", 1)
+	if picks()["p0"] == 0 {
+		t.Error("p0 quarantined by one failure after recovering")
+	}
+}
+
+func TestProxyPoolAllQuarantined(t *testing.T) {
+	pc, clock := testProxyPool(t, 2, config.ProxyConfig{MaxFailures: 1})
+	fail(t, pc, "p0", 1)
+	fail(t, pc, "p1", 1)
+	seen := map[string]bool{}
+	for range 4 {
+		name, pick := pickName(t, pc, "example.com")
+		if !pick.allQuarantined() {
+			t.Error("pick not marked as made from quarantined proxies")
+		}
+		seen[name] = true
+	}
+	if len(seen) != 2 {
+		t.Errorf("picked %v, want both proxies", seen)
+	}
+
+	clock.advance(defaultProxyQuarantine + time.Second)
+	if _, pick := pickName(t, pc, "example.com"); pick.allQuarantined() {
+		t.Error("pick after the default quarantine still marked")
+	}
+}
+
+func TestProxyPoolSticky(t *testing.T) {
+	hosts := make([]string, 200)
+	for i := range hosts {
+		hosts[i] = fmt.Sprintf("site%d.example", i)
+	}
+	for _, strategy := range []string{config.ProxyStickyHost, config.ProxyStickySession} {
+		t.Run(strategy, func(t *testing.T) {
+			pc, clock := testProxyPool(t, 4, config.ProxyConfig{Strategy: strategy, SessionKey: "initial", MaxFailures: 1, Quarantine: 60})
+			// pick sends key through the pool, as a host or a session key.
+			pick := func(key string) string {
+				if strategy == config.ProxyStickySession {
+					pc.SessionKey = key
+					key = "example.com"
+				}
+				name, _ := pickName(t, pc, key)
+				return name
+			}
+
+			assigned := map[string]string{}
+			perProxy := map[string]int{}
+			for _, h := range hosts {
+				assigned[h] = pick(h)
+				perProxy[assigned[h]]++
+			}
+			for i := range 4 {
+				if n := perProxy[fmt.Sprint("p", i)]; n < len(hosts)/10 {
+					t.Errorf("p%d got %d of %d keys", i, n, len(hosts))
+				}
+			}
+			for _, h := range hosts {
+				if got := pick(strings.ToUpper(h)); strategy == config.ProxyStickyHost && got != assigned[h] {
+					t.Fatalf("%s moved from %s to %s", h, assigned[h], got)
+				}
+				if got := pick(h); got != assigned[h] {
+					t.Fatalf("%s moved from %s to %s", h, assigned[h], got)
+				}
+			}
+
+			// Only the keys of a quarantined proxy move, and they come back.
+			fail(t, pc, "p2", 1)
+			for _, h := range hosts {
+				got := pick(h)
+				if assigned[h] == "p2" && got == "p2" || assigned[h] != "p2" && got != assigned[h] {
+					t.Fatalf("with p2 quarantined, %s went from %s to %s", h, assigned[h], got)
+				}
+			}
+			clock.advance(61 * time.Second)
+			for _, h := range hosts {
+				if got := pick(h); got != assigned[h] {
+					t.Fatalf("after p2's quarantine, %s went from %s to %s", h, assigned[h], got)
+				}
+			}
+		})
+	}
+}
+
+func TestProxyPoolWeighted(t *testing.T) {
+	pc := &config.ProxyConfig{
+		Enabled:  true,
+		Strategy: config.ProxyWeighted,
+		URLs:     []string{"http://light.weighted.test:8080 1", "http://heavy.weighted.test:8080 3"},
+	}
+	counts := map[string]int{}
+	for range 4000 {
+		name, _ := pickName(t, pc, "example.com")
+		counts[name]++
+	}
+	if share := float64(counts["heavy"]) / 4000; share < 0.70 || share > 0.80 {
+		t.Errorf("heavy proxy got %.2f of the picks, want 0.75", share)
+	}
+}
+
+func TestProxyPoolFile(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "proxies.txt", []byte("# pool\nhttp://a.file.test:8080\n\nhttp://b.file.test:8080 2\n"))
+	pc := &config.ProxyConfig{Enabled: true, File: file, URLs: []string{"socks5://c.file.test:1080"}, MaxFailures: 1}
+	pool, err := loadProxyPool(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var urls []string
+	for _, e := range pool.entries {
+		urls = append(urls, fmt.Sprint(e.url, " ", e.weight))
+	}
+	if want := []string{"socks5://c.file.test:1080 1", "http://a.file.test:8080 1", "http://b.file.test:8080 2"}; !slices.Equal(urls, want) {
+		t.Errorf("entries %q, want %q", urls, want)
+	}
+
+	// A rewritten file is read again; proxies still listed keep their state.
+	pick := &proxyPick{pool: pool, entry: pool.entries[1], max: 1, period: time.Minute}
+	pick.report(errors.New("dial tcp: connection refused"))
+	os.WriteFile(file, []byte("http://a.file.test:8080\nhttp://d.file.test:8080\n"), 0600)
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(file, later, later)
+	reloaded, err := loadProxyPool(pc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded == pool || len(reloaded.entries) != 3 {
+		t.Fatalf("file not read again: %d entries", len(reloaded.entries))
+	}
+	if a := reloaded.entries[1]; a.url != "http://a.file.test:8080" || a.failures != 1 || a.until.IsZero() {
+		t.Errorf("a.file.test lost its quarantine: %+v", a)
+	}
+	if same, _ := loadProxyPool(pc); same != reloaded {
+		t.Error("unchanged file read again")
+	}
+
+	for name, content := range map[string]string{
+		"bad weight":   "http://a.test:8080 0\n",
+		"extra field":  "http://a.test:8080 1 x\n",
+		"bad scheme":   "ftp://a.test:21\n",
+		"only comment": "# nothing\n",
+	} {
+		bad := writeFile(t, dir, strings.ReplaceAll(name, " ", "-")+".txt", []byte(content))
+		if _, err := proxyPoolEntries(&config.ProxyConfig{File: bad}); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+	if _, err := loadProxyPool(&config.ProxyConfig{File: filepath.Join(dir, "missing.txt")}); err == nil {
+		t.Error("missing file accepted")
+	}
+}
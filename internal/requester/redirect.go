@@ -0,0 +1,134 @@
+package requester
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// DefaultMaxRedirects is the hop limit Chrome and Firefox use.
+const DefaultMaxRedirects = 20
+
+// Redirect is one hop of a followed redirect chain.
+type Redirect struct {
+	URL      string `json:"url"`
+	Status   int    `json:"status"`
+	Location string `json:"location"`
+}
+
+// bodyHeaders describe the request body and go when a redirect drops it.
+var bodyHeaders = []string{"Content-Type", "Content-Length", "Content-Encoding", "Content-Language", "Content-Location"}
+
+// followRedirects sends req with send and follows up to max redirects the
+// way browsers do: 301/302 turn POST into GET, 303 turns anything but
+// GET/HEAD into GET, 307/308 resend the method and body. Credentials are
+// dropped when a hop changes origin. As with http.Client, each request's
+// Response is the redirect that led to it; see RedirectChain.
+func followRedirects(req *http.Request, max int, send func(*http.Request) (*http.Response, error)) (*http.Response, error) {
+	for hops := 0; ; hops++ {
+		resp, err := send(req)
+		if err != nil {
+			return nil, err
+		}
+		resp.Request = req
+
+		location := resp.Header.Get("Location")
+		if !isRedirect(resp.StatusCode) || location == "" {
+			return resp, nil
+		}
+		if hops >= max {
+			resp.Body.Close()
+			return nil, fmt.Errorf("stopped after %d redirects", max)
+		}
+		next, err := redirectRequest(req, resp, location)
+		// Drain a little so the connection can serve the next hop.
+		io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req = next
+	}
+}
+
+func isRedirect(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// redirectRequest builds the request for the hop resp points at.
+func redirectRequest(req *http.Request, resp *http.Response, location string) (*http.Request, error) {
+	target, err := req.URL.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redirect location %q: %v", location, err)
+	}
+	if target.Scheme != "http" && target.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported redirect to %q", location)
+	}
+
+	next := req.Clone(req.Context())
+	next.URL = target
+	next.Response = resp
+
+	method := req.Method
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound:
+		if method == http.MethodPost {
+			method = http.MethodGet
+		}
+	case http.StatusSeeOther:
+		if method != http.MethodGet && method != http.MethodHead {
+			method = http.MethodGet
+		}
+	}
+	if method != req.Method {
+		next.Method = method
+		next.Body, next.GetBody, next.ContentLength = nil, nil, 0
+		for _, k := range bodyHeaders {
+			next.Header.Del(k)
+		}
+	} else if req.GetBody != nil {
+		if next.Body, err = req.GetBody(); err != nil {
+			return nil, err
+		}
+	} else if req.Body != nil && req.Body != http.NoBody {
+		return nil, fmt.Errorf("cannot resend request body to %s", target)
+	}
+
+	if !sameOrigin(req.URL, target) {
+		next.Header.Del("Authorization")
+		next.Header.Del("Cookie")
+	}
+	if next.Host != "" && target.Host != req.URL.Host {
+		// An explicit Host belongs to the original URL; keep its position
+		// but point it at the new one.
+		next.Host = target.Host
+		if next.Header.Get("Host") != "" {
+			next.Header.Set("Host", target.Host)
+		}
+	}
+	return next, nil
+}
+
+func sameOrigin(a, b *url.URL) bool {
+	return a.Scheme == b.Scheme && strings.EqualFold(originAddr(a), originAddr(b))
+}
+
+// RedirectChain lists the redirects followed to reach resp, oldest first.
+func RedirectChain(resp *http.Response) []Redirect {
+	var chain []Redirect
+	for req := resp.Request; req != nil && req.Response != nil; req = req.Response.Request {
+		r := req.Response
+		chain = append(chain, Redirect{URL: r.Request.URL.String(), Status: r.StatusCode, Location: req.URL.String()})
+	}
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+	return chain
+}
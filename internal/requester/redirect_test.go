@@ -0,0 +1,189 @@
+package requester
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// sentRequest is what a fake send saw for one hop.
+type sentRequest struct {
+	method, url, body string
+	header            http.Header
+}
+
+// redirectTo is a redirect response a fake send returns.
+type redirectTo struct {
+	status   int
+	location string
+}
+
+// redirectServer fakes send: hop i gets responses[i], and every later hop
+// a 200.
+func redirectServer(responses ...redirectTo) (send func(*http.Request) (*http.Response, error), sent *[]sentRequest) {
+	sent = &[]sentRequest{}
+	send = func(r *http.Request) (*http.Response, error) {
+		body := ""
+		if r.Body != nil {
+			data, _ := io.ReadAll(r.Body)
+			body = string(data)
+		}
+		*sent = append(*sent, sentRequest{r.Method, r.URL.String(), body, r.Header.Clone()})
+		resp := &http.Response{StatusCode: 200, Header: http.Header{}, Body: io.NopCloser(strings.NewReader("ok"))}
+		if i := len(*sent) - 1; i < len(responses) {
+			resp.StatusCode = responses[i].status
+			resp.Header.Set("Location", responses[i].location)
+		}
+		return resp, nil
+	}
+	return send, sent
+}
+
+func TestFollowRedirectsMethod(t *testing.T) {
+	tests := []struct {
+		status     int
+		method     string
+		wantMethod string
+		keepBody   bool
+	}{
+		{301, "POST", "GET", false},
+		{302, "POST", "GET", false},
+		{303, "POST", "GET", false},
+		{303, "PUT", "GET", false},
+		{303, "HEAD", "HEAD", false},
+		{301, "PUT", "PUT", true},
+		{302, "DELETE", "DELETE", true},
+		{307, "POST", "POST", true},
+		{308, "POST", "POST", true},
+		{308, "PATCH", "PATCH", true},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.status, " ", tt.method), func(t *testing.T) {
+			body := io.Reader(strings.NewReader("a=1"))
+			if tt.method == "HEAD" {
+				body = nil
+			}
+			req, _ := http.NewRequest(tt.method, "https://example.com/form", body)
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			send, sent := redirectServer(redirectTo{tt.status, "/done"})
+			resp, err := followRedirects(req, 10, send)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if len(*sent) != 2 {
+				t.Fatalf("%d requests sent, want 2", len(*sent))
+			}
+			hop := (*sent)[1]
+			if hop.method != tt.wantMethod || hop.url != "https://example.com/done" {
+				t.Errorf("second hop %s %s, want %s https://example.com/done", hop.method, hop.url, tt.wantMethod)
+			}
+			wantBody, wantType := "", ""
+			if tt.keepBody {
+				wantBody, wantType = "a=1", "application/x-www-form-urlencoded"
+			}
+			if tt.method == "HEAD" {
+				wantType = "application/x-www-form-urlencoded"
+			}
+			if hop.body != wantBody {
+				t.Errorf("second hop body %q, want %q", hop.body, wantBody)
+			}
+			if got := hop.header.Get("Content-Type"); got != wantType {
+				t.Errorf("second hop Content-Type %q, want %q", got, wantType)
+			}
+		})
+	}
+}
+
+func TestFollowRedirectsCredentials(t *testing.T) {
+	tests := []struct {
+		location string
+		keep     bool
+	}{
+		{"/same", true},
+		{"https://EXAMPLE.com:443/explicit-port", true},
+		{"https://other.example.com/", false},
+		{"http://example.com/", false},
+		{"https://example.com:8443/", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.location, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "https://example.com/", nil)
+			req.Header.Set("Authorization", "Bearer token")
+			req.Header.Set("Cookie", "sid=1")
+			req.Header.Set("X-Custom", "kept")
+			send, sent := redirectServer(redirectTo{302, tt.location})
+			resp, err := followRedirects(req, 10, send)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			h := (*sent)[1].header
+			for _, name := range []string{"Authorization", "Cookie"} {
+				if kept := h.Get(name) != ""; kept != tt.keep {
+					t.Errorf("%s kept = %v, want %v", name, kept, tt.keep)
+				}
+			}
+			if h.Get("X-Custom") != "kept" {
+				t.Error("X-Custom was dropped")
+			}
+		})
+	}
+}
+
+func TestFollowRedirectsLimit(t *testing.T) {
+	loop := make([]redirectTo, 30)
+	for i := range loop {
+		loop[i] = redirectTo{302, "/again"}
+	}
+	for _, max := range []int{1, 3, DefaultMaxRedirects} {
+		req, _ := http.NewRequest("GET", "https://example.com/", nil)
+		send, sent := redirectServer(loop...)
+		if _, err := followRedirects(req, max, send); err == nil || !strings.Contains(err.Error(), "stopped after") {
+			t.Errorf("max %d: error %v", max, err)
+		}
+		if len(*sent) != max+1 {
+			t.Errorf("max %d: %d requests sent, want %d", max, len(*sent), max+1)
+		}
+	}
+
+	// Exactly max redirects still succeed.
+	req, _ := http.NewRequest("GET", "https://example.com/", nil)
+	send, _ := redirectServer(loop[:3]...)
+	resp, err := followRedirects(req, 3, send)
+	if err != nil {
+		t.Fatal(err)
+	}
+	chain := RedirectChain(resp)
+	if len(chain) != 3 || chain[0].URL != "https://example.com/" || chain[2].Location != "https://example.com/again" || chain[0].Status != 302 {
+		t.Errorf("chain %+v", chain)
+	}
+}
+
+func TestFollowRedirectsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		status   int
+		location string
+		body     io.Reader // nil for a body that cannot be replayed
+		err      string
+	}{
+		{"unsupported scheme", 302, "ftp://example.com/", strings.NewReader(""), "unsupported redirect"},
+		{"bad location", 302, "http://[::1", strings.NewReader(""), "invalid redirect location"},
+		{"307 with a streamed body", 307, "/next", nil, "cannot resend request body"},
+	}
+	for _, tt := range tests {
+		req, _ := http.NewRequest("POST", "https://example.com/", tt.body)
+		if tt.body == nil {
+			req.Body, req.ContentLength = io.NopCloser(strings.NewReader("x")), -1
+		}
+		send, _ := redirectServer(redirectTo{tt.status, tt.location})
+		if _, err := followRedirects(req, 10, send); err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
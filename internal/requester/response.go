@@ -3,22 +3,18 @@ package requester
 import (
 	"fmt"
 	"io"
-	"net"
 	"net/http"
 	"os"
 )
 
-func ForwardResponse(resp *http.Response, conn net.Conn) error {
-	defer conn.Close()
-
+// ForwardResponse writes resp to stdout as it arrives and closes its body.
+func ForwardResponse(resp *http.Response) error {
 	// Write status line
 	fmt.Fprintf(os.Stdout, "HTTP/%d.%d %s\r\n", resp.ProtoMajor, resp.ProtoMinor, resp.Status)
 
-	// Write headers
-	for k, vv := range resp.Header {
-		for _, v := range vv {
-			fmt.Fprintf(os.Stdout, "%s: %s\r\n", k, v)
-		}
+	// Write headers in the order they arrived
+	for _, hdr := range ResponseHeaders(resp) {
+		fmt.Fprintf(os.Stdout, "%s: %s\r\n", hdr.Name, hdr.Value)
 	}
 	fmt.Fprintf(os.Stdout, "\r\n")
 	os.Stdout.Sync()
@@ -0,0 +1,61 @@
+package requester
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+)
+
+// socks4Connect asks the SOCKS4 proxy on conn to connect to addr. An IPv4
+// addr is sent as is; a host name is sent with the SOCKS4a extension for
+// the proxy to resolve. The URL's username, if any, is the user ID.
+func socks4Connect(conn net.Conn, addr string, proxyURL *url.URL) error {
+	host, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return fmt.Errorf("socks4: invalid port %q", portStr)
+	}
+
+	req := []byte{4, 1, byte(port >> 8), byte(port)}
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		req = append(req, 0, 0, 0, 1) // 0.0.0.x marks a SOCKS4a host name
+	case ip.To4() != nil:
+		req = append(req, ip.To4()...)
+	default:
+		return fmt.Errorf("socks4: cannot connect to IPv6 address %s", host)
+	}
+	if proxyURL.User != nil {
+		req = append(req, proxyURL.User.Username()...)
+	}
+	req = append(req, 0)
+	if ip == nil {
+		req = append(req, host...)
+		req = append(req, 0)
+	}
+	if _, err := conn.Write(req); err != nil {
+		return err
+	}
+
+	var resp [8]byte
+	if _, err := io.ReadFull(conn, resp[:]); err != nil {
+		return fmt.Errorf("socks4: %v", err)
+	}
+	switch resp[1] {
+	case 90:
+		return nil
+	case 92, 93:
+		// The proxy could not confirm the user ID with identd
+		return &ProxyAuthError{Proxy: proxyURL.Host, Err: fmt.Errorf("socks4: user ID rejected (code %d)", resp[1])}
+	case 91:
+		return &proxyRefusedError{errors.New("socks4: request rejected or failed")}
+	}
+	return fmt.Errorf("socks4: unexpected reply code %d", resp[1])
+}
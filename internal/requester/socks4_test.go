@@ -0,0 +1,116 @@
+package requester
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"fingerPrintRequester/internal/config"
+)
+
+// socks4Proxy is an in-process SOCKS4 proxy replying with code. Each
+// request it reads, up to the host name's NUL for SOCKS4a, is sent on the
+// returned channel; a granted one writes "socks4 tunnel" and closes.
+func socks4Proxy(t *testing.T, code byte) (string, <-chan []byte) {
+	t.Helper()
+	requests := make(chan []byte, 10)
+	addr := serveStub(t, func(conn net.Conn) {
+		br := bufio.NewReader(conn)
+		req := make([]byte, 8)
+		if _, err := io.ReadFull(br, req); err != nil {
+			return
+		}
+		userID, err := br.ReadBytes(0)
+		if err != nil {
+			return
+		}
+		req = append(req, userID...)
+		if req[4] == 0 && req[5] == 0 && req[6] == 0 && req[7] != 0 {
+			host, err := br.ReadBytes(0)
+			if err != nil {
+				return
+			}
+			req = append(req, host...)
+		}
+		requests <- req
+		conn.Write([]byte{0, code, 0, 0, 0, 0, 0, 0})
+		if code == 90 {
+			io.WriteString(conn, "socks4 tunnel")
+		}
+	})
+	return addr, requests
+}
+
+func TestSOCKS4Request(t *testing.T) {
+	tests := []struct {
+		name    string
+		url     string
+		target  string
+		resolve []string
+		want    []byte
+	}{
+		{"socks4a host name", "socks4a://user@", "example.com:443", nil,
+			[]byte("\x04\x01\x01\xbb\x00\x00\x00\x01user\x00example.com\x00")},
+		{"socks4a IPv4", "socks4a://user@", "192.0.2.10:8080", nil,
+			[]byte("\x04\x01\x1f\x90\xc0\x00\x02\x0auser\x00")},
+		{"socks4 resolves locally", "socks4://", "example.com:443", []string{"example.com:443:192.0.2.10"},
+			[]byte("\x04\x01\x01\xbb\xc0\x00\x02\x0a\x00")},
+		{"socks4a with resolve", "socks4a://", "example.com:443", []string{"example.com:443:192.0.2.10"},
+			[]byte("\x04\x01\x01\xbb\xc0\x00\x02\x0a\x00")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			addr, requests := socks4Proxy(t, 90)
+			cfg := proxyConfig(config.ProxyConfig{URL: tt.url + addr})
+			cfg.Resolve = tt.resolve
+			got, err := dialTunnel(t, tt.target, cfg)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != "socks4 tunnel" {
+				t.Errorf("tunnel delivered %q", got)
+			}
+			if req := <-requests; !bytes.Equal(req, tt.want) {
+				t.Errorf("request %q, want %q", req, tt.want)
+			}
+		})
+	}
+}
+
+func TestSOCKS4Rejects(t *testing.T) {
+	for _, code := range []byte{91, 92, 93, 0x42} {
+		addr, _ := socks4Proxy(t, code)
+		_, err := dialTunnel(t, "example.com:443", proxyConfig(config.ProxyConfig{URL: "socks4a://user@" + addr}))
+		var authErr *ProxyAuthError
+		var refused *proxyRefusedError
+		switch code {
+		case 91:
+			if !errors.As(err, &refused) {
+				t.Errorf("code 91: error %v, want a proxyRefusedError", err)
+			}
+		case 92, 93:
+			if !errors.As(err, &authErr) || authErr.Proxy != addr {
+				t.Errorf("code %d: error %v, want a ProxyAuthError for %s", code, err, addr)
+			}
+		default:
+			if err == nil || !strings.Contains(err.Error(), "unexpected reply code 66") || errors.As(err, &refused) || errors.As(err, &authErr) {
+				t.Errorf("code %d: error %v", code, err)
+			}
+		}
+	}
+
+	addr, requests := socks4Proxy(t, 90)
+	_, err := dialTunnel(t, "[2001:db8::1]:443", proxyConfig(config.ProxyConfig{URL: "socks4a://" + addr}))
+	if err == nil || !strings.Contains(err.Error(), "IPv6") {
+		t.Errorf("IPv6 target: error %v", err)
+	}
+	select {
+	case req := <-requests:
+		t.Errorf("IPv6 target sent %q", req)
+	default:
+	}
+}
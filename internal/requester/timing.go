@@ -0,0 +1,184 @@
+package requester
+
+import (
+	"context"
+	"net"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// Timings are the milliseconds from the start of a request to the end of
+// each phase, cumulative like curl's -w time_* variables. Phases a request
+// skipped (DNS for an IP address, dialing on a reused connection) are zero.
+// After redirects, the phases are those of the last hop.
+type Timings struct {
+	DNS          float64 `json:"dns_ms,omitempty"`           // time_namelookup
+	Connect      float64 `json:"connect_ms,omitempty"`       // time_connect
+	Proxy        float64 `json:"proxy_ms,omitempty"`         // proxy tunnel established
+	TLS          float64 `json:"tls_ms,omitempty"`           // time_appconnect
+	WroteRequest float64 `json:"wrote_request_ms,omitempty"` // request headers and body sent
+	FirstByte    float64 `json:"first_byte_ms,omitempty"`    // time_starttransfer
+	Redirect     float64 `json:"redirect_ms,omitempty"`      // time_redirect
+	Total        float64 `json:"total_ms"`                   // time_total
+	Reused       bool    `json:"reused,omitempty"`           // the last hop used a pooled connection
+	// ProxyQuarantined is set when every proxy in the pool was
+	// quarantined and the last hop went through one of them anyway.
+	ProxyQuarantined bool `json:"proxy_quarantined,omitempty"`
+}
+
+// Timer records the Timings of one request, including its redirects, and
+// the address and proxy it was sent to.
+type Timer struct {
+	mu     sync.Mutex
+	start  time.Time
+	t      Timings
+	remote string
+	proxy  string
+	done   bool
+}
+
+func NewTimer() *Timer {
+	return &Timer{start: time.Now()}
+}
+
+type timerKey struct{}
+
+// WithTimer returns a context whose requests record their phases in t.
+func WithTimer(ctx context.Context, t *Timer) context.Context {
+	// net.Dialer resolves names itself and reports it through httptrace
+	ctx = httptrace.WithClientTrace(ctx, &httptrace.ClientTrace{
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			if info.Err == nil {
+				t.mark(&t.t.DNS)
+			}
+		},
+	})
+	return context.WithValue(ctx, timerKey{}, t)
+}
+
+// timerFrom returns ctx's Timer, or nil.
+func timerFrom(ctx context.Context) *Timer {
+	t, _ := ctx.Value(timerKey{}).(*Timer)
+	return t
+}
+
+// mark sets a phase of t.t, so t must not be nil.
+func (t *Timer) mark(field *float64) {
+	t.mu.Lock()
+	*field = t.elapsed()
+	t.mu.Unlock()
+}
+
+func (t *Timer) elapsed() float64 {
+	return float64(time.Since(t.start).Microseconds()) / 1000
+}
+
+// The phase methods accept a nil Timer, for requests without one.
+
+func (t *Timer) resolved() {
+	if t != nil {
+		t.mark(&t.t.DNS)
+	}
+}
+
+func (t *Timer) connected() {
+	if t != nil {
+		t.mark(&t.t.Connect)
+	}
+}
+
+func (t *Timer) tunneled() {
+	if t != nil {
+		t.mark(&t.t.Proxy)
+	}
+}
+
+func (t *Timer) handshook() {
+	if t != nil {
+		t.mark(&t.t.TLS)
+	}
+}
+
+func (t *Timer) wrote() {
+	if t != nil {
+		t.mark(&t.t.WroteRequest)
+	}
+}
+
+// firstByte keeps the earliest byte, which may belong to a 1xx response.
+func (t *Timer) firstByte() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	if t.t.FirstByte == 0 {
+		t.t.FirstByte = t.elapsed()
+	}
+	t.mu.Unlock()
+}
+
+func (t *Timer) setReused(reused bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.t.Reused = reused
+	t.mu.Unlock()
+}
+
+func (t *Timer) setRemote(conn net.Conn) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.remote = conn.RemoteAddr().String()
+	t.mu.Unlock()
+}
+
+// RemoteAddr returns the ip:port the last hop was sent to: the server's,
+// or the proxy's when there is one. It is empty if no connection was made.
+func (t *Timer) RemoteAddr() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.remote
+}
+
+func (t *Timer) setProxy(proxy string, quarantined bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.proxy = proxy
+	t.t.ProxyQuarantined = quarantined
+	t.mu.Unlock()
+}
+
+// Proxy returns the URL, without password, of the proxy the last hop went
+// through, or "" without one.
+func (t *Timer) Proxy() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.proxy
+}
+
+// redirected starts a new hop: the previous hop's phases are dropped.
+func (t *Timer) redirected() {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.t = Timings{Redirect: t.elapsed()}
+	t.mu.Unlock()
+}
+
+// Finish records the total time on its first call and returns the Timings.
+func (t *Timer) Finish() Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !t.done {
+		t.t.Total = t.elapsed()
+		t.done = true
+	}
+	return t.t
+}
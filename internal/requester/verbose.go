@@ -0,0 +1,70 @@
+package requester
+
+import (
+	"fmt"
+	"os"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/fingerprint"
+)
+
+// printFingerprint writes the hashes of the ClientHello actually sent to
+// stderr, curl -v style, so stdout stays a plain HTTP response.
+func printFingerprint(rawHello []byte, cfg *config.Config) {
+	hashes, err := fingerprint.HashClientHello(rawHello)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "* fingerprint: %v\n", err)
+		return
+	}
+	hashes.SetAkamai(fingerprint.AkamaiFingerprint(cfg))
+
+	fmt.Fprintf(os.Stderr, "* JA3: %s\n", hashes.JA3)
+	fmt.Fprintf(os.Stderr, "* JA3 hash: %s\n", hashes.JA3Hash)
+	fmt.Fprintf(os.Stderr, "* JA3N: %s\n", hashes.JA3N)
+	fmt.Fprintf(os.Stderr, "* JA3N hash: %s\n", hashes.JA3NHash)
+	fmt.Fprintf(os.Stderr, "* JA4: %s\n", hashes.JA4)
+	if hashes.Akamai != "" {
+		fmt.Fprintf(os.Stderr, "* Akamai: %s\n", hashes.Akamai)
+		fmt.Fprintf(os.Stderr, "* Akamai hash: %s\n", hashes.AkamaiHash)
+	}
+}
+
+// printRedirects lists the redirects followed, one line per hop.
+func printRedirects(chain []Redirect) {
+	for _, r := range chain {
+		fmt.Fprintf(os.Stderr, "* Redirect: %d %s -> %s\n", r.Status, r.URL, r.Location)
+	}
+}
+
+// printTimings writes the address connected to and the phases to stderr,
+// curl -w style.
+func printTimings(timer *Timer) {
+	t := timer.Finish()
+	phase := func(name string, ms float64) {
+		if ms > 0 {
+			fmt.Fprintf(os.Stderr, "* %-14s %9.3f ms\n", name+":", ms)
+		}
+	}
+	if proxy := timer.Proxy(); proxy != "" {
+		if t.ProxyQuarantined {
+			fmt.Fprintf(os.Stderr, "* Using proxy %s (every proxy in the pool is quarantined)\n", proxy)
+		} else {
+			fmt.Fprintf(os.Stderr, "* Using proxy %s\n", proxy)
+		}
+	}
+	if addr := timer.RemoteAddr(); addr != "" {
+		if t.Reused {
+			fmt.Fprintf(os.Stderr, "* Reused connection to %s\n", addr)
+		} else {
+			fmt.Fprintf(os.Stderr, "* Connected to %s\n", addr)
+		}
+	}
+	phase("Redirects", t.Redirect)
+	phase("DNS", t.DNS)
+	phase("Connect", t.Connect)
+	phase("Proxy", t.Proxy)
+	phase("TLS", t.TLS)
+	phase("Wrote request", t.WroteRequest)
+	phase("First byte", t.FirstByte)
+	phase("Total", t.Total)
+}
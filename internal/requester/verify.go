@@ -0,0 +1,139 @@
+package requester
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+
+	"fingerPrintRequester/internal/config"
+
+	utls "github.com/refraction-networking/utls"
+)
+
+// CertificateError reports that the server certificate failed verification
+// or pinning. Callers surface it apart from network errors.
+type CertificateError struct {
+	Host string
+	Err  error
+}
+
+func (e *CertificateError) Error() string {
+	return fmt.Sprintf("certificate verification failed for %s: %v", e.Host, e.Err)
+}
+
+func (e *CertificateError) Unwrap() error { return e.Err }
+
+var errPinMismatch = errors.New("no certificate matches the pinned public keys")
+
+// caPools caches CA bundles by path; they are read once per process.
+var caPools sync.Map
+
+// newUTLSConfig returns the utls config for connecting to serverName with
+// cfg's verification settings.
+func newUTLSConfig(cfg *config.Config, serverName string) (*utls.Config, error) {
+	tlsConfig := &utls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: cfg.TLS.Insecure,
+	}
+	if cfg.TLS.CAFile != "" {
+		roots, err := loadCAFile(cfg.TLS.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = roots
+	}
+	if cfg.TLS.CertFile != "" {
+		cert, err := loadClientCert(&cfg.TLS)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.Certificates = []utls.Certificate{cert}
+	}
+
+	pins, err := pinsFor(cfg.TLS.Pins, serverName)
+	if err != nil {
+		return nil, err
+	}
+	if len(pins) > 0 {
+		tlsConfig.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+			return checkPins(pins, rawCerts, verifiedChains)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// certificateError wraps handshake errors caused by the certificate.
+func certificateError(err error, host string) error {
+	var verr *utls.CertificateVerificationError
+	if errors.As(err, &verr) || errors.Is(err, errPinMismatch) {
+		return &CertificateError{Host: host, Err: err}
+	}
+	return err
+}
+
+func loadCAFile(file string) (*x509.CertPool, error) {
+	if pool, ok := caPools.Load(file); ok {
+		return pool.(*x509.CertPool), nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA file: %v", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no certificates found in CA file %s", file)
+	}
+	caPools.Store(file, pool)
+	return pool, nil
+}
+
+// pinsFor collects the decoded pins of every entry matching host.
+func pinsFor(pins map[string][]string, host string) ([][]byte, error) {
+	host = strings.ToLower(host)
+	var out [][]byte
+	for pattern, values := range pins {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); !ok {
+			continue
+		}
+		for _, v := range values {
+			sum, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(v, "sha256/"))
+			if err != nil || len(sum) != sha256.Size {
+				return nil, fmt.Errorf("invalid pin %q for %s: want base64 SHA-256", v, pattern)
+			}
+			out = append(out, sum)
+		}
+	}
+	return out, nil
+}
+
+// checkPins accepts the connection if any certificate in a verified chain
+// (or, with verification off, any presented certificate) has a pinned SPKI.
+func checkPins(pins [][]byte, rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	var certs []*x509.Certificate
+	for _, chain := range verifiedChains {
+		certs = append(certs, chain...)
+	}
+	if len(verifiedChains) == 0 {
+		for _, raw := range rawCerts {
+			if cert, err := x509.ParseCertificate(raw); err == nil {
+				certs = append(certs, cert)
+			}
+		}
+	}
+	for _, cert := range certs {
+		sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+		for _, pin := range pins {
+			if bytes.Equal(sum[:], pin) {
+				return nil
+			}
+		}
+	}
+	return errPinMismatch
+}
@@ -0,0 +1,202 @@
+// Package tlsclient is an http.RoundTripper that sends requests with a
+// configurable TLS ClientHello and HTTP/2 fingerprint, the same way the
+// tlsRequester binary does.
+//
+//	t, err := tlsclient.NewTransportFromProfile("chrome")
+//	if err != nil { ... }
+//	defer t.Close()
+//	client := &http.Client{Transport: t}
+//	resp, err := client.Get("https://example.com/")
+//
+// Go's http.Header does not keep header order. Set HeaderOrderKey on a
+// request, or Config.HeaderOrder on the transport, to control it. Responses
+// carry the order they were received in under the same key, and Response.TLS
+// describes the negotiated connection.
+package tlsclient
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"fingerPrintRequester/internal/config"
+	"fingerPrintRequester/internal/cookies"
+	"fingerPrintRequester/internal/profiles"
+	"fingerPrintRequester/internal/requester"
+)
+
+// Config types are shared with the config file format (see CONFIG.md).
+type (
+	Config            = config.Config
+	TimeoutConfig     = config.TimeoutConfig
+	ProxyConfig       = config.ProxyConfig
+	DNSConfig         = config.DNSConfig
+	TLSConfig         = config.TLSConfig
+	FingerprintConfig = config.FingerprintConfig
+	ExtensionConfig   = config.ExtensionConfig
+	HTTP2Config       = config.HTTP2Config
+	HTTP2Setting      = config.HTTP2Setting
+	Header            = config.Header
+	LocalAddresses    = config.LocalAddresses
+)
+
+// HeaderOrderKey is a pseudo header whose values list header names in the
+// order they go on the wire. It is never sent; a name may repeat to
+// interleave duplicate headers.
+const HeaderOrderKey = requester.HeaderOrderKey
+
+// CertificateError is returned when the server certificate fails
+// verification or pinning (see Config.TLS).
+type CertificateError = requester.CertificateError
+
+// ProxyAuthError is returned when the proxy rejects its credentials (HTTP
+// 407 or a failed SOCKS5 authentication).
+type ProxyAuthError = requester.ProxyAuthError
+
+// DNSError is returned when Config.DNS servers cannot resolve a host.
+type DNSError = requester.DNSError
+
+// CookieJar is an RFC 6265 http.CookieJar that can be saved to a file in
+// the Netscape cookies.txt or JSON format, the same files the tlsRequester
+// binary reads with cookie_jar.
+type CookieJar = cookies.Jar
+
+// NewCookieJar returns an in-memory cookie jar.
+func NewCookieJar() *CookieJar {
+	return cookies.New()
+}
+
+// OpenCookieJar loads the cookie file at path; call Save to write it back.
+// A missing file gives an empty jar.
+func OpenCookieJar(path string) (*CookieJar, error) {
+	return cookies.Open(path)
+}
+
+// DecompressBody decodes resp's gzip, deflate, br or zstd Content-Encoding
+// while the body is read, and removes Content-Encoding and Content-Length.
+// Unlike net/http's Transport, Transport never decompresses on its own.
+func DecompressBody(resp *http.Response) {
+	requester.DecompressBody(resp)
+}
+
+// ResponseHeaders returns resp's headers in the order the server sent them,
+// duplicates included, as name/value pairs.
+func ResponseHeaders(resp *http.Response) []Header {
+	return requester.ResponseHeaders(resp)
+}
+
+// Timings are the phases of a request (DNS, connect, proxy tunnel, TLS,
+// request written, first byte) in milliseconds since it started.
+type Timings = requester.Timings
+
+// Timer records Timings for requests whose context carries it.
+type Timer = requester.Timer
+
+// NewTimer returns a Timer started now.
+func NewTimer() *Timer {
+	return requester.NewTimer()
+}
+
+// WithTimer returns a context that makes requests sent with it record
+// their phases in t; with several requests, the last one's phases win.
+//
+//	timer := tlsclient.NewTimer()
+//	req = req.WithContext(tlsclient.WithTimer(req.Context(), timer))
+//	resp, err := client.Do(req)
+//	...
+//	timings := timer.Finish()
+func WithTimer(ctx context.Context, t *Timer) context.Context {
+	return requester.WithTimer(ctx, t)
+}
+
+// LoadConfig reads a config file.
+func LoadConfig(path string) (*Config, error) {
+	return config.LoadConfig(path)
+}
+
+// DefaultConfig returns the default timeout, proxy and DNS settings with an
+// empty fingerprint; fill in Fingerprint or use ProfileConfig.
+func DefaultConfig() *Config {
+	return config.Default()
+}
+
+// ProfileConfig returns the default config with a built-in profile
+// ("chrome", "firefox_133", ...) applied.
+func ProfileConfig(name string) (*Config, error) {
+	p, err := profiles.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	cfg := config.Default()
+	p.Apply(cfg)
+	return cfg, nil
+}
+
+// Profiles lists the names of the built-in profiles.
+func Profiles() []string {
+	names := []string{}
+	for _, p := range profiles.List() {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// Transport implements http.RoundTripper. It keeps connections open and
+// shares HTTP/2 sessions between requests, and is safe for concurrent use.
+// A request's context cancels dialing, the handshake and reading the body.
+type Transport struct {
+	cfg  *Config
+	pool *requester.Pool
+}
+
+// NewTransport returns a Transport using cfg. cfg must not be modified
+// afterwards.
+func NewTransport(cfg *Config) *Transport {
+	return &Transport{cfg: cfg, pool: requester.NewPool()}
+}
+
+// NewTransportFromProfile returns a Transport using a built-in profile.
+func NewTransportFromProfile(name string) (*Transport, error) {
+	cfg, err := ProfileConfig(name)
+	if err != nil {
+		return nil, err
+	}
+	return NewTransport(cfg), nil
+}
+
+// RoundTrip sends req. Only http and https URLs are supported.
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL == nil {
+		closeBody(req)
+		return nil, errors.New("tlsclient: nil request URL")
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		closeBody(req)
+		return nil, fmt.Errorf("tlsclient: unsupported protocol scheme %q", req.URL.Scheme)
+	}
+	return t.pool.RoundTrip(req, t.cfg, "", false)
+}
+
+// CloseIdleConnections closes connections that carry no request. Called by
+// http.Client.CloseIdleConnections.
+func (t *Transport) CloseIdleConnections() {
+	t.pool.CloseIdleConnections()
+}
+
+// Close closes all connections, failing requests in flight.
+func (t *Transport) Close() error {
+	t.pool.Close()
+	return nil
+}
+
+// Client returns an http.Client using t.
+func (t *Transport) Client() *http.Client {
+	return &http.Client{Transport: t}
+}
+
+func closeBody(req *http.Request) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+}
@@ -3,6 +3,7 @@ import { existsSync } from 'fs';
 import { join, dirname } from 'path';
 import { fileURLToPath } from 'url';
 import { platform, arch } from 'os';
+import { StringDecoder } from 'string_decoder';
 
 const __dirname = dirname(fileURLToPath(import.meta.url));
 
@@ -11,9 +12,11 @@ class FingerprintRequester {
     this.binDir = options.binDir || join(__dirname, 'bin');
     this.binaryPath = options.binaryPath || this._detectBinary();
     this.configPath = options.configPath || join(__dirname, 'config.json');
+    this.profile = options.profile || null; // built-in profile, e.g. 'chrome' or 'firefox_133'
     this.defaults = {
       timeout: options.timeout || 30, // seconds
       proxy: options.proxy || null,
+      decompress: options.decompress ?? false, // decode gzip/deflate/br/zstd bodies
     };
     this.activeProcesses = new Set();
   }
@@ -57,6 +60,7 @@ class FingerprintRequester {
       data = '',
       timeout,
       proxy,
+      decompress = this.defaults.decompress,
       responseType = 'text',
       onDownloadProgress,
       validateStatus = (status) => status >= 200 && status < 300,
@@ -73,8 +77,15 @@ class FingerprintRequester {
       headers,
       body: typeof data === 'string' ? data : JSON.stringify(data),
       config_path: this.configPath,
+      decompress,
+      format: 'ndjson',
     };
 
+    const profile = config.profile || this.profile;
+    if (profile) {
+      requestPayload.profile = profile;
+    }
+
     // Add timeout if specified (in seconds)
     const timeoutSec = timeout || this.defaults.timeout;
     if (timeoutSec) {
@@ -84,13 +95,12 @@ class FingerprintRequester {
       };
     }
 
-    // Add proxy if specified
+    // Add proxy if specified; the binary takes the type from the URL scheme
+    // (http, https, socks4, socks4a, socks5, socks5h)
     const proxyUrl = proxy || this.defaults.proxy;
     if (proxyUrl) {
-      const proxyType = proxyUrl.startsWith('socks') ? 'socks5' : 'http';
       requestPayload.proxy = {
         enabled: true,
-        type: proxyType,
         url: proxyUrl,
       };
     }
@@ -98,11 +108,13 @@ class FingerprintRequester {
     return new Promise((resolve, reject) => {
       const proc = spawn(this.binaryPath);
       this.activeProcesses.add(proc);
-      let headersParsed = false;
       let responseHeaders = {};
       let responseStatus = 200;
       let responseStatusText = 'OK';
-      let headerBuffer = '';
+      let responseMeta = {};
+      let summary = null;
+      let lineBuffer = '';
+      const decoder = new StringDecoder('utf8');
       let bodyChunks = [];
       let totalLoaded = 0;
       let stderrData = '';
@@ -127,66 +139,56 @@ class FingerprintRequester {
         });
       }
 
+      // stdout is an NDJSON stream: a headers event, data events, then an end event
       proc.stdout.on('data', (chunk) => {
-        if (!headersParsed) {
-          headerBuffer += chunk.toString();
-          const headerEndIndex = headerBuffer.indexOf('\r\n\r\n');
-          
-          if (headerEndIndex !== -1) {
-            // Parse headers
-            const headerPart = headerBuffer.substring(0, headerEndIndex);
-            const bodyPart = headerBuffer.substring(headerEndIndex + 4);
-            
-            const lines = headerPart.split('\r\n');
-            const statusMatch = lines[0].match(/HTTP\/[\d.]+ (\d+) (.+)/);
-            responseStatus = statusMatch ? parseInt(statusMatch[1]) : 200;
-            responseStatusText = statusMatch ? statusMatch[2] : 'OK';
-            
-            for (let i = 1; i < lines.length; i++) {
-              const [key, ...valueParts] = lines[i].split(': ');
-              if (key) responseHeaders[key.toLowerCase()] = valueParts.join(': ');
-            }
-            
-            headersParsed = true;
-            
-            // Clear timeout for streaming responses
-            clearTimeout(timeoutId);
-            
-            // 先调用 validateStatus 让外部知道状态码
-            if (validateStatus) {
-              validateStatus(responseStatus);
-            }
-            
-            // Process body part after headers
-            if (bodyPart) {
-              bodyChunks.push(Buffer.from(bodyPart));
-              totalLoaded += bodyPart.length;
-              if (onDownloadProgress) {
-                onDownloadProgress({
-                  loaded: totalLoaded,
-                  total: parseInt(responseHeaders['content-length']) || 0,
-                  chunk: bodyPart,
-                  status: responseStatus,
-                });
-              }
+        lineBuffer += decoder.write(chunk);
+        let newline;
+        while ((newline = lineBuffer.indexOf('\n')) !== -1) {
+          const line = lineBuffer.slice(0, newline);
+          lineBuffer = lineBuffer.slice(newline + 1);
+          if (line.trim()) {
+            handleEvent(JSON.parse(line));
+          }
+        }
+      });
+
+      const handleEvent = (event) => {
+        if (event.type === 'headers') {
+          responseStatus = event.status;
+          responseStatusText = event.status_text;
+          for (const [name, value] of event.headers) {
+            const key = name.toLowerCase();
+            if (key === 'set-cookie') {
+              (responseHeaders[key] = responseHeaders[key] || []).push(value);
+            } else {
+              responseHeaders[key] = key in responseHeaders ? `${responseHeaders[key]}, ${value}` : value;
             }
           }
-        } else {
-          // Headers already parsed, process body chunks
-          const chunkStr = chunk.toString();
-          bodyChunks.push(chunk);
-          totalLoaded += chunk.length;
+          responseMeta = { proto: event.proto, alpn: event.alpn, tlsVersion: event.tls_version, cipher: event.cipher, url: event.url, remoteAddr: event.remote_addr, proxy: event.proxy, redirects: event.redirects || [] };
+
+          // Clear timeout for streaming responses
+          clearTimeout(timeoutId);
+
+          // 先调用 validateStatus 让外部知道状态码
+          if (validateStatus) {
+            validateStatus(responseStatus);
+          }
+        } else if (event.type === 'data') {
+          const data = Buffer.from(event.data, event.encoding === 'base64' ? 'base64' : 'utf8');
+          bodyChunks.push(data);
+          totalLoaded += data.length;
           if (onDownloadProgress) {
             onDownloadProgress({
               loaded: totalLoaded,
               total: parseInt(responseHeaders['content-length']) || 0,
-              chunk: chunkStr,
+              chunk: data.toString(),
               status: responseStatus,
             });
           }
+        } else if (event.type === 'end') {
+          summary = event;
         }
-      });
-
+      };
       proc.stderr.on('data', (chunk) => {
         stderrData += chunk.toString();
       });
@@ -197,7 +199,9 @@ class FingerprintRequester {
 
         if (code !== 0) {
           let errorInfo = { error: `Process exited with code ${code}`, error_type: 'UNKNOWN_ERROR' };
-          if (stderrData) {
+          if (summary && summary.error) {
+            errorInfo = summary;
+          } else if (stderrData) {
             try {
               errorInfo = JSON.parse(stderrData);
             } catch (e) {
@@ -212,9 +216,10 @@ class FingerprintRequester {
           return reject(error);
         }
 
-        const body = Buffer.concat(bodyChunks).toString();
-        let parsedData = body;
-        
+        const raw = Buffer.concat(bodyChunks);
+        const body = raw.toString();
+        let parsedData = responseType === 'arraybuffer' ? raw : body;
+
         if (responseType === 'json') {
           try {
             parsedData = JSON.parse(body);
@@ -228,6 +233,8 @@ class FingerprintRequester {
           status: responseStatus,
           statusText: responseStatusText,
           headers: responseHeaders,
+          ...responseMeta,
+          timings: summary ? summary.timings : undefined,
           config,
         };
         